@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"timetable/internal/diff"
+	"timetable/internal/gtfs"
+)
+
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOut := flags.Bool("json", false, "print the report as JSON")
+	lines := flags.String("lines", "", "comma-separated route short names to compare (default all)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable diff [-json] [-lines 11,25] <old dir|zip> <new dir|zip>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	var feeds [2]*gtfs.Feed
+	for i, path := range flags.Args() {
+		feedFS, closer, err := openFeed(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+			return 2
+		}
+		feed, _, err := gtfs.ParseFS(feedFS, gtfs.ParseOptions{})
+		closer.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diff: %s: %v\n", path, err)
+			return 2
+		}
+		feeds[i] = feed
+	}
+
+	var opts diff.Options
+	if *lines != "" {
+		opts.Lines = strings.Split(*lines, ",")
+	}
+	report := diff.Feeds(feeds[0], feeds[1], opts)
+
+	var err error
+	if *jsonOut {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return 2
+	}
+	return 0
+}
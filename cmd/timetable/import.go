@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"timetable/internal/store"
+	"timetable/internal/updater"
+)
+
+// runImport imports the feed in the data directory into the database, or
+// with -download fetches the source first. A running server keeps serving
+// its in-memory index until it is restarted or reloaded.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	download := flags.Bool("download", false, "download the feed from GTFS_SOURCE_URL instead of importing the files on disk")
+	force := flags.Bool("force", false, "with -download, reload even if the source is unchanged")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable import [-download [-force]]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	dataDir, dbPath := storagePaths()
+	// LoadOrImport already imports into an empty database.
+	empty, err := isEmptyStore(dbPath)
+	if err != nil {
+		slog.Error("Failed to open database", "err", err)
+		return 1
+	}
+
+	u := updater.New(updater.Config{
+		DataDir:          dataDir,
+		DBPath:           dbPath,
+		SourceURL:        envOrDefault("GTFS_SOURCE_URL", defaultSourceURL),
+		MaxDownloadSize:  envMegabytes("GTFS_MAX_DOWNLOAD_MB"),
+		MaxExtractedSize: envMegabytes("GTFS_MAX_EXTRACTED_MB"),
+		SourceSHA256:     os.Getenv("GTFS_SOURCE_SHA256"),
+		KeepFeeds:        envInt("FEED_RETENTION", 0),
+	})
+	defer u.Close()
+
+	if _, err := u.LoadOrImport(); err != nil {
+		slog.Error("Failed to load data", "err", err)
+		return 1
+	}
+
+	switch {
+	case *download:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		changed, err := u.Update(ctx, *force)
+		if err != nil {
+			slog.Error("Update failed", "err", err)
+			return 1
+		}
+		if !changed {
+			slog.Info("Feed unchanged, nothing imported")
+		}
+	case !empty:
+		if err := u.Reload(); err != nil {
+			slog.Error("Import failed", "err", err)
+			return 1
+		}
+	}
+
+	idx := u.Index()
+	slog.Info("Live feed", "feed_id", u.LiveFeedID(), "stations", len(idx.Stations), "trips", len(idx.TripService))
+	return 0
+}
+
+func isEmptyStore(dbPath string) (bool, error) {
+	s, err := store.Open(dbPath)
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+	return s.IsEmpty()
+}
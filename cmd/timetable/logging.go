@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging installs the default logger: text, or JSON with
+// LOG_FORMAT=json, on stderr at LOG_LEVEL (debug, info, warn or error;
+// default info). Output of the log package goes through it as well.
+func setupLogging() {
+	var level slog.Level
+	levelErr := level.UnmarshalText([]byte(envOrDefault("LOG_LEVEL", "info")))
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		h = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+
+	if levelErr != nil {
+		slog.Warn("Ignoring invalid LOG_LEVEL", "value", os.Getenv("LOG_LEVEL"))
+	}
+	if format != "" && format != "json" && format != "text" {
+		slog.Warn("Ignoring invalid LOG_FORMAT", "value", os.Getenv("LOG_FORMAT"))
+	}
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
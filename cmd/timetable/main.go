@@ -1,51 +1,230 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"syscall"
 	"time"
 
+	"timetable/internal/store"
 	"timetable/internal/updater"
 	"timetable/internal/web"
 )
 
+const defaultSourceURL = "http://www.dpmlj.cz/gtfs.zip"
+
+// defaultShutdownTimeout bounds draining requests and stopping the updater
+// separately. Twice it stays below the stop grace period in
+// docker-compose.yml, so that the database is closed before the container is
+// killed.
+const defaultShutdownTimeout = 10 * time.Second
+
+const usage = `usage: timetable <command> [arguments]
+
+Commands:
+  serve                   run the web server (default)
+  import                  import the feed on disk or download a new one
+  search FROM TO          direct connections between two stations
+  departures STATION      departure board of a station
+  stations [QUERY]        list or search stations
+  trip ID                 stop times of a trip
+  tui STATION...          full-screen departure board
+  validate <dir|zip>      check a GTFS feed
+  diff <old> <new>        compare two GTFS feeds
+  rollback                restore the feed from before the last update
+
+Stations can be given by ID or name. Run "timetable <command> -h" for the
+flags of a command.
+`
+
 func main() {
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+	setupLogging()
+
 	if tz := os.Getenv("TZ"); tz != "" {
 		loc, err := time.LoadLocation(tz)
 		if err != nil {
-			log.Fatalf("Invalid TZ %q: %v", tz, err)
+			fatal("Invalid TZ", "tz", tz, "err", err)
 		}
 		time.Local = loc
-		log.Printf("Timezone set to %s", tz)
 	}
 
-	dataDir := envOrDefault("GTFS_DATA_DIR", "gtfs")
-	dbPath := envOrDefault("DB_PATH", filepath.Join(dataDir, "timetable.db"))
-	sourceURL := envOrDefault("GTFS_SOURCE_URL", "http://www.dpmlj.cz/gtfs.zip")
+	switch cmd {
+	case "serve":
+		os.Exit(runServe())
+	case "import":
+		os.Exit(runImport(args))
+	case "search":
+		os.Exit(runSearch(args))
+	case "departures":
+		os.Exit(runDepartures(args))
+	case "stations":
+		os.Exit(runStations(args))
+	case "trip":
+		os.Exit(runTrip(args))
+	case "tui":
+		os.Exit(runTUI(args))
+	case "validate":
+		os.Exit(runValidate(args))
+	case "rollback":
+		os.Exit(runRollback())
+	case "diff":
+		os.Exit(runDiff(args))
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "timetable: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// runServe runs the web server until SIGINT or SIGTERM, then stops accepting
+// connections, waits for in-flight requests and updates to finish and closes
+// the database.
+func runServe() int {
+	if tz := os.Getenv("TZ"); tz != "" {
+		slog.Info("Timezone set", "tz", tz)
+	}
+
+	dataDir, dbPath := storagePaths()
+	sourceURL := envOrDefault("GTFS_SOURCE_URL", defaultSourceURL)
 	addr := envOrDefault("LISTEN_ADDR", ":8080")
 	templateDir := envOrDefault("TEMPLATE_DIR", "web/templates")
 	staticDir := envOrDefault("STATIC_DIR", "web/static")
 
-	u := updater.New(dataDir, dbPath, sourceURL)
-	defer u.Close()
+	u := updater.New(updater.Config{
+		DataDir:          dataDir,
+		DBPath:           dbPath,
+		SourceURL:        sourceURL,
+		MaxDownloadSize:  envMegabytes("GTFS_MAX_DOWNLOAD_MB"),
+		MaxExtractedSize: envMegabytes("GTFS_MAX_EXTRACTED_MB"),
+		SourceSHA256:     os.Getenv("GTFS_SOURCE_SHA256"),
+		CheckInterval:    envDuration("GTFS_CHECK_INTERVAL"),
+		CheckAt:          os.Getenv("GTFS_CHECK_AT"),
+		RetryAttempts:    envInt("GTFS_RETRY_ATTEMPTS", updater.DefaultRetryAttempts),
+		RetryBaseDelay:   envDuration("GTFS_RETRY_DELAY"),
+		KeepFeeds:        envInt("FEED_RETENTION", 0),
+	})
 
 	if _, err := u.LoadOrImport(); err != nil {
-		log.Fatalf("Failed to load data: %v", err)
+		u.Close()
+		fatal("Failed to load data", "err", err)
 	}
 
-	u.StartBackgroundCheck()
+	var boards []store.Board
+	if path := os.Getenv("BOARDS_FILE"); path != "" {
+		b, err := web.LoadBoards(path)
+		if err != nil {
+			u.Close()
+			fatal("Failed to load boards", "err", err)
+		}
+		boards = b
+		slog.Info("Loaded boards", "count", len(boards), "file", path)
+	}
 
-	router, err := web.NewRouter(u, templateDir, staticDir)
+	shutdown := make(chan struct{})
+	router, err := web.NewRouter(u, web.Config{
+		TemplateDir: templateDir,
+		StaticDir:   staticDir,
+		AdminToken:  os.Getenv("ADMIN_TOKEN"),
+		Boards:      boards,
+		Shutdown:    shutdown,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create router: %v", err)
+		u.Close()
+		fatal("Failed to create router", "err", err)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	srv.RegisterOnShutdown(func() { close(shutdown) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	u.StartBackgroundCheck()
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "addr", addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	status := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("Server error", "err", err)
+		status = 1
+	case <-ctx.Done():
+		// A second signal kills the process right away.
+		stop()
+		slog.Info("Shutting down")
+	}
+
+	timeout := envDuration("SHUTDOWN_TIMEOUT")
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Connections not drained", "err", err)
+	}
+	// Slow clients must not use up the time a running update has to stop.
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), timeout)
+	defer cancelStop()
+	if err := u.Stop(stopCtx); err != nil {
+		slog.Warn("Updater did not stop in time", "err", err)
+	}
+	// Close waits for an import that is still committing.
+	if err := u.Close(); err != nil {
+		slog.Error("Failed to close database", "err", err)
+		status = 1
 	}
+	slog.Info("Stopped")
+	return status
+}
+
+// runRollback restores the feed kept from before the last update. A running
+// server keeps serving its in-memory index until it is restarted.
+func runRollback() int {
+	dataDir, dbPath := storagePaths()
 
-	log.Printf("Listening on %s", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatalf("Server error: %v", err)
+	u := updater.New(updater.Config{DataDir: dataDir, DBPath: dbPath})
+	defer u.Close()
+
+	// Unlike the queries, a rollback writes to the database and has to see
+	// the live and upcoming feeds exactly as the server would restore them.
+	if _, err := u.LoadOrImport(); err != nil {
+		slog.Error("Failed to load data", "err", err)
+		return 1
+	}
+	if err := u.Rollback(); err != nil {
+		slog.Error("Rollback failed", "err", err)
+		return 1
 	}
+	return 0
+}
+
+// storagePaths returns the GTFS data directory and the database path.
+func storagePaths() (dataDir, dbPath string) {
+	dataDir = envOrDefault("GTFS_DATA_DIR", "gtfs")
+	return dataDir, envOrDefault("DB_PATH", filepath.Join(dataDir, "timetable.db"))
 }
 
 func envOrDefault(key, defaultVal string) string {
@@ -54,3 +233,46 @@ func envOrDefault(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+// envMegabytes reads a size in megabytes, returning it in bytes. Unset or
+// invalid values yield 0 so the updater falls back to its default.
+func envMegabytes(key string) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	mb, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || mb <= 0 {
+		slog.Warn("Ignoring invalid setting", "key", key, "value", v)
+		return 0
+	}
+	return mb << 20
+}
+
+// envInt reads an integer, returning def if the variable is unset or
+// invalid. A value of 0 is kept, so it can differ from the default.
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("Ignoring invalid setting", "key", key, "value", v)
+		return def
+	}
+	return n
+}
+
+func envDuration(key string) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("Ignoring invalid setting", "key", key, "value", v)
+		return 0
+	}
+	return d
+}
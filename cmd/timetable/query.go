@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"timetable/internal/search"
+	"timetable/internal/updater"
+)
+
+// queryOptions are the flags shared by the commands that query the
+// timetable.
+type queryOptions struct {
+	json   bool
+	date   string
+	time   string
+	window int
+	// timed is set for commands that take -time and -window.
+	timed bool
+}
+
+func (o *queryOptions) register(flags *flag.FlagSet, withTime bool) {
+	flags.BoolVar(&o.json, "json", false, "print the result as JSON")
+	flags.StringVar(&o.date, "date", "", "service date YYYY-MM-DD (default today)")
+	o.timed = withTime
+	if withTime {
+		flags.StringVar(&o.time, "time", "", "time HH:MM (default now)")
+		flags.IntVar(&o.window, "window", 60, "minutes to look ahead")
+	}
+}
+
+// when returns the service date and time of day the query is for.
+func (o *queryOptions) when() (time.Time, int, error) {
+	now := time.Now()
+	date := now
+	if o.date != "" {
+		d, err := time.ParseInLocation("2006-01-02", o.date, time.Local)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid date %q, want YYYY-MM-DD", o.date)
+		}
+		date = d
+	}
+	seconds := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	if o.time != "" {
+		t, err := time.Parse("15:04", o.time)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid time %q, want HH:MM", o.time)
+		}
+		seconds = t.Hour()*3600 + t.Minute()*60
+	}
+	if o.timed && o.window <= 0 {
+		return time.Time{}, 0, errors.New("window must be positive")
+	}
+	return date, seconds, nil
+}
+
+// parseInterspersed parses flags that may follow the positional arguments,
+// as in "search Fügnerova Viadukt -time 7:30", and returns the positional
+// arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// openIndex loads the timetable for the query commands from the feed files,
+// leaving the database alone. The updater's progress logging is suppressed
+// so only the result reaches the terminal.
+func openIndex() (*updater.Updater, error) {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	dataDir, _ := storagePaths()
+	u := updater.New(updater.Config{DataDir: dataDir})
+	if _, err := u.LoadReadOnly(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// resolveStation finds a station by ID or name. A name must match a single
+// station, or one station exactly.
+func resolveStation(idx *search.Index, query string) (search.Station, error) {
+	if s, ok := idx.Station(query); ok {
+		return s, nil
+	}
+	matches := idx.SearchStations(query)
+	normalized := search.NormalizeCzech(query)
+	for _, s := range matches {
+		if s.NormalizedName == normalized {
+			return s, nil
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return search.Station{}, fmt.Errorf("no station matches %q", query)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, s := range matches {
+		names[i] = s.Name
+	}
+	return search.Station{}, fmt.Errorf("%q matches several stations: %s", query, strings.Join(names, ", "))
+}
+
+type jsonStation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func runSearch(args []string) int {
+	var opts queryOptions
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	opts.register(flags, true)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable search FROM TO [-time HH:MM] [-date YYYY-MM-DD] [-window minutes] [-json]")
+		flags.PrintDefaults()
+	}
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 2 {
+		flags.Usage()
+		return 2
+	}
+	date, seconds, err := opts.when()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search: %v\n", err)
+		return 2
+	}
+
+	u, err := openIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search: %v\n", err)
+		return 1
+	}
+	defer u.Close()
+	idx := u.IndexForDate(date)
+
+	from, err := resolveStation(idx, positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search: %v\n", err)
+		return 1
+	}
+	to, err := resolveStation(idx, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search: %v\n", err)
+		return 1
+	}
+	connections := idx.FindConnections(from.ID, to.ID, seconds, opts.window, date)
+
+	if opts.json {
+		type jsonConnection struct {
+			TripID    string    `json:"trip_id"`
+			Line      string    `json:"line"`
+			Headsign  string    `json:"headsign"`
+			FromStop  string    `json:"from_stop_id"`
+			ToStop    string    `json:"to_stop_id"`
+			Departure time.Time `json:"departure"`
+			Arrival   time.Time `json:"arrival"`
+			Minutes   int       `json:"duration_minutes"`
+		}
+		out := struct {
+			From        jsonStation      `json:"from"`
+			To          jsonStation      `json:"to"`
+			Date        string           `json:"date"`
+			Connections []jsonConnection `json:"connections"`
+		}{
+			From:        jsonStation{from.ID, from.Name},
+			To:          jsonStation{to.ID, to.Name},
+			Date:        date.Format("2006-01-02"),
+			Connections: make([]jsonConnection, len(connections)),
+		}
+		for i, c := range connections {
+			out.Connections[i] = jsonConnection{
+				TripID:    c.TripID,
+				Line:      c.Line,
+				Headsign:  c.Headsign,
+				FromStop:  c.FromStopID,
+				ToStop:    c.ToStopID,
+				Departure: search.ResolveTime(date, c.DepartureTime),
+				Arrival:   search.ResolveTime(date, c.ArrivalTime),
+				Minutes:   c.Duration / 60,
+			}
+		}
+		return writeJSONOutput(out)
+	}
+
+	fmt.Printf("%s → %s, %s\n\n", from.Name, to.Name, date.Format("2.1.2006"))
+	if len(connections) == 0 {
+		fmt.Printf("No connections within %d minutes.\n", opts.window)
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tDIRECTION\tDEPARTS\tARRIVES\tDURATION\tTRIP")
+	for _, c := range connections {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d min\t%s\n", c.Line, c.Headsign,
+			search.FormatTime(c.DepartureTime), search.FormatTime(c.ArrivalTime), c.Duration/60, c.TripID)
+	}
+	tw.Flush()
+	return 0
+}
+
+func runDepartures(args []string) int {
+	var opts queryOptions
+	flags := flag.NewFlagSet("departures", flag.ExitOnError)
+	opts.register(flags, true)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable departures STATION [-time HH:MM] [-date YYYY-MM-DD] [-window minutes] [-json]")
+		flags.PrintDefaults()
+	}
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		flags.Usage()
+		return 2
+	}
+	date, seconds, err := opts.when()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "departures: %v\n", err)
+		return 2
+	}
+
+	u, err := openIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "departures: %v\n", err)
+		return 1
+	}
+	defer u.Close()
+	idx := u.IndexForDate(date)
+
+	station, err := resolveStation(idx, positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "departures: %v\n", err)
+		return 1
+	}
+	departures := idx.DepartureBoard(station.ID, seconds, opts.window, date)
+
+	if opts.json {
+		type jsonDeparture struct {
+			TripID    string    `json:"trip_id"`
+			Line      string    `json:"line"`
+			Headsign  string    `json:"headsign"`
+			StopID    string    `json:"stop_id"`
+			Departure time.Time `json:"departure"`
+		}
+		out := struct {
+			Station    jsonStation     `json:"station"`
+			Date       string          `json:"date"`
+			Departures []jsonDeparture `json:"departures"`
+		}{
+			Station:    jsonStation{station.ID, station.Name},
+			Date:       date.Format("2006-01-02"),
+			Departures: make([]jsonDeparture, len(departures)),
+		}
+		for i, d := range departures {
+			out.Departures[i] = jsonDeparture{
+				TripID:    d.TripID,
+				Line:      d.Line,
+				Headsign:  d.Headsign,
+				StopID:    d.StopID,
+				Departure: search.ResolveTime(date, d.DepartureTime),
+			}
+		}
+		return writeJSONOutput(out)
+	}
+
+	fmt.Printf("%s, %s\n\n", station.Name, date.Format("2.1.2006"))
+	if len(departures) == 0 {
+		fmt.Printf("No departures within %d minutes.\n", opts.window)
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tDIRECTION\tDEPARTS\tTRIP")
+	for _, d := range departures {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Line, d.Headsign, search.FormatTime(d.DepartureTime), d.TripID)
+	}
+	tw.Flush()
+	return 0
+}
+
+func runStations(args []string) int {
+	var opts queryOptions
+	flags := flag.NewFlagSet("stations", flag.ExitOnError)
+	opts.register(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable stations [QUERY] [-json]")
+		flags.PrintDefaults()
+	}
+	positional := parseInterspersed(flags, args)
+	if len(positional) > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	u, err := openIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stations: %v\n", err)
+		return 1
+	}
+	defer u.Close()
+	idx := u.Index()
+
+	stations := idx.Stations
+	if len(positional) == 1 {
+		stations = idx.SearchStations(positional[0])
+	}
+
+	if opts.json {
+		out := make([]jsonStation, len(stations))
+		for i, s := range stations {
+			out[i] = jsonStation{s.ID, s.Name}
+		}
+		return writeJSONOutput(out)
+	}
+	if len(stations) == 0 {
+		fmt.Println("No stations found.")
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, s := range stations {
+		fmt.Fprintf(tw, "%s\t%s\n", s.ID, s.Name)
+	}
+	tw.Flush()
+	return 0
+}
+
+func runTrip(args []string) int {
+	var opts queryOptions
+	flags := flag.NewFlagSet("trip", flag.ExitOnError)
+	opts.register(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable trip ID [-date YYYY-MM-DD] [-json]")
+		flags.PrintDefaults()
+	}
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		flags.Usage()
+		return 2
+	}
+	date, _, err := opts.when()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "trip: %v\n", err)
+		return 2
+	}
+
+	u, err := openIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "trip: %v\n", err)
+		return 1
+	}
+	defer u.Close()
+	idx := u.IndexForDate(date)
+
+	id := positional[0]
+	routeID, ok := idx.TripRoute[id]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "trip: unknown trip %q\n", id)
+		return 1
+	}
+	runs := search.ActiveServices(idx.Calendars, idx.CalendarDates, date)[idx.TripService[id]]
+	stops := idx.TripStops[id]
+
+	if opts.json {
+		type jsonStop struct {
+			StopID    string     `json:"stop_id"`
+			Name      string     `json:"name"`
+			Arrival   *time.Time `json:"arrival,omitempty"`
+			Departure *time.Time `json:"departure,omitempty"`
+		}
+		out := struct {
+			ID       string     `json:"id"`
+			Line     string     `json:"line"`
+			Headsign string     `json:"headsign"`
+			Date     string     `json:"date"`
+			Runs     bool       `json:"runs"`
+			Stops    []jsonStop `json:"stops"`
+		}{
+			ID:       id,
+			Line:     idx.RouteShortName[routeID],
+			Headsign: idx.TripHeadsign[id],
+			Date:     date.Format("2006-01-02"),
+			Runs:     runs,
+			Stops:    make([]jsonStop, len(stops)),
+		}
+		for i, ts := range stops {
+			out.Stops[i] = jsonStop{
+				StopID:    ts.StopID,
+				Name:      idx.StopName[ts.StopID],
+				Arrival:   search.ResolveStopTime(date, ts.ArrivalTime),
+				Departure: search.ResolveStopTime(date, ts.DepartureTime),
+			}
+		}
+		return writeJSONOutput(out)
+	}
+
+	fmt.Printf("Line %s → %s, %s", idx.RouteShortName[routeID], idx.TripHeadsign[id], date.Format("2.1.2006"))
+	if !runs {
+		fmt.Print(" (does not run on this day)")
+	}
+	fmt.Print("\n\n")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STOP\tARRIVES\tDEPARTS")
+	for _, ts := range stops {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", idx.StopName[ts.StopID],
+			search.FormatTime(ts.ArrivalTime), search.FormatTime(ts.DepartureTime))
+	}
+	tw.Flush()
+	return 0
+}
+
+func writeJSONOutput(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"timetable/internal/search"
+	"timetable/internal/tui"
+)
+
+func runTUI(args []string) int {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	refresh := flags.Duration("refresh", 5*time.Second, "time between updates of the board")
+	window := flags.Int("window", 90, "minutes to look ahead")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable tui [-refresh 5s] [-window minutes] STATION...")
+		flags.PrintDefaults()
+	}
+	positional := parseInterspersed(flags, args)
+	if len(positional) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	u, err := openIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tui: %v\n", err)
+		return 1
+	}
+	defer u.Close()
+
+	stations := make([]search.Station, len(positional))
+	for i, q := range positional {
+		if stations[i], err = resolveStation(u.Index(), q); err != nil {
+			fmt.Fprintf(os.Stderr, "tui: %v\n", err)
+			return 1
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = tui.Run(ctx, tui.Config{
+		Stations: stations,
+		Index:    u.IndexForDate,
+		Refresh:  *refresh,
+		Window:   *window,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tui: %v\n", err)
+		return 1
+	}
+	return 0
+}
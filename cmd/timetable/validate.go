@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/validate"
+)
+
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	jsonOut := flags.Bool("json", false, "print the report as JSON")
+	maxSpeed := flags.Float64("max-speed", validate.DefaultOptions().MaxSpeedKmh, "highest plausible speed between stops in km/h")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: timetable validate [-json] [-max-speed km/h] <dir|zip>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	feedFS, closer, err := openFeed(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+		return 2
+	}
+	defer closer.Close()
+
+	feed, diags, err := gtfs.ParseFS(feedFS, gtfs.ParseOptions{Strict: true, MaxErrors: math.MaxInt})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+		return 2
+	}
+
+	opts := validate.DefaultOptions()
+	opts.MaxSpeedKmh = *maxSpeed
+	report := validate.Feed(feed, opts)
+	report.AddDiagnostics(diags)
+
+	if *jsonOut {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+		return 2
+	}
+	if report.Errors() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// openFeed opens a GTFS feed stored either as a directory or a zip archive.
+func openFeed(path string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(path), io.NopCloser(nil), nil
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return nil, nil, fmt.Errorf("%s: expected a directory or .zip file", path)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zr, zr, nil
+}
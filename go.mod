@@ -3,6 +3,9 @@ module timetable
 go 1.24.0
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
+	golang.org/x/image v0.32.0
+	golang.org/x/term v0.36.0
 	golang.org/x/text v0.34.0
 	modernc.org/sqlite v1.45.0
 )
@@ -0,0 +1,359 @@
+// Package diff compares two GTFS feeds and summarises what changed for
+// passengers: stops and routes, trip times, headsigns and how often each
+// station is served.
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"timetable/internal/gtfs"
+)
+
+// Day types departures are counted for.
+const (
+	Weekday  = "weekday"
+	Saturday = "saturday"
+	Sunday   = "sunday"
+)
+
+var dayTypes = []string{Weekday, Saturday, Sunday}
+
+type Options struct {
+	// Lines limits trip, line and station comparisons to these route short
+	// names. All lines are compared if empty.
+	Lines []string
+}
+
+type StopRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type RouteRef struct {
+	ID        string `json:"id"`
+	ShortName string `json:"short_name"`
+	LongName  string `json:"long_name,omitempty"`
+}
+
+// TripChange is a trip present in both feeds whose times differ. Times are
+// seconds since midnight at the trip's first stop; MaxShift is the largest
+// difference at any stop.
+type TripChange struct {
+	Direction    int    `json:"direction"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	OldDeparture int    `json:"old_departure"`
+	NewDeparture int    `json:"new_departure"`
+	MaxShift     int    `json:"max_shift"`
+	OldTripID    string `json:"old_trip_id"`
+	NewTripID    string `json:"new_trip_id"`
+}
+
+// DayChange is a trip present in both feeds that runs on different days
+// of the week. Departure is the new time at the trip's first stop.
+type DayChange struct {
+	Direction int    `json:"direction"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Departure int    `json:"departure"`
+	OldDays   Days   `json:"old_days"`
+	NewDays   Days   `json:"new_days"`
+	OldTripID string `json:"old_trip_id"`
+	NewTripID string `json:"new_trip_id"`
+}
+
+// HeadsignChange counts trips in one direction whose headsign changed.
+type HeadsignChange struct {
+	Direction int    `json:"direction"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Trips     int    `json:"trips"`
+}
+
+// LineSummary describes the changes to one line. Added and Removed count
+// trips without a counterpart in the other feed.
+type LineSummary struct {
+	Line      string           `json:"line"`
+	OldTrips  int              `json:"old_trips"`
+	NewTrips  int              `json:"new_trips"`
+	Added     int              `json:"added"`
+	Removed   int              `json:"removed"`
+	Shifted   []TripChange     `json:"shifted,omitempty"`
+	Days      []DayChange      `json:"days,omitempty"`
+	Headsigns []HeadsignChange `json:"headsigns,omitempty"`
+}
+
+func (l LineSummary) Changed() bool {
+	return l.Added > 0 || l.Removed > 0 || len(l.Shifted) > 0 || len(l.Days) > 0 || len(l.Headsigns) > 0
+}
+
+// StationChange is a change in the number of departures from a station on
+// one day type.
+type StationChange struct {
+	Station string `json:"station"`
+	DayType string `json:"day_type"`
+	Old     int    `json:"old"`
+	New     int    `json:"new"`
+}
+
+type Report struct {
+	OldVersion    string          `json:"old_version"`
+	NewVersion    string          `json:"new_version"`
+	AddedStops    []StopRef       `json:"added_stops"`
+	RemovedStops  []StopRef       `json:"removed_stops"`
+	AddedRoutes   []RouteRef      `json:"added_routes"`
+	RemovedRoutes []RouteRef      `json:"removed_routes"`
+	Lines         []LineSummary   `json:"lines"`
+	Stations      []StationChange `json:"stations"`
+}
+
+// Feeds compares the old feed with the new one.
+func Feeds(old, new *gtfs.Feed, opts Options) *Report {
+	a, b := load(old, opts), load(new, opts)
+	r := &Report{
+		OldVersion: version(old),
+		NewVersion: version(new),
+	}
+	r.AddedStops, r.RemovedStops = compareStops(old.Stops, new.Stops)
+	r.AddedRoutes, r.RemovedRoutes = compareRoutes(old.Routes, new.Routes)
+	r.Lines = compareLines(a, b)
+	r.Stations = compareStations(a, b)
+
+	r.AddedStops, r.RemovedStops = orEmpty(r.AddedStops), orEmpty(r.RemovedStops)
+	r.AddedRoutes, r.RemovedRoutes = orEmpty(r.AddedRoutes), orEmpty(r.RemovedRoutes)
+	r.Stations = orEmpty(r.Stations)
+	return r
+}
+
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+func version(f *gtfs.Feed) string {
+	if f.FeedInfo == nil {
+		return ""
+	}
+	return f.FeedInfo.Version
+}
+
+func compareStops(old, new []gtfs.Stop) (added, removed []StopRef) {
+	ids := func(stops []gtfs.Stop) map[string]bool {
+		m := make(map[string]bool, len(stops))
+		for _, s := range stops {
+			m[s.ID] = true
+		}
+		return m
+	}
+	oldIDs, newIDs := ids(old), ids(new)
+	for _, s := range new {
+		if !oldIDs[s.ID] {
+			added = append(added, StopRef{s.ID, s.Name})
+		}
+	}
+	for _, s := range old {
+		if !newIDs[s.ID] {
+			removed = append(removed, StopRef{s.ID, s.Name})
+		}
+	}
+	return added, removed
+}
+
+func compareRoutes(old, new []gtfs.Route) (added, removed []RouteRef) {
+	names := func(routes []gtfs.Route) map[string]bool {
+		m := make(map[string]bool, len(routes))
+		for _, r := range routes {
+			m[lineName(r)] = true
+		}
+		return m
+	}
+	oldNames, newNames := names(old), names(new)
+	for _, r := range new {
+		if !oldNames[lineName(r)] {
+			added = append(added, RouteRef{r.ID, r.ShortName, r.LongName})
+		}
+	}
+	for _, r := range old {
+		if !newNames[lineName(r)] {
+			removed = append(removed, RouteRef{r.ID, r.ShortName, r.LongName})
+		}
+	}
+	return added, removed
+}
+
+// lineName identifies a route across feeds by its public name, since route
+// IDs are not guaranteed to be stable.
+func lineName(r gtfs.Route) string {
+	if r.ShortName != "" {
+		return r.ShortName
+	}
+	return r.ID
+}
+
+// trip is a trip reduced to what identifies it for passengers.
+type trip struct {
+	id        string
+	line      string
+	direction int
+	headsign  string
+	days      Days
+	stops     []string
+	times     []int
+}
+
+// key groups trips that serve the same stops in the same direction, so they
+// can be matched by time instead of by trip ID. Operating days are left out
+// so that a trip gaining or losing a day is reported as a day change rather
+// than as one trip removed and another added.
+func (t *trip) key() string {
+	return fmt.Sprintf("%s|%d|%s", t.line, t.direction, strings.Join(t.stops, ","))
+}
+
+type feedTrips struct {
+	trips       []*trip
+	stationName map[string]string // stop ID -> name of its station
+}
+
+func load(f *gtfs.Feed, opts Options) *feedTrips {
+	lines := make(map[string]bool, len(opts.Lines))
+	for _, l := range opts.Lines {
+		lines[l] = true
+	}
+
+	routes := make(map[string]string, len(f.Routes))
+	for _, r := range f.Routes {
+		routes[r.ID] = lineName(r)
+	}
+
+	stops := make(map[string]gtfs.Stop, len(f.Stops))
+	for _, s := range f.Stops {
+		stops[s.ID] = s
+	}
+	ft := &feedTrips{stationName: make(map[string]string, len(f.Stops))}
+	for _, s := range f.Stops {
+		name := s.Name
+		if p, ok := stops[s.ParentStation]; ok {
+			name = p.Name
+		}
+		ft.stationName[s.ID] = name
+	}
+
+	days := serviceDays(f)
+	byID := make(map[string]*trip, len(f.Trips))
+	for _, t := range f.Trips {
+		line := routes[t.RouteID]
+		if len(lines) > 0 && !lines[line] {
+			continue
+		}
+		tr := &trip{id: t.TripID, line: line, direction: t.DirectionID, headsign: t.Headsign, days: days[t.ServiceID]}
+		byID[t.TripID] = tr
+		ft.trips = append(ft.trips, tr)
+	}
+
+	stopTimes := make(map[string][]gtfs.StopTime)
+	for _, st := range f.StopTimes {
+		if byID[st.TripID] != nil {
+			stopTimes[st.TripID] = append(stopTimes[st.TripID], st)
+		}
+	}
+	for id, sts := range stopTimes {
+		sort.Slice(sts, func(i, j int) bool { return sts[i].StopSequence < sts[j].StopSequence })
+		tr := byID[id]
+		for _, st := range sts {
+			tr.stops = append(tr.stops, st.StopID)
+			tr.times = append(tr.times, st.DepartureTime)
+		}
+	}
+	return ft
+}
+
+// serviceDays returns the weekdays each service runs on, taken from
+// calendar.txt or, for services defined only by calendar_dates.txt, from the
+// dates added.
+func serviceDays(f *gtfs.Feed) map[string]Days {
+	days := make(map[string]Days)
+	for _, c := range f.Calendars {
+		var d Days
+		for i, on := range []bool{c.Sunday, c.Monday, c.Tuesday, c.Wednesday, c.Thursday, c.Friday, c.Saturday} {
+			if on {
+				d |= 1 << i
+			}
+		}
+		days[c.ServiceID] = d
+	}
+	fromDates := make(map[string]Days)
+	for _, cd := range f.CalendarDates {
+		if cd.ExceptionType != 1 {
+			continue
+		}
+		if t, err := time.Parse("20060102", cd.Date); err == nil {
+			fromDates[cd.ServiceID] |= 1 << t.Weekday()
+		}
+	}
+	for id, d := range fromDates {
+		if _, ok := days[id]; !ok {
+			days[id] = d
+		}
+	}
+	return days
+}
+
+// dayTypesOf returns the day types a trip running on days counts for.
+func dayTypesOf(days Days) []string {
+	var types []string
+	if days&0b0111110 != 0 {
+		types = append(types, Weekday)
+	}
+	if days&(1<<time.Saturday) != 0 {
+		types = append(types, Saturday)
+	}
+	if days&(1<<time.Sunday) != 0 {
+		types = append(types, Sunday)
+	}
+	return types
+}
+
+// Days is a set of weekdays, with bit i set for time.Weekday(i).
+type Days uint8
+
+// String lists the days from Monday on, joining runs of three or more
+// days with a dash, e.g. "Mon-Fri,Sun".
+func (d Days) String() string {
+	return d.Format([7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}, "-", ",")
+}
+
+func (d Days) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Format lists the days like String with the given day names, indexed by
+// time.Weekday, and separators.
+func (d Days) Format(names [7]string, dash, sep string) string {
+	var parts []string
+	for i := 0; i < 7; {
+		if d&(1<<((i+1)%7)) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < 7 && d&(1<<((j+2)%7)) != 0 {
+			j++
+		}
+		first, last := names[(i+1)%7], names[(j+1)%7]
+		switch j - i {
+		case 0:
+			parts = append(parts, first)
+		case 1:
+			parts = append(parts, first, last)
+		default:
+			parts = append(parts, first+dash+last)
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, sep)
+}
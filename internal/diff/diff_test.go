@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	"timetable/internal/gtfs"
+)
+
+const (
+	weekdays = Days(0b0111110)
+	sat      = Days(1 << 6)
+	sun      = Days(1 << 0)
+)
+
+// testTrip describes a trip on line 1 by its operating days, stops and
+// departure times in minutes; -1 leaves a stop without a time.
+type testTrip struct {
+	days     Days
+	headsign string
+	stops    string
+	minutes  []int
+}
+
+func buildFeed(trips ...testTrip) *gtfs.Feed {
+	f := &gtfs.Feed{Routes: []gtfs.Route{{ID: "R1", ShortName: "1"}}}
+	for _, id := range []string{"A", "B", "C", "D"} {
+		f.Stops = append(f.Stops, gtfs.Stop{ID: id, Name: "Stop " + id})
+	}
+	services := make(map[Days]string)
+	for i, t := range trips {
+		service, ok := services[t.days]
+		if !ok {
+			service = fmt.Sprintf("S%d", len(services))
+			services[t.days] = service
+			f.Calendars = append(f.Calendars, gtfs.Calendar{
+				ServiceID: service,
+				Sunday:    t.days&sun != 0,
+				Monday:    t.days&(1<<1) != 0,
+				Tuesday:   t.days&(1<<2) != 0,
+				Wednesday: t.days&(1<<3) != 0,
+				Thursday:  t.days&(1<<4) != 0,
+				Friday:    t.days&(1<<5) != 0,
+				Saturday:  t.days&sat != 0,
+			})
+		}
+		id := fmt.Sprintf("T%d", i)
+		f.Trips = append(f.Trips, gtfs.Trip{RouteID: "R1", ServiceID: service, TripID: id, Headsign: t.headsign})
+		for j, stop := range t.stops {
+			at := gtfs.NoTime
+			if t.minutes[j] >= 0 {
+				at = t.minutes[j] * 60
+			}
+			f.StopTimes = append(f.StopTimes, gtfs.StopTime{TripID: id, StopID: string(stop), StopSequence: j + 1, ArrivalTime: at, DepartureTime: at})
+		}
+	}
+	return f
+}
+
+func TestFeedsLines(t *testing.T) {
+	morning := testTrip{days: weekdays, headsign: "D", stops: "ABCD", minutes: []int{480, 485, 490, 495}}
+	saturday := testTrip{days: sat, headsign: "D", stops: "ABCD", minutes: []int{480, 485, 490, 495}}
+	with := func(t testTrip, edit func(*testTrip)) testTrip {
+		t.minutes = append([]int(nil), t.minutes...)
+		edit(&t)
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		old, new []testTrip
+		want     LineSummary
+	}{
+		{
+			name: "unchanged",
+			old:  []testTrip{morning, saturday},
+			new:  []testTrip{morning, saturday},
+			want: LineSummary{OldTrips: 2, NewTrips: 2},
+		},
+		{
+			name: "trip gains Saturday",
+			old:  []testTrip{morning},
+			new:  []testTrip{with(morning, func(t *testTrip) { t.days |= sat })},
+			want: LineSummary{OldTrips: 1, NewTrips: 1, Days: []DayChange{{
+				From: "Stop A", To: "Stop D", Departure: 480 * 60, OldDays: weekdays, NewDays: weekdays | sat, OldTripID: "T0", NewTripID: "T0",
+			}}},
+		},
+		{
+			name: "same-time trips are paired by their days",
+			old:  []testTrip{morning, saturday},
+			new:  []testTrip{with(saturday, func(t *testTrip) { t.days = sat | sun }), morning},
+			want: LineSummary{OldTrips: 2, NewTrips: 2, Days: []DayChange{{
+				From: "Stop A", To: "Stop D", Departure: 480 * 60, OldDays: sat, NewDays: sat | sun, OldTripID: "T1", NewTripID: "T0",
+			}}},
+		},
+		{
+			name: "shifted",
+			old:  []testTrip{morning},
+			new:  []testTrip{with(morning, func(t *testTrip) { t.minutes[2] += 3; t.minutes[3] += 3 })},
+			want: LineSummary{OldTrips: 1, NewTrips: 1, Shifted: []TripChange{{
+				From: "Stop A", To: "Stop D", OldDeparture: 480 * 60, NewDeparture: 480 * 60, MaxShift: 180, OldTripID: "T0", NewTripID: "T0",
+			}}},
+		},
+		{
+			name: "moved beyond the match tolerance",
+			old:  []testTrip{morning},
+			new:  []testTrip{with(morning, func(t *testTrip) { t.minutes = []int{540, 545, 550, 555} })},
+			want: LineSummary{OldTrips: 1, NewTrips: 1, Added: 1, Removed: 1},
+		},
+		{
+			name: "different stop pattern",
+			old:  []testTrip{morning},
+			new:  []testTrip{with(morning, func(t *testTrip) { t.stops = "ABD"; t.minutes = []int{480, 485, 495} })},
+			want: LineSummary{OldTrips: 1, NewTrips: 1, Added: 1, Removed: 1},
+		},
+		{
+			name: "stop without a time",
+			old:  []testTrip{morning},
+			new:  []testTrip{with(morning, func(t *testTrip) { t.minutes[1] = -1 })},
+			want: LineSummary{OldTrips: 1, NewTrips: 1},
+		},
+		{
+			name: "headsign",
+			old:  []testTrip{morning, with(morning, func(t *testTrip) { t.minutes = []int{600, 605, 610, 615} })},
+			new: []testTrip{
+				with(morning, func(t *testTrip) { t.headsign = "Depot" }),
+				with(morning, func(t *testTrip) { t.headsign = "Depot"; t.minutes = []int{600, 605, 610, 615} }),
+			},
+			want: LineSummary{OldTrips: 2, NewTrips: 2, Headsigns: []HeadsignChange{{Old: "D", New: "Depot", Trips: 2}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Feeds(buildFeed(tt.old...), buildFeed(tt.new...), Options{})
+			if len(r.Lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(r.Lines))
+			}
+			got, want := r.Lines[0], tt.want
+			want.Line = "1"
+			if s1, s2 := fmt.Sprintf("%+v", got), fmt.Sprintf("%+v", want); s1 != s2 {
+				t.Errorf("line summary\n got %s\nwant %s", s1, s2)
+			}
+			if got.Changed() != (len(tt.want.Days)+len(tt.want.Shifted)+len(tt.want.Headsigns)+tt.want.Added+tt.want.Removed > 0) {
+				t.Errorf("Changed() = %v", got.Changed())
+			}
+		})
+	}
+}
+
+func TestDaysString(t *testing.T) {
+	tests := []struct {
+		days Days
+		want string
+	}{
+		{0, ""},
+		{weekdays, "Mon-Fri"},
+		{weekdays | sat | sun, "Mon-Sun"},
+		{sat | sun, "Sat,Sun"},
+		{weekdays | sun, "Mon-Fri,Sun"},
+		{Days(1<<1 | 1<<3 | 1<<4 | 1<<5), "Mon,Wed-Fri"},
+		{sun, "Sun"},
+	}
+	for _, tt := range tests {
+		if got := tt.days.String(); got != tt.want {
+			t.Errorf("Days(%07b).String() = %q, want %q", uint8(tt.days), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package diff
+
+import (
+	"math/bits"
+	"sort"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+// matchTolerance is the largest departure shift for which a trip is still
+// considered the same trip rather than one removed and another added.
+const matchTolerance = 30 * 60
+
+func compareLines(a, b *feedTrips) []LineSummary {
+	lines := make(map[string]*LineSummary)
+	line := func(name string) *LineSummary {
+		l, ok := lines[name]
+		if !ok {
+			l = &LineSummary{Line: name}
+			lines[name] = l
+		}
+		return l
+	}
+
+	oldGroups, newGroups := groupTrips(a.trips), groupTrips(b.trips)
+	keys := make(map[string]bool, len(oldGroups)+len(newGroups))
+	for k := range oldGroups {
+		keys[k] = true
+	}
+	for k := range newGroups {
+		keys[k] = true
+	}
+
+	headsigns := make(map[string]map[HeadsignChange]int)
+	for k := range keys {
+		olds, news := oldGroups[k], newGroups[k]
+		var l *LineSummary
+		if len(olds) > 0 {
+			l = line(olds[0].line)
+		} else {
+			l = line(news[0].line)
+		}
+		l.OldTrips += len(olds)
+		l.NewTrips += len(news)
+
+		pairs, removed, added := matchTrips(olds, news)
+		l.Removed += removed
+		l.Added += added
+		for _, p := range pairs {
+			o, n := p[0], p[1]
+			if shift := maxShift(o.times, n.times); shift != 0 {
+				l.Shifted = append(l.Shifted, TripChange{
+					Direction:    n.direction,
+					From:         b.stationName[n.stops[0]],
+					To:           b.stationName[n.stops[len(n.stops)-1]],
+					OldDeparture: o.times[0],
+					NewDeparture: n.times[0],
+					MaxShift:     shift,
+					OldTripID:    o.id,
+					NewTripID:    n.id,
+				})
+			}
+			if o.days != n.days {
+				l.Days = append(l.Days, DayChange{
+					Direction: n.direction,
+					From:      b.stationName[n.stops[0]],
+					To:        b.stationName[n.stops[len(n.stops)-1]],
+					Departure: n.times[0],
+					OldDays:   o.days,
+					NewDays:   n.days,
+					OldTripID: o.id,
+					NewTripID: n.id,
+				})
+			}
+			if o.headsign != n.headsign {
+				if headsigns[l.Line] == nil {
+					headsigns[l.Line] = make(map[HeadsignChange]int)
+				}
+				headsigns[l.Line][HeadsignChange{Direction: n.direction, Old: o.headsign, New: n.headsign}]++
+			}
+		}
+	}
+
+	summaries := make([]LineSummary, 0, len(lines))
+	for _, l := range lines {
+		for hc, n := range headsigns[l.Line] {
+			hc.Trips = n
+			l.Headsigns = append(l.Headsigns, hc)
+		}
+		sort.Slice(l.Headsigns, func(i, j int) bool {
+			x, y := l.Headsigns[i], l.Headsigns[j]
+			if x.Direction != y.Direction {
+				return x.Direction < y.Direction
+			}
+			return x.Old+x.New < y.Old+y.New
+		})
+		sort.Slice(l.Shifted, func(i, j int) bool {
+			x, y := l.Shifted[i], l.Shifted[j]
+			if x.Direction != y.Direction {
+				return x.Direction < y.Direction
+			}
+			return x.OldDeparture < y.OldDeparture
+		})
+		sort.Slice(l.Days, func(i, j int) bool {
+			x, y := l.Days[i], l.Days[j]
+			if x.Direction != y.Direction {
+				return x.Direction < y.Direction
+			}
+			return x.Departure < y.Departure
+		})
+		summaries = append(summaries, *l)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return search.LineLess(summaries[i].Line, summaries[j].Line)
+	})
+	return summaries
+}
+
+// groupTrips groups trips by key, each group sorted by departure.
+func groupTrips(trips []*trip) map[string][]*trip {
+	groups := make(map[string][]*trip)
+	for _, t := range trips {
+		if len(t.stops) == 0 {
+			continue
+		}
+		k := t.key()
+		groups[k] = append(groups[k], t)
+	}
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool { return g[i].times[0] < g[j].times[0] })
+	}
+	return groups
+}
+
+// matchTrips pairs trips of the same group, closest departures first and,
+// among equally close ones, those running on the most similar days. It
+// counts the trips left over on either side.
+func matchTrips(olds, news []*trip) (pairs [][2]*trip, removed, added int) {
+	type candidate struct{ i, j, d, days int }
+	var cands []candidate
+	for i, o := range olds {
+		for j, n := range news {
+			if d := abs(n.times[0] - o.times[0]); d <= matchTolerance {
+				cands = append(cands, candidate{i, j, d, bits.OnesCount8(uint8(o.days ^ n.days))})
+			}
+		}
+	}
+	sort.SliceStable(cands, func(x, y int) bool {
+		if cands[x].d != cands[y].d {
+			return cands[x].d < cands[y].d
+		}
+		return cands[x].days < cands[y].days
+	})
+
+	usedOld := make([]bool, len(olds))
+	usedNew := make([]bool, len(news))
+	for _, c := range cands {
+		if usedOld[c.i] || usedNew[c.j] {
+			continue
+		}
+		usedOld[c.i], usedNew[c.j] = true, true
+		pairs = append(pairs, [2]*trip{olds[c.i], news[c.j]})
+	}
+	return pairs, len(olds) - len(pairs), len(news) - len(pairs)
+}
+
+// maxShift skips stops without a time in either feed.
+func maxShift(old, new []int) int {
+	shift := 0
+	for i := range old {
+		if old[i] == gtfs.NoTime || new[i] == gtfs.NoTime {
+			continue
+		}
+		if d := new[i] - old[i]; abs(d) > abs(shift) {
+			shift = d
+		}
+	}
+	return shift
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func compareStations(a, b *feedTrips) []StationChange {
+	oldCounts, newCounts := departureCounts(a), departureCounts(b)
+	stations := make(map[string]bool, len(oldCounts)+len(newCounts))
+	for s := range oldCounts {
+		stations[s] = true
+	}
+	for s := range newCounts {
+		stations[s] = true
+	}
+	names := make([]string, 0, len(stations))
+	for s := range stations {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+
+	var changes []StationChange
+	for _, s := range names {
+		for _, dt := range dayTypes {
+			o, n := oldCounts[s][dt], newCounts[s][dt]
+			if o != n {
+				changes = append(changes, StationChange{Station: s, DayType: dt, Old: o, New: n})
+			}
+		}
+	}
+	return changes
+}
+
+// departureCounts counts departures per station name and day type. The last
+// stop of a trip is an arrival only and not counted.
+func departureCounts(ft *feedTrips) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for _, t := range ft.trips {
+		types := dayTypesOf(t.days)
+		for i := 0; i+1 < len(t.stops); i++ {
+			name := ft.stationName[t.stops[i]]
+			if counts[name] == nil {
+				counts[name] = make(map[string]int)
+			}
+			for _, dt := range types {
+				counts[name][dt]++
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"timetable/internal/search"
+)
+
+// maxTextExamples limits how many shifted trips and day changes per line
+// WriteText lists.
+const maxTextExamples = 10
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ChangedLines returns the lines with added, removed, shifted or renamed
+// trips, or trips running on other days.
+func (r *Report) ChangedLines() []LineSummary {
+	var changed []LineSummary
+	for _, l := range r.Lines {
+		if l.Changed() {
+			changed = append(changed, l)
+		}
+	}
+	return changed
+}
+
+// WriteText prints a human-readable summary of the report.
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Feed %s -> %s\n", orUnknown(r.OldVersion), orUnknown(r.NewVersion))
+
+	writeStops(w, "Stops added", r.AddedStops)
+	writeStops(w, "Stops removed", r.RemovedStops)
+	writeRoutes(w, "Routes added", r.AddedRoutes)
+	writeRoutes(w, "Routes removed", r.RemovedRoutes)
+
+	changed := r.ChangedLines()
+	fmt.Fprintf(w, "\nLines changed (%d of %d)\n", len(changed), len(r.Lines))
+	for _, l := range changed {
+		fmt.Fprintf(w, "  %s: %d -> %d trips, %d added, %d removed, %d shifted, %d on other days\n",
+			l.Line, l.OldTrips, l.NewTrips, l.Added, l.Removed, len(l.Shifted), len(l.Days))
+		for _, h := range l.Headsigns {
+			fmt.Fprintf(w, "    direction %d headsign %q -> %q (%d trips)\n", h.Direction, h.Old, h.New, h.Trips)
+		}
+		for i, t := range l.Shifted {
+			if i == maxTextExamples {
+				fmt.Fprintf(w, "    ... and %d more\n", len(l.Shifted)-i)
+				break
+			}
+			fmt.Fprintf(w, "    %s -> %s %s -> %s (max %s)\n",
+				search.FormatTime(t.OldDeparture), search.FormatTime(t.NewDeparture), t.From, t.To, FormatShift(t.MaxShift))
+		}
+		for i, t := range l.Days {
+			if i == maxTextExamples {
+				fmt.Fprintf(w, "    ... and %d more\n", len(l.Days)-i)
+				break
+			}
+			fmt.Fprintf(w, "    %s %s -> %s runs %s, was %s\n",
+				search.FormatTime(t.Departure), t.From, t.To, t.NewDays, t.OldDays)
+		}
+	}
+
+	fmt.Fprintf(w, "\nDepartures per station changed (%d)\n", len(r.Stations))
+	for _, s := range r.Stations {
+		fmt.Fprintf(w, "  %s, %s: %d -> %d\n", s.Station, s.DayType, s.Old, s.New)
+	}
+	return nil
+}
+
+func writeStops(w io.Writer, title string, stops []StopRef) {
+	if len(stops) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s (%d)\n", title, len(stops))
+	for _, s := range stops {
+		fmt.Fprintf(w, "  %s %s\n", s.ID, s.Name)
+	}
+}
+
+func writeRoutes(w io.Writer, title string, routes []RouteRef) {
+	if len(routes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s (%d)\n", title, len(routes))
+	for _, r := range routes {
+		fmt.Fprintf(w, "  %s %s\n", r.ShortName, r.LongName)
+	}
+}
+
+// FormatShift formats a time difference in seconds as signed minutes.
+func FormatShift(seconds int) string {
+	return fmt.Sprintf("%+d min", seconds/60)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "(unknown)"
+	}
+	return s
+}
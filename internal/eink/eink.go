@@ -0,0 +1,204 @@
+// Package eink renders departure boards as images for e-paper displays.
+package eink
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Board is what a board image shows.
+type Board struct {
+	Title  string
+	Clock  string // shown at the right of the header, e.g. "14:05"
+	Rows   []Row
+	Empty  string // shown instead of the rows when there are none
+	Footer string
+}
+
+// Row is one departure or connection.
+type Row struct {
+	Line        string
+	Destination string
+	Time        string
+	Minutes     int
+}
+
+// Options sets the image size and whether it is dithered to pure black and
+// white instead of greyscale.
+type Options struct {
+	Width, Height int
+	Dither        bool
+}
+
+var (
+	regular = mustParse(goregular.TTF)
+	bold    = mustParse(gobold.TTF)
+)
+
+func mustParse(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// canvas draws text and boxes scaled from a 800×480 reference layout.
+type canvas struct {
+	img   *image.Gray
+	scale float64
+	faces map[faceKey]font.Face
+}
+
+type faceKey struct {
+	bold bool
+	size float64
+}
+
+func (c *canvas) px(v float64) int {
+	return int(v*c.scale + 0.5)
+}
+
+func (c *canvas) face(isBold bool, size float64) font.Face {
+	key := faceKey{isBold, size}
+	if f, ok := c.faces[key]; ok {
+		return f
+	}
+	f := regular
+	if isBold {
+		f = bold
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size * c.scale, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	c.faces[key] = face
+	return face
+}
+
+func (c *canvas) width(face font.Face, s string) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// text draws s with its baseline centred vertically in the band from top to
+// top+height, starting at x.
+func (c *canvas) text(face font.Face, s string, x, top, height int, col color.Gray) {
+	m := face.Metrics()
+	baseline := top + (height+m.Ascent.Ceil()-m.Descent.Ceil())/2
+	d := font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: face,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(s)
+}
+
+func (c *canvas) fill(r image.Rectangle, col color.Gray) {
+	draw.Draw(c.img, r, image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+// truncate shortens s with an ellipsis until it fits in width.
+func (c *canvas) truncate(face font.Face, s string, width int) string {
+	if c.width(face, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := string(runes) + "…"; c.width(face, t) <= width {
+			return t
+		}
+	}
+	return ""
+}
+
+// Render draws the board: a black header with the title and clock, as many
+// rows as fit with the line in an inverted box, the destination, the time
+// and a countdown, and a footer line.
+func Render(b Board, opt Options) image.Image {
+	w, h := opt.Width, opt.Height
+	c := &canvas{
+		img:   image.NewGray(image.Rect(0, 0, w, h)),
+		scale: min(float64(w)/800, float64(h)/480),
+		faces: make(map[faceKey]font.Face),
+	}
+	c.fill(c.img.Bounds(), color.Gray{Y: 0xff})
+	black, white := color.Gray{Y: 0}, color.Gray{Y: 0xff}
+	pad := c.px(16)
+
+	headerH := c.px(64)
+	c.fill(image.Rect(0, 0, w, headerH), black)
+	clockFace := c.face(true, 36)
+	clockW := c.width(clockFace, b.Clock)
+	c.text(clockFace, b.Clock, w-pad-clockW, 0, headerH, white)
+	titleFace := c.face(true, 34)
+	c.text(titleFace, c.truncate(titleFace, b.Title, w-3*pad-clockW), pad, 0, headerH, white)
+
+	footerH := c.px(32)
+	footerFace := c.face(false, 18)
+	c.fill(image.Rect(0, h-footerH, w, h-footerH+max(c.px(2), 1)), black)
+	c.text(footerFace, c.truncate(footerFace, b.Footer, w-2*pad), pad, h-footerH, footerH, black)
+
+	top, bottom := headerH, h-footerH
+	if len(b.Rows) == 0 {
+		face := c.face(false, 30)
+		s := c.truncate(face, b.Empty, w-2*pad)
+		c.text(face, s, (w-c.width(face, s))/2, top, bottom-top, black)
+		return finish(c.img, opt.Dither)
+	}
+
+	rowH := c.px(62)
+	lineFace, destFace, timeFace, countFace := c.face(true, 34), c.face(false, 32), c.face(false, 28), c.face(true, 34)
+	badgeW := c.px(76)
+	countW := c.width(countFace, "99 min")
+	timeW := 0
+	for _, r := range b.Rows {
+		badgeW = max(badgeW, c.width(lineFace, r.Line)+2*c.px(10))
+		timeW = max(timeW, c.width(timeFace, r.Time))
+	}
+	countX := w - pad - countW
+	timeX := countX - pad - timeW
+	destX := pad + badgeW + pad
+
+	for i, r := range b.Rows {
+		y := top + i*rowH
+		if y+rowH > bottom {
+			break
+		}
+		if i > 0 {
+			c.fill(image.Rect(pad, y, w-pad, y+max(c.px(1), 1)), black)
+		}
+		inset := c.px(7)
+		c.fill(image.Rect(pad, y+inset, pad+badgeW, y+rowH-inset), black)
+		c.text(lineFace, r.Line, pad+(badgeW-c.width(lineFace, r.Line))/2, y, rowH, white)
+		c.text(destFace, c.truncate(destFace, r.Destination, timeX-pad-destX), destX, y, rowH, black)
+		c.text(timeFace, r.Time, timeX+timeW-c.width(timeFace, r.Time), y, rowH, black)
+
+		count := "<1 min"
+		if r.Minutes > 0 {
+			count = strconv.Itoa(r.Minutes) + " min"
+		}
+		c.text(countFace, count, w-pad-c.width(countFace, count), y, rowH, black)
+	}
+	return finish(c.img, opt.Dither)
+}
+
+// finish returns the greyscale image, or a two-colour version of it
+// dithered with Floyd–Steinberg for displays without grey levels.
+func finish(img *image.Gray, dither bool) image.Image {
+	if !dither {
+		return img
+	}
+	bw := image.NewPaletted(img.Bounds(), color.Palette{color.Black, color.White})
+	draw.FloydSteinberg.Draw(bw, img.Bounds(), img, image.Point{})
+	return bw
+}
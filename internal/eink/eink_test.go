@@ -0,0 +1,81 @@
+package eink
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testBoard(rows int) Board {
+	b := Board{
+		Title:  "Hlavní nádraží",
+		Clock:  "14:05",
+		Empty:  "Žádné odjezdy v příštích 60 minutách.",
+		Footer: "Aktualizováno v 14:05 · příštích 60 minut",
+	}
+	for i := range rows {
+		b.Rows = append(b.Rows, Row{
+			Line:        fmt.Sprint(i + 1),
+			Destination: "Sídliště Barrandov přes Smíchovské nádraží",
+			Time:        "14:1" + fmt.Sprint(i%10),
+			Minutes:     i,
+		})
+	}
+	return b
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          int
+		width, height int
+	}{
+		{"rows", 3, 400, 240},
+		{"no rows", 0, 400, 240},
+		{"more rows than fit", 40, 400, 240},
+		{"full size", 40, 800, 480},
+		{"tiny", 5, 20, 10},
+	}
+	for _, tt := range tests {
+		for _, dither := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/dither=%v", tt.name, dither), func(t *testing.T) {
+				img := Render(testBoard(tt.rows), Options{Width: tt.width, Height: tt.height, Dither: dither})
+				if want := image.Rect(0, 0, tt.width, tt.height); img.Bounds() != want {
+					t.Fatalf("bounds = %v, want %v", img.Bounds(), want)
+				}
+				colors := make(map[color.Gray]bool)
+				for y := range tt.height {
+					for x := range tt.width {
+						colors[color.GrayModel.Convert(img.At(x, y)).(color.Gray)] = true
+					}
+				}
+				if dither {
+					for c := range colors {
+						if c.Y != 0 && c.Y != 0xff {
+							t.Fatalf("dithered image has grey %d", c.Y)
+						}
+					}
+				}
+				if !colors[color.Gray{Y: 0}] || !colors[color.Gray{Y: 0xff}] {
+					t.Errorf("image is not drawn in black and white: %v", colors)
+				}
+			})
+		}
+	}
+}
+
+// TestRenderGreyscale checks that text is antialiased unless dithered.
+func TestRenderGreyscale(t *testing.T) {
+	img := Render(testBoard(3), Options{Width: 400, Height: 240})
+	if _, ok := img.(*image.Gray); !ok {
+		t.Fatalf("image is %T, want *image.Gray", img)
+	}
+	grey := false
+	for _, y := range img.(*image.Gray).Pix {
+		grey = grey || (y != 0 && y != 0xff)
+	}
+	if !grey {
+		t.Error("greyscale image has no grey levels")
+	}
+}
@@ -0,0 +1,74 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrTooManyErrors = errors.New("too many parse errors")
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic describes a single problem found while parsing a feed file.
+// Line is 1-based and counts the header row; Column is empty for
+// file-level problems.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   string   `json:"column,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Severity)
+	if d.Column != "" {
+		s += fmt.Sprintf(": %s", d.Column)
+	}
+	if d.Value != "" {
+		s += fmt.Sprintf(" %q", d.Value)
+	}
+	return s + ": " + d.Message
+}
+
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Errors() int {
+	n := 0
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func (ds Diagnostics) Warnings() int {
+	return len(ds) - ds.Errors()
+}
+
+// ParseOptions controls ParseFeedWithOptions. In strict mode every
+// unparsable value, malformed time or date and missing required column is
+// recorded as a Diagnostic, and parsing fails with ErrTooManyErrors once
+// the number of errors exceeds MaxErrors.
+type ParseOptions struct {
+	Strict    bool
+	MaxErrors int
+}
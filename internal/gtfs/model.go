@@ -1,22 +1,65 @@
 package gtfs
 
+import "sort"
+
+type Agency struct {
+	ID       string
+	Name     string
+	URL      string
+	Timezone string
+	Lang     string
+	Phone    string
+	FareURL  string
+	Email    string
+}
+
+type FeedInfo struct {
+	PublisherName string
+	PublisherURL  string
+	Lang          string
+	DefaultLang   string
+	StartDate     string
+	EndDate       string
+	Version       string
+	ContactEmail  string
+	ContactURL    string
+}
+
 type Stop struct {
 	ID                 string
 	Code               string
 	Name               string
+	Desc               string
 	Lat                float64
 	Lon                float64
+	ZoneID             string
+	URL                string
 	LocationType       int
 	ParentStation      string
+	Timezone           string
 	WheelchairBoarding int
+	LevelID            string
+	PlatformCode       string
 }
 
+// NoTime is the ArrivalTime and DepartureTime of a stop time whose times
+// are left empty, as GTFS allows for stops between timepoints. It is also
+// returned for times that fail to parse.
+const NoTime = -1
+
 type StopTime struct {
-	TripID        string
-	ArrivalTime   int
-	DepartureTime int
-	StopID        string
-	StopSequence  int
+	TripID            string
+	ArrivalTime       int
+	DepartureTime     int
+	StopID            string
+	StopSequence      int
+	StopHeadsign      string
+	PickupType        int
+	DropOffType       int
+	ContinuousPickup  int
+	ContinuousDropOff int
+	ShapeDistTraveled float64
+	Timepoint         int
 }
 
 type Trip struct {
@@ -24,17 +67,27 @@ type Trip struct {
 	ServiceID    string
 	TripID       string
 	Headsign     string
+	ShortName    string
 	DirectionID  int
+	BlockID      string
 	ShapeID      string
 	Wheelchair   int
+	BikesAllowed int
 }
 
 type Route struct {
-	ID        string
-	AgencyID  string
-	ShortName string
-	LongName  string
-	Type      int
+	ID                string
+	AgencyID          string
+	ShortName         string
+	LongName          string
+	Desc              string
+	Type              int
+	URL               string
+	Color             string
+	TextColor         string
+	SortOrder         int
+	ContinuousPickup  int
+	ContinuousDropOff int
 }
 
 type Calendar struct {
@@ -63,12 +116,138 @@ type Transfer struct {
 	MinTransferTime int
 }
 
+type ShapePoint struct {
+	ShapeID      string
+	Lat          float64
+	Lon          float64
+	Sequence     int
+	DistTraveled float64
+}
+
+type Frequency struct {
+	TripID      string
+	StartTime   int
+	EndTime     int
+	HeadwaySecs int
+	ExactTimes  int
+}
+
+type FareAttribute struct {
+	FareID           string
+	Price            float64
+	CurrencyType     string
+	PaymentMethod    int
+	Transfers        string
+	AgencyID         string
+	TransferDuration int
+}
+
+type FareRule struct {
+	FareID        string
+	RouteID       string
+	OriginID      string
+	DestinationID string
+	ContainsID    string
+}
+
+type Level struct {
+	ID    string
+	Index float64
+	Name  string
+}
+
+type Pathway struct {
+	ID                   string
+	FromStopID           string
+	ToStopID             string
+	Mode                 int
+	IsBidirectional      int
+	Length               float64
+	TraversalTime        int
+	StairCount           int
+	MaxSlope             float64
+	MinWidth             float64
+	SignpostedAs         string
+	ReversedSignpostedAs string
+}
+
 type Feed struct {
-	Stops         []Stop
-	StopTimes     []StopTime
-	Trips         []Trip
-	Routes        []Route
-	Calendars     []Calendar
-	CalendarDates []CalendarDate
-	Transfers     []Transfer
+	Agencies       []Agency
+	FeedInfo       *FeedInfo
+	Stops          []Stop
+	StopTimes      []StopTime
+	Trips          []Trip
+	Routes         []Route
+	Calendars      []Calendar
+	CalendarDates  []CalendarDate
+	Transfers      []Transfer
+	Shapes         []ShapePoint
+	Frequencies    []Frequency
+	FareAttributes []FareAttribute
+	FareRules      []FareRule
+	Levels         []Level
+	Pathways       []Pathway
+
+	// lines maps each parsed file to the lines its rows were read from.
+	lines map[string][]lineRun
+}
+
+// lineRun records that the row at index and the ones after it were read
+// from consecutive lines starting at line. A new run starts wherever a
+// quoted field spans lines or blank lines are skipped.
+type lineRun struct {
+	index, line int
+}
+
+// Line returns the line in file that the i-th entry of the corresponding
+// slice was parsed from, counting the header as line 1. It returns 0 if
+// the feed was not parsed from files, such as one loaded from the store.
+func (f *Feed) Line(file string, i int) int {
+	return lineOf(f.lines[file], i)
+}
+
+func lineOf(runs []lineRun, i int) int {
+	n := sort.Search(len(runs), func(j int) bool { return runs[j].index > i })
+	if n == 0 {
+		return 0
+	}
+	r := runs[n-1]
+	return r.line + i - r.index
+}
+
+// ServiceRange returns the first and last service days (YYYYMMDD) covered
+// by calendar.txt and dates added in calendar_dates.txt.
+func (f *Feed) ServiceRange() (start, end string) {
+	extend := func(from, to string) {
+		if from != "" && (start == "" || from < start) {
+			start = from
+		}
+		if to > end {
+			end = to
+		}
+	}
+	for _, c := range f.Calendars {
+		extend(c.StartDate, c.EndDate)
+	}
+	for _, cd := range f.CalendarDates {
+		if cd.ExceptionType == 1 {
+			extend(cd.Date, cd.Date)
+		}
+	}
+	return start, end
+}
+
+// ValidityRange returns the dates the feed declares itself valid for in
+// feed_info.txt, falling back to ServiceRange.
+func (f *Feed) ValidityRange() (start, end string) {
+	start, end = f.ServiceRange()
+	if f.FeedInfo != nil {
+		if f.FeedInfo.StartDate != "" {
+			start = f.FeedInfo.StartDate
+		}
+		if f.FeedInfo.EndDate != "" {
+			end = f.FeedInfo.EndDate
+		}
+	}
+	return start, end
 }
@@ -2,51 +2,126 @@ package gtfs
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func ParseFeed(dir string) (*Feed, error) {
-	feed := &Feed{}
+	feed, _, err := ParseFeedWithOptions(dir, ParseOptions{})
+	return feed, err
+}
+
+// ParseFeedWithOptions parses the feed in dir. The returned diagnostics are
+// only populated in strict mode; they are returned even when parsing fails
+// with ErrTooManyErrors so the caller can report them.
+func ParseFeedWithOptions(dir string, opts ParseOptions) (*Feed, Diagnostics, error) {
+	return ParseFS(os.DirFS(dir), opts)
+}
+
+// ParseFS parses a feed whose files sit at the root of fsys, such as an
+// extracted directory or an opened zip archive.
+func ParseFS(fsys fs.FS, opts ParseOptions) (*Feed, Diagnostics, error) {
+	p := &parser{fsys: fsys, opts: opts, lines: make(map[string][]lineRun)}
+	feed := &Feed{lines: p.lines}
 
 	var err error
-	if feed.Stops, err = parseStops(filepath.Join(dir, "stops.txt")); err != nil {
-		return nil, fmt.Errorf("stops: %w", err)
+	if feed.Agencies, err = p.parseAgencies(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("agency: %w", err)
+	}
+	if feed.FeedInfo, err = p.parseFeedInfo(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("feed_info: %w", err)
+	}
+	if feed.Stops, err = p.parseStops(); err != nil {
+		return nil, p.diags, fmt.Errorf("stops: %w", err)
+	}
+	if feed.Routes, err = p.parseRoutes(); err != nil {
+		return nil, p.diags, fmt.Errorf("routes: %w", err)
 	}
-	if feed.Routes, err = parseRoutes(filepath.Join(dir, "routes.txt")); err != nil {
-		return nil, fmt.Errorf("routes: %w", err)
+	if feed.Trips, err = p.parseTrips(); err != nil {
+		return nil, p.diags, fmt.Errorf("trips: %w", err)
 	}
-	if feed.Trips, err = parseTrips(filepath.Join(dir, "trips.txt")); err != nil {
-		return nil, fmt.Errorf("trips: %w", err)
+	if feed.Calendars, err = p.parseCalendars(); err != nil {
+		return nil, p.diags, fmt.Errorf("calendar: %w", err)
 	}
-	if feed.Calendars, err = parseCalendars(filepath.Join(dir, "calendar.txt")); err != nil {
-		return nil, fmt.Errorf("calendar: %w", err)
+	if feed.CalendarDates, err = p.parseCalendarDates(); err != nil {
+		return nil, p.diags, fmt.Errorf("calendar_dates: %w", err)
 	}
-	if feed.CalendarDates, err = parseCalendarDates(filepath.Join(dir, "calendar_dates.txt")); err != nil {
-		return nil, fmt.Errorf("calendar_dates: %w", err)
+	if feed.StopTimes, err = p.parseStopTimes(); err != nil {
+		return nil, p.diags, fmt.Errorf("stop_times: %w", err)
 	}
-	if feed.StopTimes, err = parseStopTimes(filepath.Join(dir, "stop_times.txt")); err != nil {
-		return nil, fmt.Errorf("stop_times: %w", err)
+	if feed.Transfers, err = p.parseTransfers(); err != nil {
+		return nil, p.diags, fmt.Errorf("transfers: %w", err)
 	}
-	if feed.Transfers, err = parseTransfers(filepath.Join(dir, "transfers.txt")); err != nil {
-		return nil, fmt.Errorf("transfers: %w", err)
+	if feed.Shapes, err = p.parseShapes(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("shapes: %w", err)
+	}
+	if feed.Frequencies, err = p.parseFrequencies(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("frequencies: %w", err)
+	}
+	if feed.FareAttributes, err = p.parseFareAttributes(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("fare_attributes: %w", err)
+	}
+	if feed.FareRules, err = p.parseFareRules(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("fare_rules: %w", err)
+	}
+	if feed.Levels, err = p.parseLevels(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("levels: %w", err)
+	}
+	if feed.Pathways, err = p.parsePathways(); err != nil && !isNotExist(err) {
+		return nil, p.diags, fmt.Errorf("pathways: %w", err)
 	}
 
-	return feed, nil
+	if opts.Strict {
+		if n := p.diags.Errors(); n > opts.MaxErrors {
+			return feed, p.diags, fmt.Errorf("%w: %d errors (max %d)", ErrTooManyErrors, n, opts.MaxErrors)
+		}
+	}
+	return feed, p.diags, nil
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+type parser struct {
+	fsys  fs.FS
+	opts  ParseOptions
+	diags Diagnostics
+	lines map[string][]lineRun
+}
+
+func (p *parser) report(d Diagnostic) {
+	if p.opts.Strict {
+		p.diags = append(p.diags, d)
+	}
+}
+
+// record is the current data row of a file being parsed. Its accessors are
+// lenient like the original parser (bad values become zero) but report a
+// diagnostic in strict mode.
+type record struct {
+	p    *parser
+	file string
+	line int
+	idx  map[string]int
+	row  []string
 }
 
-func openCSV(path string) (*csv.Reader, io.Closer, error) {
-	f, err := os.Open(path)
+func openCSV(fsys fs.FS, name string) (*csv.Reader, io.Closer, error) {
+	f, err := fsys.Open(name)
 	if err != nil {
 		return nil, nil, err
 	}
 	r := csv.NewReader(f)
 	r.LazyQuotes = true
 	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
 	return r, f, nil
 }
 
@@ -57,267 +132,471 @@ func readHeader(r *csv.Reader) (map[string]int, error) {
 	}
 	idx := make(map[string]int, len(row))
 	for i, col := range row {
-		idx[strings.TrimSpace(col)] = i
+		idx[strings.TrimPrefix(strings.TrimSpace(col), "\ufeff")] = i
 	}
 	return idx, nil
 }
 
-func col(row []string, idx map[string]int, name string) string {
-	i, ok := idx[name]
-	if !ok || i >= len(row) {
-		return ""
-	}
-	return strings.TrimSpace(row[i])
-}
-
-func colInt(row []string, idx map[string]int, name string) int {
-	v, _ := strconv.Atoi(col(row, idx, name))
-	return v
-}
-
-func colFloat(row []string, idx map[string]int, name string) float64 {
-	v, _ := strconv.ParseFloat(col(row, idx, name), 64)
-	return v
-}
-
-func ParseTimeToSeconds(s string) int {
-	parts := strings.Split(s, ":")
-	if len(parts) != 3 {
-		return 0
-	}
-	h, _ := strconv.Atoi(parts[0])
-	m, _ := strconv.Atoi(parts[1])
-	sec, _ := strconv.Atoi(parts[2])
-	return h*3600 + m*60 + sec
-}
-
-func parseStops(path string) ([]Stop, error) {
-	r, closer, err := openCSV(path)
+// forEachRow opens a GTFS CSV file and calls fn for every data row. Columns
+// in required must be present in the header and non-empty in every row.
+func (p *parser) forEachRow(file string, required []string, fn func(rec *record)) error {
+	r, closer, err := openCSV(p.fsys, file)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer closer.Close()
 
 	idx, err := readHeader(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var stops []Stop
-	for {
+	var present []string
+	for _, name := range required {
+		if _, ok := idx[name]; ok {
+			present = append(present, name)
+			continue
+		}
+		p.report(Diagnostic{File: file, Line: 1, Column: name, Severity: SeverityError, Message: "missing required column"})
+	}
+
+	rec := &record{p: p, file: file, idx: idx}
+	for n, next := 0, 0; ; n++ {
 		row, err := r.Read()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-		stops = append(stops, Stop{
-			ID:                 col(row, idx, "stop_id"),
-			Code:               col(row, idx, "stop_code"),
-			Name:               col(row, idx, "stop_name"),
-			Lat:                colFloat(row, idx, "stop_lat"),
-			Lon:                colFloat(row, idx, "stop_lon"),
-			LocationType:       colInt(row, idx, "location_type"),
-			ParentStation:      col(row, idx, "parent_station"),
-			WheelchairBoarding: colInt(row, idx, "wheelchair_boarding"),
-		})
+		rec.row = row
+		rec.line, _ = r.FieldPos(0)
+		if rec.line != next {
+			p.lines[file] = append(p.lines[file], lineRun{index: n, line: rec.line})
+		}
+		next = rec.line + 1
+		if len(row) != len(idx) {
+			rec.report("", "", SeverityWarning, fmt.Sprintf("row has %d fields, header has %d", len(row), len(idx)))
+		}
+		for _, name := range present {
+			if rec.str(name) == "" {
+				rec.report(name, "", SeverityError, "missing required value")
+			}
+		}
+		fn(rec)
 	}
-	return stops, nil
 }
 
-func parseRoutes(path string) ([]Route, error) {
-	r, closer, err := openCSV(path)
-	if err != nil {
-		return nil, err
-	}
-	defer closer.Close()
+func (rec *record) report(column, value string, sev Severity, msg string) {
+	rec.p.report(Diagnostic{File: rec.file, Line: rec.line, Column: column, Value: value, Severity: sev, Message: msg})
+}
 
-	idx, err := readHeader(r)
-	if err != nil {
-		return nil, err
+func (rec *record) str(name string) string {
+	i, ok := rec.idx[name]
+	if !ok || i >= len(rec.row) {
+		return ""
 	}
+	return strings.TrimSpace(rec.row[i])
+}
 
-	var routes []Route
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		routes = append(routes, Route{
-			ID:        col(row, idx, "route_id"),
-			AgencyID:  col(row, idx, "agency_id"),
-			ShortName: col(row, idx, "route_short_name"),
-			LongName:  col(row, idx, "route_long_name"),
-			Type:      colInt(row, idx, "route_type"),
-		})
-	}
-	return routes, nil
+func (rec *record) int(name string) int {
+	return rec.intDefault(name, 0)
 }
 
-func parseTrips(path string) ([]Trip, error) {
-	r, closer, err := openCSV(path)
+func (rec *record) intDefault(name string, def int) int {
+	s := rec.str(name)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
 	if err != nil {
-		return nil, err
+		rec.report(name, s, SeverityError, "invalid integer")
+		return def
 	}
-	defer closer.Close()
+	return v
+}
 
-	idx, err := readHeader(r)
+func (rec *record) float(name string) float64 {
+	s := rec.str(name)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		return nil, err
+		rec.report(name, s, SeverityError, "invalid number")
+		return 0
 	}
+	return v
+}
 
-	var trips []Trip
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		trips = append(trips, Trip{
-			RouteID:     col(row, idx, "route_id"),
-			ServiceID:   col(row, idx, "service_id"),
-			TripID:      col(row, idx, "trip_id"),
-			Headsign:    col(row, idx, "trip_headsign"),
-			DirectionID: colInt(row, idx, "direction_id"),
-			ShapeID:     col(row, idx, "shape_id"),
-			Wheelchair:  colInt(row, idx, "wheelchair_accessible"),
-		})
+func (rec *record) bool(name string) bool {
+	s := rec.str(name)
+	if s != "" && s != "0" && s != "1" {
+		rec.report(name, s, SeverityError, "expected 0 or 1")
 	}
-	return trips, nil
+	return s == "1"
 }
 
-func parseCalendars(path string) ([]Calendar, error) {
-	r, closer, err := openCSV(path)
+// time returns NoTime for an empty or unparsable value.
+func (rec *record) time(name string) int {
+	s := rec.str(name)
+	if s == "" {
+		return NoTime
+	}
+	v, err := ParseTime(s)
 	if err != nil {
-		return nil, err
+		rec.report(name, s, SeverityError, err.Error())
+		return NoTime
 	}
-	defer closer.Close()
+	return v
+}
 
-	idx, err := readHeader(r)
-	if err != nil {
-		return nil, err
+func (rec *record) date(name string) string {
+	s := rec.str(name)
+	if s == "" {
+		return ""
+	}
+	if _, err := time.Parse("20060102", s); err != nil {
+		rec.report(name, s, SeverityError, "invalid date, expected YYYYMMDD")
 	}
+	return s
+}
 
-	var cals []Calendar
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
+func (rec *record) enum(name string, def int, valid ...int) int {
+	v := rec.intDefault(name, def)
+	for _, ok := range valid {
+		if v == ok {
+			return v
 		}
-		if err != nil {
-			return nil, err
-		}
-		cals = append(cals, Calendar{
-			ServiceID: col(row, idx, "service_id"),
-			Monday:    col(row, idx, "monday") == "1",
-			Tuesday:   col(row, idx, "tuesday") == "1",
-			Wednesday: col(row, idx, "wednesday") == "1",
-			Thursday:  col(row, idx, "thursday") == "1",
-			Friday:    col(row, idx, "friday") == "1",
-			Saturday:  col(row, idx, "saturday") == "1",
-			Sunday:    col(row, idx, "sunday") == "1",
-			StartDate: col(row, idx, "start_date"),
-			EndDate:   col(row, idx, "end_date"),
-		})
 	}
-	return cals, nil
+	rec.report(name, rec.str(name), SeverityWarning, "unexpected value")
+	return v
 }
 
-func parseCalendarDates(path string) ([]CalendarDate, error) {
-	r, closer, err := openCSV(path)
-	if err != nil {
-		return nil, err
+// ParseTime parses a GTFS HH:MM:SS time, which may exceed 24:00:00 for trips
+// running past midnight, into seconds since the start of the service day.
+func ParseTime(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time, expected HH:MM:SS")
 	}
-	defer closer.Close()
-
-	idx, err := readHeader(r)
-	if err != nil {
-		return nil, err
+	h, errH := strconv.Atoi(parts[0])
+	m, errM := strconv.Atoi(parts[1])
+	sec, errS := strconv.Atoi(parts[2])
+	if errH != nil || errM != nil || errS != nil || h < 0 || m < 0 || m > 59 || sec < 0 || sec > 59 {
+		return 0, fmt.Errorf("invalid time, expected HH:MM:SS")
 	}
+	return h*3600 + m*60 + sec, nil
+}
 
-	var dates []CalendarDate
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
+func (p *parser) parseAgencies() ([]Agency, error) {
+	var agencies []Agency
+	err := p.forEachRow("agency.txt", []string{"agency_name", "agency_url", "agency_timezone"}, func(rec *record) {
+		agencies = append(agencies, Agency{
+			ID:       rec.str("agency_id"),
+			Name:     rec.str("agency_name"),
+			URL:      rec.str("agency_url"),
+			Timezone: rec.str("agency_timezone"),
+			Lang:     rec.str("agency_lang"),
+			Phone:    rec.str("agency_phone"),
+			FareURL:  rec.str("agency_fare_url"),
+			Email:    rec.str("agency_email"),
+		})
+	})
+	return agencies, err
+}
+
+func (p *parser) parseFeedInfo() (*FeedInfo, error) {
+	var info *FeedInfo
+	err := p.forEachRow("feed_info.txt", []string{"feed_publisher_name", "feed_publisher_url", "feed_lang"}, func(rec *record) {
+		if info != nil {
+			rec.report("", "", SeverityWarning, "feed_info.txt has more than one row")
+			return
 		}
-		if err != nil {
-			return nil, err
+		info = &FeedInfo{
+			PublisherName: rec.str("feed_publisher_name"),
+			PublisherURL:  rec.str("feed_publisher_url"),
+			Lang:          rec.str("feed_lang"),
+			DefaultLang:   rec.str("default_lang"),
+			StartDate:     rec.date("feed_start_date"),
+			EndDate:       rec.date("feed_end_date"),
+			Version:       rec.str("feed_version"),
+			ContactEmail:  rec.str("feed_contact_email"),
+			ContactURL:    rec.str("feed_contact_url"),
 		}
-		dates = append(dates, CalendarDate{
-			ServiceID:     col(row, idx, "service_id"),
-			Date:          col(row, idx, "date"),
-			ExceptionType: colInt(row, idx, "exception_type"),
+	})
+	return info, err
+}
+
+func (p *parser) parseStops() ([]Stop, error) {
+	var stops []Stop
+	err := p.forEachRow("stops.txt", []string{"stop_id"}, func(rec *record) {
+		stops = append(stops, Stop{
+			ID:                 rec.str("stop_id"),
+			Code:               rec.str("stop_code"),
+			Name:               rec.str("stop_name"),
+			Desc:               rec.str("stop_desc"),
+			Lat:                rec.float("stop_lat"),
+			Lon:                rec.float("stop_lon"),
+			ZoneID:             rec.str("zone_id"),
+			URL:                rec.str("stop_url"),
+			LocationType:       rec.enum("location_type", 0, 0, 1, 2, 3, 4),
+			ParentStation:      rec.str("parent_station"),
+			Timezone:           rec.str("stop_timezone"),
+			WheelchairBoarding: rec.enum("wheelchair_boarding", 0, 0, 1, 2),
+			LevelID:            rec.str("level_id"),
+			PlatformCode:       rec.str("platform_code"),
 		})
-	}
-	return dates, nil
+	})
+	return stops, err
 }
 
-func parseStopTimes(path string) ([]StopTime, error) {
-	r, closer, err := openCSV(path)
-	if err != nil {
-		return nil, err
-	}
-	defer closer.Close()
+func (p *parser) parseRoutes() ([]Route, error) {
+	var routes []Route
+	err := p.forEachRow("routes.txt", []string{"route_id", "route_type"}, func(rec *record) {
+		routes = append(routes, Route{
+			ID:                rec.str("route_id"),
+			AgencyID:          rec.str("agency_id"),
+			ShortName:         rec.str("route_short_name"),
+			LongName:          rec.str("route_long_name"),
+			Desc:              rec.str("route_desc"),
+			Type:              rec.int("route_type"),
+			URL:               rec.str("route_url"),
+			Color:             rec.str("route_color"),
+			TextColor:         rec.str("route_text_color"),
+			SortOrder:         rec.int("route_sort_order"),
+			ContinuousPickup:  rec.enum("continuous_pickup", 1, 0, 1, 2, 3),
+			ContinuousDropOff: rec.enum("continuous_drop_off", 1, 0, 1, 2, 3),
+		})
+	})
+	return routes, err
+}
 
-	idx, err := readHeader(r)
-	if err != nil {
-		return nil, err
-	}
+func (p *parser) parseTrips() ([]Trip, error) {
+	var trips []Trip
+	err := p.forEachRow("trips.txt", []string{"route_id", "service_id", "trip_id"}, func(rec *record) {
+		trips = append(trips, Trip{
+			RouteID:      rec.str("route_id"),
+			ServiceID:    rec.str("service_id"),
+			TripID:       rec.str("trip_id"),
+			Headsign:     rec.str("trip_headsign"),
+			ShortName:    rec.str("trip_short_name"),
+			DirectionID:  rec.enum("direction_id", 0, 0, 1),
+			BlockID:      rec.str("block_id"),
+			ShapeID:      rec.str("shape_id"),
+			Wheelchair:   rec.enum("wheelchair_accessible", 0, 0, 1, 2),
+			BikesAllowed: rec.enum("bikes_allowed", 0, 0, 1, 2),
+		})
+	})
+	return trips, err
+}
+
+func (p *parser) parseCalendars() ([]Calendar, error) {
+	var cals []Calendar
+	required := []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"}
+	err := p.forEachRow("calendar.txt", required, func(rec *record) {
+		cals = append(cals, Calendar{
+			ServiceID: rec.str("service_id"),
+			Monday:    rec.bool("monday"),
+			Tuesday:   rec.bool("tuesday"),
+			Wednesday: rec.bool("wednesday"),
+			Thursday:  rec.bool("thursday"),
+			Friday:    rec.bool("friday"),
+			Saturday:  rec.bool("saturday"),
+			Sunday:    rec.bool("sunday"),
+			StartDate: rec.date("start_date"),
+			EndDate:   rec.date("end_date"),
+		})
+	})
+	return cals, err
+}
 
+func (p *parser) parseCalendarDates() ([]CalendarDate, error) {
+	var dates []CalendarDate
+	err := p.forEachRow("calendar_dates.txt", []string{"service_id", "date", "exception_type"}, func(rec *record) {
+		dates = append(dates, CalendarDate{
+			ServiceID:     rec.str("service_id"),
+			Date:          rec.date("date"),
+			ExceptionType: rec.enum("exception_type", 0, 1, 2),
+		})
+	})
+	return dates, err
+}
+
+func (p *parser) parseStopTimes() ([]StopTime, error) {
 	var times []StopTime
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
+	err := p.forEachRow("stop_times.txt", []string{"trip_id", "stop_id", "stop_sequence"}, func(rec *record) {
+		st := StopTime{
+			TripID:            rec.str("trip_id"),
+			ArrivalTime:       rec.time("arrival_time"),
+			DepartureTime:     rec.time("departure_time"),
+			StopID:            rec.str("stop_id"),
+			StopSequence:      rec.int("stop_sequence"),
+			StopHeadsign:      rec.str("stop_headsign"),
+			PickupType:        rec.enum("pickup_type", 0, 0, 1, 2, 3),
+			DropOffType:       rec.enum("drop_off_type", 0, 0, 1, 2, 3),
+			ContinuousPickup:  rec.enum("continuous_pickup", 1, 0, 1, 2, 3),
+			ContinuousDropOff: rec.enum("continuous_drop_off", 1, 0, 1, 2, 3),
+			ShapeDistTraveled: rec.float("shape_dist_traveled"),
+			Timepoint:         rec.enum("timepoint", 1, 0, 1),
 		}
-		if err != nil {
-			return nil, err
+		// A stop with only one of the two times is served at that time.
+		if st.ArrivalTime == NoTime {
+			st.ArrivalTime = st.DepartureTime
 		}
-		times = append(times, StopTime{
-			TripID:        col(row, idx, "trip_id"),
-			ArrivalTime:   ParseTimeToSeconds(col(row, idx, "arrival_time")),
-			DepartureTime: ParseTimeToSeconds(col(row, idx, "departure_time")),
-			StopID:        col(row, idx, "stop_id"),
-			StopSequence:  colInt(row, idx, "stop_sequence"),
-		})
+		if st.DepartureTime == NoTime {
+			st.DepartureTime = st.ArrivalTime
+		}
+		times = append(times, st)
+	})
+	if err == nil && p.opts.Strict {
+		p.checkTripEnds(times)
 	}
-	return times, nil
+	return times, err
 }
 
-func parseTransfers(path string) ([]Transfer, error) {
-	r, closer, err := openCSV(path)
-	if err != nil {
-		return nil, err
+// checkTripEnds reports trips whose first or last stop has no time. GTFS
+// only allows times to be left out between timepoints.
+func (p *parser) checkTripEnds(times []StopTime) {
+	type ends struct{ first, last int }
+	trips := make(map[string]ends)
+	var order []string
+	for i, st := range times {
+		e, ok := trips[st.TripID]
+		if !ok {
+			trips[st.TripID] = ends{i, i}
+			order = append(order, st.TripID)
+			continue
+		}
+		if st.StopSequence < times[e.first].StopSequence {
+			e.first = i
+		}
+		if st.StopSequence > times[e.last].StopSequence {
+			e.last = i
+		}
+		trips[st.TripID] = e
 	}
-	defer closer.Close()
 
-	idx, err := readHeader(r)
-	if err != nil {
-		return nil, err
+	for _, id := range order {
+		e := trips[id]
+		for _, i := range []int{e.first, e.last} {
+			if times[i].ArrivalTime != NoTime {
+				continue
+			}
+			which := "first"
+			if i == e.last {
+				which = "last"
+			}
+			p.report(Diagnostic{File: "stop_times.txt", Line: lineOf(p.lines["stop_times.txt"], i), Column: "arrival_time", Severity: SeverityError,
+				Message: fmt.Sprintf("%s stop of trip %s has no arrival or departure time", which, id)})
+			if e.first == e.last {
+				break
+			}
+		}
 	}
+}
 
+func (p *parser) parseTransfers() ([]Transfer, error) {
 	var transfers []Transfer
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+	err := p.forEachRow("transfers.txt", []string{"transfer_type"}, func(rec *record) {
 		transfers = append(transfers, Transfer{
-			FromStopID:      col(row, idx, "from_stop_id"),
-			ToStopID:        col(row, idx, "to_stop_id"),
-			TransferType:    colInt(row, idx, "transfer_type"),
-			MinTransferTime: colInt(row, idx, "min_transfer_time"),
+			FromStopID:      rec.str("from_stop_id"),
+			ToStopID:        rec.str("to_stop_id"),
+			TransferType:    rec.enum("transfer_type", 0, 0, 1, 2, 3, 4, 5),
+			MinTransferTime: rec.int("min_transfer_time"),
 		})
-	}
-	return transfers, nil
+	})
+	return transfers, err
+}
+
+func (p *parser) parseShapes() ([]ShapePoint, error) {
+	var points []ShapePoint
+	err := p.forEachRow("shapes.txt", []string{"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"}, func(rec *record) {
+		points = append(points, ShapePoint{
+			ShapeID:      rec.str("shape_id"),
+			Lat:          rec.float("shape_pt_lat"),
+			Lon:          rec.float("shape_pt_lon"),
+			Sequence:     rec.int("shape_pt_sequence"),
+			DistTraveled: rec.float("shape_dist_traveled"),
+		})
+	})
+	return points, err
+}
+
+func (p *parser) parseFrequencies() ([]Frequency, error) {
+	var freqs []Frequency
+	err := p.forEachRow("frequencies.txt", []string{"trip_id", "start_time", "end_time", "headway_secs"}, func(rec *record) {
+		freqs = append(freqs, Frequency{
+			TripID:      rec.str("trip_id"),
+			StartTime:   rec.time("start_time"),
+			EndTime:     rec.time("end_time"),
+			HeadwaySecs: rec.int("headway_secs"),
+			ExactTimes:  rec.enum("exact_times", 0, 0, 1),
+		})
+	})
+	return freqs, err
+}
+
+func (p *parser) parseFareAttributes() ([]FareAttribute, error) {
+	var fares []FareAttribute
+	err := p.forEachRow("fare_attributes.txt", []string{"fare_id", "price", "currency_type", "payment_method"}, func(rec *record) {
+		fares = append(fares, FareAttribute{
+			FareID:           rec.str("fare_id"),
+			Price:            rec.float("price"),
+			CurrencyType:     rec.str("currency_type"),
+			PaymentMethod:    rec.enum("payment_method", 0, 0, 1),
+			Transfers:        rec.str("transfers"),
+			AgencyID:         rec.str("agency_id"),
+			TransferDuration: rec.int("transfer_duration"),
+		})
+	})
+	return fares, err
+}
+
+func (p *parser) parseFareRules() ([]FareRule, error) {
+	var rules []FareRule
+	err := p.forEachRow("fare_rules.txt", []string{"fare_id"}, func(rec *record) {
+		rules = append(rules, FareRule{
+			FareID:        rec.str("fare_id"),
+			RouteID:       rec.str("route_id"),
+			OriginID:      rec.str("origin_id"),
+			DestinationID: rec.str("destination_id"),
+			ContainsID:    rec.str("contains_id"),
+		})
+	})
+	return rules, err
+}
+
+func (p *parser) parseLevels() ([]Level, error) {
+	var levels []Level
+	err := p.forEachRow("levels.txt", []string{"level_id", "level_index"}, func(rec *record) {
+		levels = append(levels, Level{
+			ID:    rec.str("level_id"),
+			Index: rec.float("level_index"),
+			Name:  rec.str("level_name"),
+		})
+	})
+	return levels, err
+}
+
+func (p *parser) parsePathways() ([]Pathway, error) {
+	var pathways []Pathway
+	required := []string{"pathway_id", "from_stop_id", "to_stop_id", "pathway_mode", "is_bidirectional"}
+	err := p.forEachRow("pathways.txt", required, func(rec *record) {
+		pathways = append(pathways, Pathway{
+			ID:                   rec.str("pathway_id"),
+			FromStopID:           rec.str("from_stop_id"),
+			ToStopID:             rec.str("to_stop_id"),
+			Mode:                 rec.enum("pathway_mode", 0, 1, 2, 3, 4, 5, 6, 7),
+			IsBidirectional:      rec.enum("is_bidirectional", 0, 0, 1),
+			Length:               rec.float("length"),
+			TraversalTime:        rec.int("traversal_time"),
+			StairCount:           rec.int("stair_count"),
+			MaxSlope:             rec.float("max_slope"),
+			MinWidth:             rec.float("min_width"),
+			SignpostedAs:         rec.str("signposted_as"),
+			ReversedSignpostedAs: rec.str("reversed_signposted_as"),
+		})
+	})
+	return pathways, err
 }
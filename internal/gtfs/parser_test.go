@@ -0,0 +1,183 @@
+package gtfs
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+// testFeed returns a minimal valid feed with one trip over three stops.
+// Tests replace individual files.
+func testFeed() fstest.MapFS {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	return fstest.MapFS{
+		"stops.txt":          file("stop_id,stop_name\nA,Alpha\nB,Beta\nC,Gamma\n"),
+		"routes.txt":         file("route_id,route_short_name,route_type\nR,1,0\n"),
+		"trips.txt":          file("route_id,service_id,trip_id\nR,S,T\n"),
+		"calendar.txt":       file("service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nS,1,1,1,1,1,0,0,20260101,20261231\n"),
+		"calendar_dates.txt": file("service_id,date,exception_type\n"),
+		"transfers.txt":      file("from_stop_id,to_stop_id,transfer_type\n"),
+		"stop_times.txt": file("trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"T,08:00:00,08:00:00,A,1\n" +
+			"T,08:05:00,08:06:00,B,2\n" +
+			"T,08:10:00,08:10:00,C,3\n"),
+	}
+}
+
+func TestParseStrictDiagnostics(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Diagnostic
+	}{
+		{
+			name: "valid feed",
+		},
+		{
+			name: "empty times between timepoints",
+			files: map[string]string{"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T,08:00:00,08:00:00,A,1\nT,,,B,2\nT,08:10:00,08:10:00,C,3\n"},
+		},
+		{
+			name: "empty time at first stop",
+			files: map[string]string{"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T,08:05:00,08:05:00,B,2\nT,,,A,1\nT,08:10:00,08:10:00,C,3\n"},
+			want: []Diagnostic{{File: "stop_times.txt", Line: 3, Column: "arrival_time", Severity: SeverityError,
+				Message: "first stop of trip T has no arrival or departure time"}},
+		},
+		{
+			name: "empty time at last stop",
+			files: map[string]string{"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T,08:00:00,08:00:00,A,1\nT,08:05:00,08:05:00,B,2\nT,,,C,3\n"},
+			want: []Diagnostic{{File: "stop_times.txt", Line: 4, Column: "arrival_time", Severity: SeverityError,
+				Message: "last stop of trip T has no arrival or departure time"}},
+		},
+		{
+			name: "invalid time",
+			files: map[string]string{"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T,08:00:00,08:00:00,A,1\nT,08:61:00,08:06:00,B,2\nT,08:10:00,08:10:00,C,3\n"},
+			want: []Diagnostic{{File: "stop_times.txt", Line: 3, Column: "arrival_time", Value: "08:61:00", Severity: SeverityError,
+				Message: "invalid time, expected HH:MM:SS"}},
+		},
+		{
+			name:  "missing required column",
+			files: map[string]string{"stops.txt": "stop_name\nAlpha\n"},
+			want:  []Diagnostic{{File: "stops.txt", Line: 1, Column: "stop_id", Severity: SeverityError, Message: "missing required column"}},
+		},
+		{
+			name:  "missing required value",
+			files: map[string]string{"trips.txt": "route_id,service_id,trip_id\nR,,T\n"},
+			want:  []Diagnostic{{File: "trips.txt", Line: 2, Column: "service_id", Severity: SeverityError, Message: "missing required value"}},
+		},
+		{
+			name:  "invalid date",
+			files: map[string]string{"calendar_dates.txt": "service_id,date,exception_type\nS,2026-01-01,2\n"},
+			want: []Diagnostic{{File: "calendar_dates.txt", Line: 2, Column: "date", Value: "2026-01-01", Severity: SeverityError,
+				Message: "invalid date, expected YYYYMMDD"}},
+		},
+		{
+			name:  "unexpected enum value",
+			files: map[string]string{"trips.txt": "route_id,service_id,trip_id,direction_id\nR,S,T,2\n"},
+			want:  []Diagnostic{{File: "trips.txt", Line: 2, Column: "direction_id", Value: "2", Severity: SeverityWarning, Message: "unexpected value"}},
+		},
+		{
+			name:  "line numbers after a multi-line field",
+			files: map[string]string{"stops.txt": "stop_id,stop_name,stop_lat\nA,\"Alpha\nNorth\",50\nB,Beta,north\n"},
+			want:  []Diagnostic{{File: "stops.txt", Line: 4, Column: "stop_lat", Value: "north", Severity: SeverityError, Message: "invalid number"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := testFeed()
+			for name, data := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			_, diags, err := ParseFS(fsys, ParseOptions{Strict: true, MaxErrors: 100})
+			if err != nil {
+				t.Fatalf("ParseFS: %v", err)
+			}
+			if len(diags) != len(tt.want) {
+				t.Fatalf("got diagnostics %v, want %v", diags, tt.want)
+			}
+			for i := range diags {
+				if diags[i] != tt.want[i] {
+					t.Errorf("diagnostic %d = %v, want %v", i, diags[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseStopTimesWithoutTimes(t *testing.T) {
+	fsys := testFeed()
+	fsys["stop_times.txt"] = &fstest.MapFile{Data: []byte("trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+		"T,,08:00:00,A,1\nT,,,B,2\nT,08:10:00,,C,3\n")}
+	feed, _, err := ParseFS(fsys, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int{{8 * 3600, 8 * 3600}, {NoTime, NoTime}, {8*3600 + 600, 8*3600 + 600}}
+	for i, st := range feed.StopTimes {
+		if got := [2]int{st.ArrivalTime, st.DepartureTime}; got != want[i] {
+			t.Errorf("stop %s: arrival, departure = %v, want %v", st.StopID, got, want[i])
+		}
+	}
+}
+
+func TestParseTooManyErrors(t *testing.T) {
+	fsys := testFeed()
+	fsys["stops.txt"] = &fstest.MapFile{Data: []byte("stop_id,stop_name,stop_lat\nA,Alpha,x\nB,Beta,y\nC,Gamma,z\n")}
+
+	feed, diags, err := ParseFS(fsys, ParseOptions{Strict: true, MaxErrors: 2})
+	if !errors.Is(err, ErrTooManyErrors) {
+		t.Fatalf("ParseFS error = %v, want ErrTooManyErrors", err)
+	}
+	if feed == nil || len(diags) != 3 {
+		t.Errorf("got feed %v and %d diagnostics, want the feed and 3 diagnostics", feed != nil, len(diags))
+	}
+
+	if _, diags, err = ParseFS(fsys, ParseOptions{}); err != nil || len(diags) != 0 {
+		t.Errorf("lenient ParseFS = %d diagnostics, %v; want none", len(diags), err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "00:00:00", want: 0},
+		{in: "08:05:30", want: 8*3600 + 5*60 + 30},
+		{in: "25:10:00", want: 25*3600 + 10*60},
+		{in: "8:05:00", want: 8*3600 + 5*60},
+		{in: "08:05", wantErr: true},
+		{in: "08:60:00", wantErr: true},
+		{in: "08:00:60", wantErr: true},
+		{in: "-1:00:00", wantErr: true},
+		{in: "aa:bb:cc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("ParseTime(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFeedLine(t *testing.T) {
+	fsys := testFeed()
+	fsys["stops.txt"] = &fstest.MapFile{Data: []byte("stop_id,stop_name\nA,\"Alpha\nNorth\"\nB,Beta\n\nC,Gamma\nD,Delta\n")}
+	feed, _, err := ParseFS(fsys, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{2, 4, 6, 7} {
+		if got := feed.Line("stops.txt", i); got != want {
+			t.Errorf("Line(stops.txt, %d) = %d, want %d", i, got, want)
+		}
+	}
+	if got := feed.Line("shapes.txt", 0); got != 0 {
+		t.Errorf("Line of a missing file = %d, want 0", got)
+	}
+}
@@ -0,0 +1,256 @@
+// Package ical writes iCalendar (RFC 5545) files.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const prodID = "-//DPMLJ Timetable//CS"
+
+// Event is a VEVENT. Start and End are written in their location; events
+// in a named time zone get a matching VTIMEZONE.
+type Event struct {
+	UID         string
+	Start, End  time.Time
+	Summary     string
+	Location    string
+	Description string
+	URL         string
+
+	// Repeat, if set, repeats the event weekly on the given days until the
+	// end of Until's day. ExDates are occurrences to skip and RDates extra
+	// occurrences, both at the start time.
+	Repeat  []time.Weekday
+	Until   time.Time
+	ExDates []time.Time
+	RDates  []time.Time
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Write writes a calendar holding events to w.
+func Write(w io.Writer, events []Event) error {
+	cw := &writer{w: bufio.NewWriter(w)}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + prodID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	for _, z := range zones(events) {
+		cw.timezone(z)
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		cw.line("BEGIN:VEVENT")
+		cw.line("UID:" + e.UID)
+		cw.line("DTSTAMP:" + stamp)
+		cw.line("DTSTART" + dateTime(e.Start))
+		cw.line("DTEND" + dateTime(e.End))
+		cw.line("SUMMARY:" + escape(e.Summary))
+		if e.Location != "" {
+			cw.line("LOCATION:" + escape(e.Location))
+		}
+		if e.Description != "" {
+			cw.line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.URL != "" {
+			cw.line("URL:" + e.URL)
+		}
+		if len(e.Repeat) > 0 {
+			cw.line("RRULE:" + rrule(e))
+		}
+		for _, d := range e.ExDates {
+			cw.line("EXDATE" + dateTime(d))
+		}
+		for _, d := range e.RDates {
+			cw.line("RDATE" + dateTime(d))
+		}
+		cw.line("END:VEVENT")
+	}
+	cw.line("END:VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+func rrule(e Event) string {
+	days := make([]string, len(e.Repeat))
+	for i, wd := range e.Repeat {
+		days[i] = weekdayCodes[wd]
+	}
+	rule := "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+	if !e.Until.IsZero() {
+		y, m, d := e.Until.Date()
+		end := time.Date(y, m, d, 23, 59, 59, 0, e.Start.Location())
+		// UNTIL is in UTC for zoned start times and floating otherwise.
+		if floating(e.Start) {
+			rule += ";UNTIL=" + end.Format("20060102T150405")
+		} else {
+			rule += ";UNTIL=" + end.UTC().Format("20060102T150405Z")
+		}
+	}
+	return rule
+}
+
+// dateTime formats t as a property value including its parameters.
+func dateTime(t time.Time) string {
+	switch {
+	case t.Location() == time.UTC:
+		return ":" + t.Format("20060102T150405Z")
+	case floating(t):
+		return ":" + t.Format("20060102T150405")
+	default:
+		return ";TZID=" + t.Location().String() + ":" + t.Format("20060102T150405")
+	}
+}
+
+// floating reports whether t's location has no IANA name to refer to, in
+// which case it is written as floating local time.
+func floating(t time.Time) bool {
+	name := t.Location().String()
+	return name == "Local" || name == ""
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line, folded to 75 octets without splitting UTF-8
+// sequences.
+func (cw *writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	_, cw.err = cw.w.WriteString(b.String())
+}
+
+// zone is a named location and the period it is used for.
+type zone struct {
+	loc        *time.Location
+	start, end time.Time
+}
+
+func zones(events []Event) []zone {
+	byName := make(map[string]*zone)
+	extend := func(t time.Time) {
+		if t.IsZero() || t.Location() == time.UTC || floating(t) {
+			return
+		}
+		name := t.Location().String()
+		z, ok := byName[name]
+		if !ok {
+			byName[name] = &zone{loc: t.Location(), start: t, end: t}
+			return
+		}
+		if t.Before(z.start) {
+			z.start = t
+		}
+		if t.After(z.end) {
+			z.end = t
+		}
+	}
+	for _, e := range events {
+		extend(e.Start)
+		extend(e.End)
+		if !e.Until.IsZero() {
+			extend(e.Until.In(e.Start.Location()).AddDate(0, 0, 1))
+		}
+		for _, d := range append(e.ExDates, e.RDates...) {
+			extend(d)
+		}
+	}
+
+	list := make([]zone, 0, len(byName))
+	for _, z := range byName {
+		list = append(list, *z)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].loc.String() < list[j].loc.String() })
+	return list
+}
+
+// timezone writes a VTIMEZONE with one observance for the offset in effect
+// at the start of z and one for each transition until its end.
+func (cw *writer) timezone(z zone) {
+	cw.line("BEGIN:VTIMEZONE")
+	cw.line("TZID:" + z.loc.String())
+
+	start := z.start.In(z.loc).Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	_, offset := start.Zone()
+	cw.observance(start, offset)
+	for t := start; t.Before(z.end); {
+		next := t.Add(24 * time.Hour)
+		if _, o := next.Zone(); o != offset {
+			at := transition(t, next)
+			cw.observance(at, offset)
+			_, offset = at.Zone()
+		}
+		t = next
+	}
+	cw.line("END:VTIMEZONE")
+}
+
+// observance writes a STANDARD or DAYLIGHT component for the offset in
+// effect from at, which replaces the offset from.
+func (cw *writer) observance(at time.Time, from int) {
+	name, to := at.Zone()
+	kind := "STANDARD"
+	if at.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	cw.line("BEGIN:" + kind)
+	// DTSTART is the wall clock time at the transition in the old offset.
+	cw.line("DTSTART:" + at.UTC().Add(time.Duration(from)*time.Second).Format("20060102T150405"))
+	cw.line("TZOFFSETFROM:" + formatOffset(from))
+	cw.line("TZOFFSETTO:" + formatOffset(to))
+	if name != "" && name[0] != '+' && name[0] != '-' {
+		cw.line("TZNAME:" + name)
+	}
+	cw.line("END:" + kind)
+}
+
+// transition finds the first second after a with the offset of b.
+func transition(a, b time.Time) time.Time {
+	_, want := b.Zone()
+	for b.Sub(a) > time.Second {
+		mid := a.Add(b.Sub(a) / 2).Truncate(time.Second)
+		if _, o := mid.Zone(); o == want {
+			b = mid
+		} else {
+			a = mid
+		}
+	}
+	return b
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+}
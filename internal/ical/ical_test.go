@@ -0,0 +1,156 @@
+package ical
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// properties writes e and returns its VEVENT lines that start with one of
+// the given property names, unfolded.
+func properties(t *testing.T, e Event, names ...string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, []Event{e}); err != nil {
+		t.Fatal(err)
+	}
+	text := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	_, vevent, _ := strings.Cut(text, "BEGIN:VEVENT\r\n")
+	var got []string
+	for _, line := range strings.Split(vevent, "\r\n") {
+		for _, name := range names {
+			if strings.HasPrefix(line, name+":") || strings.HasPrefix(line, name+";") {
+				got = append(got, line)
+			}
+		}
+	}
+	return got
+}
+
+func TestWriteRecurrence(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	start := time.Date(2026, 3, 2, 7, 15, 0, 0, prague)
+	local := time.Date(2026, 3, 2, 7, 15, 0, 0, time.Local)
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{
+			name:  "single event",
+			event: Event{Start: start, End: start.Add(20 * time.Minute)},
+			want:  []string{"DTSTART;TZID=Europe/Prague:20260302T071500"},
+		},
+		{
+			name: "weekdays until the end of the last day",
+			event: Event{Start: start, End: start.Add(20 * time.Minute),
+				Repeat: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				Until:  time.Date(2026, 6, 30, 0, 0, 0, 0, prague)},
+			want: []string{
+				"DTSTART;TZID=Europe/Prague:20260302T071500",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20260630T215959Z",
+			},
+		},
+		{
+			name: "floating time keeps UNTIL floating",
+			event: Event{Start: local, End: local.Add(20 * time.Minute),
+				Repeat: []time.Weekday{time.Saturday, time.Sunday},
+				Until:  time.Date(2026, 6, 30, 12, 0, 0, 0, time.Local)},
+			want: []string{
+				"DTSTART:20260302T071500",
+				"RRULE:FREQ=WEEKLY;BYDAY=SA,SU;UNTIL=20260630T235959",
+			},
+		},
+		{
+			name: "without an end",
+			event: Event{Start: start, End: start.Add(20 * time.Minute),
+				Repeat: []time.Weekday{time.Sunday}},
+			want: []string{
+				"DTSTART;TZID=Europe/Prague:20260302T071500",
+				"RRULE:FREQ=WEEKLY;BYDAY=SU",
+			},
+		},
+		{
+			name: "exceptions across a DST change",
+			event: Event{Start: start, End: start.Add(20 * time.Minute),
+				Repeat:  []time.Weekday{time.Monday},
+				Until:   time.Date(2026, 4, 30, 0, 0, 0, 0, prague),
+				ExDates: []time.Time{time.Date(2026, 4, 6, 7, 15, 0, 0, prague)},
+				RDates:  []time.Time{time.Date(2026, 4, 4, 7, 15, 0, 0, prague)}},
+			want: []string{
+				"DTSTART;TZID=Europe/Prague:20260302T071500",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO;UNTIL=20260430T215959Z",
+				"EXDATE;TZID=Europe/Prague:20260406T071500",
+				"RDATE;TZID=Europe/Prague:20260404T071500",
+			},
+		},
+		{
+			name: "UTC exceptions",
+			event: Event{Start: start.UTC(), End: start.UTC().Add(20 * time.Minute),
+				Repeat:  []time.Weekday{time.Monday},
+				ExDates: []time.Time{time.Date(2026, 3, 9, 6, 15, 0, 0, time.UTC)}},
+			want: []string{
+				"DTSTART:20260302T061500Z",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO",
+				"EXDATE:20260309T061500Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := properties(t, tt.event, "DTSTART", "RRULE", "EXDATE", "RDATE")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestWriteTimeZone(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	start := time.Date(2026, 3, 2, 7, 15, 0, 0, prague)
+	var buf bytes.Buffer
+	err = Write(&buf, []Event{{Start: start, End: start.Add(time.Hour),
+		Repeat: []time.Weekday{time.Monday}, Until: time.Date(2026, 4, 30, 0, 0, 0, 0, prague)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// The rule crosses the switch to summer time on 29 March.
+	for _, want := range []string{
+		"TZID:Europe/Prague\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20260301T010000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20260329T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("calendar lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	start := time.Date(2026, 3, 2, 7, 15, 0, 0, time.UTC)
+	summary := strings.Repeat("Příští zastávka; ", 10)
+	var buf bytes.Buffer
+	if err := Write(&buf, []Event{{Start: start, End: start, Summary: summary}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+	got := properties(t, Event{Start: start, End: start, Summary: summary}, "SUMMARY")
+	if want := "SUMMARY:" + strings.ReplaceAll(summary, ";", `\;`); len(got) != 1 || got[0] != want {
+		t.Errorf("SUMMARY = %q, want %q", got, want)
+	}
+}
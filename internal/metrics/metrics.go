@@ -0,0 +1,255 @@
+// Package metrics implements the few Prometheus metric types the service
+// exposes and writes them in the text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics in the order they were registered.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// desc is the name, help text and label names of a metric family.
+type desc struct {
+	name, help, kind string
+	labels           []string
+}
+
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// series formats the name and labels of one series, with extra label
+// pairs appended.
+func (d desc) series(suffix string, values []string, extra ...string) string {
+	var b strings.Builder
+	b.WriteString(d.name + suffix)
+	if len(values)+len(extra) == 0 {
+		return b.String()
+	}
+	b.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, d.labels[i], escapeLabel(v))
+	}
+	for i := 0; i < len(extra); i += 2 {
+		if i > 0 || len(values) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra[i], escapeLabel(extra[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// values is a set of labelled float series.
+type values struct {
+	desc
+	mu     sync.Mutex
+	series map[string][]string
+	value  map[string]float64
+}
+
+func newValues(d desc) *values {
+	return &values{desc: d, series: make(map[string][]string), value: make(map[string]float64)}
+}
+
+func (v *values) update(labels []string, f func(float64) float64) {
+	k := v.key(labels)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.series[k]; !ok {
+		v.series[k] = slices.Clone(labels)
+	}
+	v.value[k] = f(v.value[k])
+}
+
+func (v *values) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.header(w)
+	for _, k := range sortedKeys(v.series) {
+		fmt.Fprintf(w, "%s %s\n", v.desc.series("", v.series[k]), formatFloat(v.value[k]))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct{ *values }
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{newValues(desc{name, help, "counter", labels})}
+	r.register(c)
+	return c
+}
+
+// Add increases the series with the given label values by delta.
+func (c *Counter) Add(delta float64, labels ...string) {
+	c.update(labels, func(v float64) float64 { return v + delta })
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Histogram counts observations in cumulative buckets per label
+// combination.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram registers a histogram with the given upper bucket bounds in
+// increasing order; the +Inf bucket is implied.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labels ...string) {
+	k := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{labels: slices.Clone(labels), counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s %d\n", h.desc.series("_bucket", s.labels, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s %d\n", h.desc.series("_bucket", s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s %s\n", h.desc.series("_sum", s.labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s %d\n", h.desc.series("_count", s.labels), s.count)
+	}
+}
+
+// Func is a metric whose series are computed when the metrics are
+// scraped, for values kept elsewhere.
+type Func struct {
+	desc
+	collect func(emit func(v float64, labels ...string))
+}
+
+// GaugeFunc registers a gauge whose series are reported by collect.
+func (r *Registry) GaugeFunc(name, help string, collect func(emit func(v float64, labels ...string)), labels ...string) {
+	r.register(&Func{desc{name, help, "gauge", labels}, collect})
+}
+
+// CounterFunc registers a counter whose series are reported by collect.
+func (r *Registry) CounterFunc(name, help string, collect func(emit func(v float64, labels ...string)), labels ...string) {
+	r.register(&Func{desc{name, help, "counter", labels}, collect})
+}
+
+func (f *Func) write(w io.Writer) {
+	f.header(w)
+	f.collect(func(v float64, labels ...string) {
+		f.key(labels)
+		fmt.Fprintf(w, "%s %s\n", f.desc.series("", labels), formatFloat(v))
+	})
+}
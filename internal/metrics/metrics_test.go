@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("http_requests_total", "Requests served, by route and status.", "route", "code")
+	requests.Inc("/departures", "200")
+	requests.Add(2, "/departures", "200")
+	requests.Inc(`/board/"a\b"`+"\n", "404")
+	r.Counter("updates_total", "Feed updates.\nIncludes \\ failed ones.").Inc()
+
+	latency := r.Histogram("http_request_duration_seconds", "Request latency.", []float64{0.01, 0.1, 1}, "route")
+	for _, v := range []float64{0.005, 0.05, 0.5, 5} {
+		latency.Observe(v, "/departures")
+	}
+	latency.Observe(0.01, "/")
+	r.Histogram("empty_seconds", "A histogram without observations.", []float64{1})
+
+	r.GaugeFunc("feed_trips", "Trips in each feed.", func(emit func(float64, ...string)) {
+		emit(1234, "live")
+		emit(1.5e6, "upcoming")
+	}, "feed")
+	r.CounterFunc("index_builds_total", "Index builds.", func(emit func(float64, ...string)) {
+		emit(7)
+	})
+
+	var b strings.Builder
+	r.WriteText(&b)
+	want, err := os.ReadFile(filepath.Join("testdata", "exposition.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(want) {
+		t.Errorf("WriteText wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c_total", "C.").Inc()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if want := "# HELP c_total C.\n# TYPE c_total counter\nc_total 1\n"; rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestLabelCountMismatch(t *testing.T) {
+	c := NewRegistry().Counter("c_total", "C.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a missing label value")
+		}
+	}()
+	c.Inc("x")
+}
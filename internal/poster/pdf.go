@@ -0,0 +1,274 @@
+package poster
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	font   = "Go"
+	margin = 12.0
+)
+
+func newPDF(orientation, title string) *fpdf.Fpdf {
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(font, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(font, "B", gobold.TTF)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("DPMLJ Timetable", true)
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, margin)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		_, h := pdf.GetPageSize()
+		pdf.SetXY(margin, h-margin+2)
+		pdf.SetFont(font, "", 7)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 4, title, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 4, fmt.Sprintf("%d/{nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	return pdf
+}
+
+func validityText(from, to time.Time) string {
+	return fmt.Sprintf("Platí od %s do %s", from.Format("2.1.2006"), to.Format("2.1.2006"))
+}
+
+// lineBadge draws the line number on the route colour at the current
+// position.
+func lineBadge(pdf *fpdf.Fpdf, r Route, w, h, size float64) {
+	pdf.SetFillColor(r.Background[0], r.Background[1], r.Background[2])
+	pdf.SetTextColor(r.Foreground[0], r.Foreground[1], r.Foreground[2])
+	pdf.SetFont(font, "B", size)
+	pdf.CellFormat(w, h, r.Line, "", 0, "C", true, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// writeFootnotes lists the footnotes from the current position, breaking
+// pages as needed.
+func writeFootnotes(pdf *fpdf.Fpdf, notes []Footnote) {
+	if len(notes) == 0 {
+		return
+	}
+	w, h := pdf.GetPageSize()
+	if pdf.GetY()+14 > h-margin {
+		pdf.AddPage()
+	}
+	pdf.Ln(4)
+	pdf.SetFont(font, "B", 9)
+	pdf.CellFormat(0, 5, "Poznámky", "", 1, "L", false, 0, "")
+	for _, n := range notes {
+		pdf.SetFont(font, "", 8)
+		lines := pdf.SplitText(n.Text, w-2*margin-8)
+		if pdf.GetY()+float64(len(lines))*4 > h-margin {
+			pdf.AddPage()
+		}
+		pdf.SetFont(font, "B", 8)
+		pdf.CellFormat(8, 4, n.Mark, "", 0, "L", false, 0, "")
+		pdf.SetFont(font, "", 8)
+		pdf.MultiCell(0, 4, n.Text, "", "L", false)
+	}
+}
+
+// WritePDF renders the station timetable as an A4 poster.
+func (t *StationTimetable) WritePDF(w io.Writer) error {
+	pdf := newPDF("P", t.Station+" – zastávkový jízdní řád")
+	pageW, pageH := pdf.GetPageSize()
+	contentW := pageW - 2*margin
+	hourW := 12.0
+	colW := (contentW - hourW) / dayTypes
+	const lineH = 4.2
+
+	pdf.AddPage()
+	pdf.SetFont(font, "B", 20)
+	pdf.CellFormat(0, 10, t.Station, "", 1, "L", false, 0, "")
+	pdf.SetFont(font, "", 9)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.CellFormat(0, 5, "Zastávkový jízdní řád · "+validityText(t.ValidFrom, t.ValidTo), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+
+	if len(t.Sections) == 0 {
+		pdf.Ln(6)
+		pdf.SetFont(font, "", 11)
+		pdf.CellFormat(0, 6, "Ze zastávky neodjíždějí žádné spoje.", "", 1, "L", false, 0, "")
+	}
+
+	columnHeader := func() {
+		pdf.SetFont(font, "B", 8)
+		pdf.SetFillColor(235, 235, 235)
+		pdf.CellFormat(hourW, 5, "Hod.", "1", 0, "C", true, 0, "")
+		for dt := range dayTypes {
+			pdf.CellFormat(colW, 5, DayTypeNames[dt], "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	sectionHeader := func(s StationSection, cont bool) {
+		lineBadge(pdf, s.Route, 14, 7, 12)
+		pdf.SetFont(font, "B", 11)
+		title := "  směr " + s.Direction
+		if cont {
+			title += " (pokračování)"
+		}
+		pdf.CellFormat(0, 7, title, "", 1, "L", false, 0, "")
+		if s.Route.Name != "" && !cont {
+			pdf.SetFont(font, "", 7)
+			pdf.SetTextColor(90, 90, 90)
+			pdf.CellFormat(0, 4, s.Route.Name, "", 1, "L", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+		}
+		pdf.Ln(1)
+		columnHeader()
+	}
+
+	for _, s := range t.Sections {
+		pdf.Ln(5)
+		if pdf.GetY()+24 > pageH-margin {
+			pdf.AddPage()
+		}
+		sectionHeader(s, false)
+
+		for _, row := range s.Hours {
+			pdf.SetFont(font, "", 8)
+			var cells [dayTypes][]string
+			lines := 1
+			for dt := range dayTypes {
+				var parts []string
+				for _, m := range row.Minutes[dt] {
+					parts = append(parts, fmt.Sprintf("%02d%s", m.Minute, m.Mark))
+				}
+				cells[dt] = pdf.SplitText(strings.Join(parts, "  "), colW-2)
+				lines = max(lines, len(cells[dt]))
+			}
+			rowH := float64(lines)*lineH + 1
+			if pdf.GetY()+rowH > pageH-margin {
+				pdf.AddPage()
+				sectionHeader(s, true)
+				pdf.SetFont(font, "", 8)
+			}
+
+			x, y := pdf.GetXY()
+			pdf.SetFont(font, "B", 9)
+			pdf.Rect(x, y, hourW, rowH, "D")
+			pdf.SetXY(x, y+0.5)
+			pdf.CellFormat(hourW, lineH, strconv.Itoa(row.Hour%24), "", 0, "C", false, 0, "")
+			pdf.SetFont(font, "", 8)
+			for dt := range dayTypes {
+				cx := x + hourW + float64(dt)*colW
+				pdf.Rect(cx, y, colW, rowH, "D")
+				for i, text := range cells[dt] {
+					pdf.SetXY(cx+1, y+0.5+float64(i)*lineH)
+					pdf.CellFormat(colW-2, lineH, text, "", 0, "L", false, 0, "")
+				}
+			}
+			pdf.SetXY(x, y+rowH)
+		}
+	}
+
+	writeFootnotes(pdf, t.Footnotes)
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// WritePDF renders the line timetable on A4 landscape pages, one table per
+// direction and day type, split across pages when it has many trips or
+// stops.
+func (t *LineTimetable) WritePDF(w io.Writer) error {
+	pdf := newPDF("L", "Linka "+t.Route.Line+" – jízdní řád")
+	pageW, pageH := pdf.GetPageSize()
+	const (
+		stopW   = 62.0
+		tripW   = 11.0
+		headerH = 26.0
+		maxRowH = 5.0
+		minRowH = 3.4
+	)
+	tripsPerPage := int((pageW - 2*margin - stopW) / tripW)
+	avail := pageH - 2*margin - headerH - 6
+
+	if len(t.Tables) == 0 {
+		pdf.AddPage()
+		pdf.SetFont(font, "", 11)
+		pdf.CellFormat(0, 6, "Linka nemá v období platnosti žádné spoje.", "", 1, "L", false, 0, "")
+	}
+
+	for _, table := range t.Tables {
+		rowH := min(maxRowH, max(minRowH, avail/float64(len(table.Stops))))
+		rowsPerPage := int(avail / rowH)
+		size := min(8, rowH*1.9)
+
+		for firstTrip := 0; firstTrip < len(table.Trips); firstTrip += tripsPerPage {
+			trips := table.Trips[firstTrip:min(firstTrip+tripsPerPage, len(table.Trips))]
+			for firstRow := 0; firstRow < len(table.Stops); firstRow += rowsPerPage {
+				lastRow := min(firstRow+rowsPerPage, len(table.Stops))
+
+				pdf.AddPage()
+				lineBadge(pdf, t.Route, 16, 9, 14)
+				pdf.SetFont(font, "B", 14)
+				pdf.CellFormat(0, 9, "  směr "+table.Direction, "", 1, "L", false, 0, "")
+				pdf.SetFont(font, "", 9)
+				pdf.SetTextColor(90, 90, 90)
+				pdf.CellFormat(0, 5, t.Route.Name, "", 1, "L", false, 0, "")
+				pdf.CellFormat(0, 5, validityText(t.ValidFrom, t.ValidTo), "", 1, "L", false, 0, "")
+				pdf.SetTextColor(0, 0, 0)
+				pdf.SetFont(font, "B", 10)
+				pdf.CellFormat(0, 6, DayTypeNames[table.DayType], "", 1, "L", false, 0, "")
+
+				pdf.SetFont(font, "B", 7)
+				pdf.SetFillColor(235, 235, 235)
+				pdf.CellFormat(stopW, 5, "Zastávka", "1", 0, "L", true, 0, "")
+				for _, trip := range trips {
+					pdf.CellFormat(tripW, 5, trip.Mark, "1", 0, "C", true, 0, "")
+				}
+				pdf.Ln(-1)
+
+				for row := firstRow; row < lastRow; row++ {
+					fill := row%2 == 1
+					pdf.SetFillColor(246, 246, 246)
+					pdf.SetFont(font, "", size)
+					pdf.CellFormat(stopW, rowH, table.Stops[row], "1", 0, "L", fill, 0, "")
+					for _, trip := range trips {
+						pdf.CellFormat(tripW, rowH, tripCell(trip, row), "1", 0, "C", fill, 0, "")
+					}
+					pdf.Ln(-1)
+				}
+			}
+		}
+	}
+
+	writeFootnotes(pdf, t.Footnotes)
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// tripCell formats a trip's time at a row: the time where it stops, "|"
+// where it passes through and nothing before its first or after its last
+// stop.
+func tripCell(trip TripColumn, row int) string {
+	if t := trip.Times[row]; t >= 0 {
+		return fmt.Sprintf("%d:%02d", t/3600%24, t%3600/60)
+	}
+	first, last := -1, -1
+	for i, t := range trip.Times {
+		if t >= 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if row > first && row < last {
+		return "|"
+	}
+	return ""
+}
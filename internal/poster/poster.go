@@ -0,0 +1,531 @@
+// Package poster lays out printed timetables: the departures of a station
+// by hour and the timetable of a line as a matrix of stops and trips.
+package poster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"timetable/internal/search"
+)
+
+// Day types are the columns of a printed timetable.
+const (
+	Weekday = iota
+	Saturday
+	Sunday
+	dayTypes
+)
+
+var DayTypeNames = [dayTypes]string{"Pracovní dny", "Sobota", "Neděle"}
+
+// maxDays caps the range the service calendar is expanded over.
+const maxDays = 400
+
+// Footnote explains a trip that does not run on every day of its column.
+type Footnote struct {
+	Mark string
+	Text string
+}
+
+type Route struct {
+	Line string
+	Name string
+	// Background and Foreground are the badge colours as RGB.
+	Background, Foreground [3]int
+}
+
+// StationTimetable lists the departures from a station for each line and
+// direction, by hour and day type.
+type StationTimetable struct {
+	Station            string
+	ValidFrom, ValidTo time.Time
+	Sections           []StationSection
+	Footnotes          []Footnote
+}
+
+type StationSection struct {
+	Route     Route
+	Direction string
+	Hours     []HourRow
+}
+
+type HourRow struct {
+	// Hour counts from the start of the service day and may exceed 23.
+	Hour    int
+	Minutes [dayTypes][]Minute
+}
+
+type Minute struct {
+	Minute int
+	Mark   string
+}
+
+// LineTimetable lists the trips of a line for each direction and day type.
+type LineTimetable struct {
+	Route              Route
+	ValidFrom, ValidTo time.Time
+	Tables             []LineTable
+	Footnotes          []Footnote
+}
+
+type LineTable struct {
+	Direction string
+	DayType   int
+	Stops     []string
+	Trips     []TripColumn
+}
+
+type TripColumn struct {
+	Mark string
+	// Times holds the departure at each stop of the table in seconds, or
+	// -1 where the trip does not stop.
+	Times []int
+}
+
+// calendar expands the service calendar over the validity period and
+// describes which day types each service runs on.
+type calendar struct {
+	idx      *search.Index
+	from, to time.Time
+	active   []map[string]bool
+	notes    *footnotes
+
+	cache map[string][dayTypes]string
+	runs  map[string][dayTypes]bool
+}
+
+// newCalendar expands the calendar between from and to; zero dates default
+// to the range of the calendar itself.
+func newCalendar(idx *search.Index, from, to time.Time) *calendar {
+	if from.IsZero() || to.IsZero() {
+		cf, ct := calendarRange(idx)
+		if from.IsZero() {
+			from = cf
+		}
+		if to.IsZero() {
+			to = ct
+		}
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local)
+	if to.Before(from) {
+		to = from
+	}
+	if limit := from.AddDate(0, 0, maxDays); to.After(limit) {
+		to = limit
+	}
+
+	c := &calendar{
+		idx:   idx,
+		from:  from,
+		to:    to,
+		notes: &footnotes{marks: make(map[string]string)},
+		cache: make(map[string][dayTypes]string),
+		runs:  make(map[string][dayTypes]bool),
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		c.active = append(c.active, search.ActiveServices(idx.Calendars, idx.CalendarDates, d))
+	}
+	return c
+}
+
+func calendarRange(idx *search.Index) (from, to time.Time) {
+	var start, end string
+	for _, cal := range idx.Calendars {
+		if start == "" || cal.StartDate < start {
+			start = cal.StartDate
+		}
+		end = max(end, cal.EndDate)
+	}
+	for _, cd := range idx.CalendarDates {
+		if cd.ExceptionType == 1 {
+			if start == "" || cd.Date < start {
+				start = cd.Date
+			}
+			end = max(end, cd.Date)
+		}
+	}
+	from, _ = time.ParseInLocation("20060102", start, time.Local)
+	to, _ = time.ParseInLocation("20060102", end, time.Local)
+	return from, to
+}
+
+func (c *calendar) day(i int) time.Time {
+	return c.from.AddDate(0, 0, i)
+}
+
+func dayTypeOf(d time.Time) int {
+	switch d.Weekday() {
+	case time.Saturday:
+		return Saturday
+	case time.Sunday:
+		return Sunday
+	}
+	return Weekday
+}
+
+// service returns, for each day type, whether the service runs on any day
+// of that type and the footnote mark for its restrictions.
+func (c *calendar) service(serviceID string) ([dayTypes]bool, [dayTypes]string) {
+	if marks, ok := c.cache[serviceID]; ok {
+		return c.runs[serviceID], marks
+	}
+	var (
+		runs  [dayTypes]bool
+		marks [dayTypes]string
+	)
+	for dt := range dayTypes {
+		text, ok := c.restriction(serviceID, dt)
+		runs[dt] = ok
+		if text != "" {
+			marks[dt] = c.notes.mark(text)
+		}
+	}
+	c.cache[serviceID], c.runs[serviceID] = marks, runs
+	return runs, marks
+}
+
+// restriction describes on which days of type dt the service runs, relative
+// to a weekly rule inferred from the days it runs on most weeks. It returns
+// "" if the service runs on all of them, and false if on none.
+func (c *calendar) restriction(serviceID string, dt int) (string, bool) {
+	var (
+		total, running [7]int
+		first          = -1
+	)
+	for i, active := range c.active {
+		d := c.day(i)
+		if dayTypeOf(d) != dt {
+			continue
+		}
+		total[d.Weekday()]++
+		if active[serviceID] {
+			running[d.Weekday()]++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	var rule [7]bool
+	var ruleDays, typeDays []time.Weekday
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if total[wd] == 0 {
+			continue
+		}
+		typeDays = append(typeDays, wd)
+		if running[wd]*2 > total[wd] {
+			rule[wd] = true
+			ruleDays = append(ruleDays, wd)
+		}
+	}
+
+	var onDates []time.Time
+	if len(ruleDays) == 0 {
+		for i, active := range c.active {
+			if d := c.day(i); dayTypeOf(d) == dt && active[serviceID] {
+				onDates = append(onDates, d)
+			}
+		}
+		return "jede jen " + formatDates(onDates), true
+	}
+
+	var parts []string
+	if len(ruleDays) < len(typeDays) {
+		names := make([]string, len(ruleDays))
+		for i, wd := range ruleDays {
+			names[i] = weekdayNames[wd]
+		}
+		parts = append(parts, "jede "+strings.Join(names, ", "))
+	}
+
+	// Days outside the first and last day of the rule are reported as a
+	// range, the rest as single exceptions.
+	ruleFirst, ruleLast := -1, -1
+	for i, active := range c.active {
+		if d := c.day(i); dayTypeOf(d) == dt && rule[d.Weekday()] && active[serviceID] {
+			if ruleFirst < 0 {
+				ruleFirst = i
+			}
+			ruleLast = i
+		}
+	}
+	if c.day(ruleFirst).Sub(c.from) > 7*24*time.Hour {
+		parts = append(parts, "jede od "+c.day(ruleFirst).Format("2.1."))
+	}
+	if c.to.Sub(c.day(ruleLast)) > 7*24*time.Hour {
+		parts = append(parts, "jede do "+c.day(ruleLast).Format("2.1."))
+	}
+
+	var missing, extra []time.Time
+	for i, active := range c.active {
+		d := c.day(i)
+		if dayTypeOf(d) != dt {
+			continue
+		}
+		switch {
+		case rule[d.Weekday()] && !active[serviceID] && i > ruleFirst && i < ruleLast:
+			missing = append(missing, d)
+		case !rule[d.Weekday()] && active[serviceID]:
+			extra = append(extra, d)
+		}
+	}
+	if len(missing) > 0 {
+		parts = append(parts, "nejede "+formatDates(missing))
+	}
+	if len(extra) > 0 {
+		parts = append(parts, "jede také "+formatDates(extra))
+	}
+	return strings.Join(parts, "; "), true
+}
+
+var weekdayNames = [7]string{"v neděli", "v pondělí", "v úterý", "ve středu", "ve čtvrtek", "v pátek", "v sobotu"}
+
+// formatDates lists dates as "24.12., 31.12.", abbreviating long lists.
+func formatDates(dates []time.Time) string {
+	const shown = 8
+	parts := make([]string, 0, min(len(dates), shown))
+	for i, d := range dates {
+		if i == shown {
+			return strings.Join(parts, ", ") + fmt.Sprintf(" a v dalších %d dnech", len(dates)-shown)
+		}
+		parts = append(parts, d.Format("2.1."))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// footnotes assigns marks to footnote texts in order of first use.
+type footnotes struct {
+	marks map[string]string
+	list  []Footnote
+}
+
+func (f *footnotes) mark(text string) string {
+	if m, ok := f.marks[text]; ok {
+		return m
+	}
+	m := footnoteMark(len(f.list))
+	f.marks[text] = m
+	f.list = append(f.list, Footnote{Mark: m, Text: text})
+	return m
+}
+
+// footnoteMark returns a, b, ..., z, aa, ab, ...
+func footnoteMark(n int) string {
+	if n < 26 {
+		return string(rune('a' + n))
+	}
+	return footnoteMark(n/26-1) + footnoteMark(n%26)
+}
+
+func routeOf(idx *search.Index, routeID string) Route {
+	r, _ := idx.Route(routeID)
+	bg, fg := search.RouteColors(r)
+	return Route{Line: r.ShortName, Name: r.LongName, Background: bg, Foreground: fg}
+}
+
+// stationOf maps platforms to their parent station.
+func stationOf(idx *search.Index) map[string]string {
+	parent := make(map[string]string)
+	for station, platforms := range idx.StationPlatforms {
+		for _, p := range platforms {
+			parent[p] = station
+		}
+	}
+	return parent
+}
+
+// Station builds the timetable of the departures from a station.
+func Station(idx *search.Index, stationID string, validFrom, validTo time.Time) (*StationTimetable, bool) {
+	st, ok := idx.Station(stationID)
+	if !ok {
+		return nil, false
+	}
+	cal := newCalendar(idx, validFrom, validTo)
+	parent := stationOf(idx)
+
+	type sectionKey struct{ route, direction string }
+	sections := make(map[sectionKey]map[int]*HourRow)
+	for _, platform := range idx.StationPlatforms[stationID] {
+		for _, dep := range idx.StopDepartures[platform] {
+			stops := idx.TripStops[dep.TripID]
+			if len(stops) == 0 || stops[len(stops)-1].StopSequence == dep.StopSequence {
+				continue
+			}
+			runs, marks := cal.service(idx.TripService[dep.TripID])
+			last := stops[len(stops)-1].StopID
+			key := sectionKey{idx.TripRoute[dep.TripID], idx.StopName[parentOr(parent, last)]}
+			if sections[key] == nil {
+				sections[key] = make(map[int]*HourRow)
+			}
+			hour := dep.DepartureTime / 3600
+			row := sections[key][hour]
+			if row == nil {
+				row = &HourRow{Hour: hour}
+				sections[key][hour] = row
+			}
+			for dt := range dayTypes {
+				if runs[dt] {
+					row.Minutes[dt] = append(row.Minutes[dt], Minute{Minute: dep.DepartureTime % 3600 / 60, Mark: marks[dt]})
+				}
+			}
+		}
+	}
+
+	t := &StationTimetable{Station: st.Name, ValidFrom: cal.from, ValidTo: cal.to}
+	for key, hours := range sections {
+		s := StationSection{Route: routeOf(idx, key.route), Direction: key.direction}
+		for _, row := range hours {
+			for dt := range row.Minutes {
+				sort.SliceStable(row.Minutes[dt], func(i, j int) bool {
+					return row.Minutes[dt][i].Minute < row.Minutes[dt][j].Minute
+				})
+			}
+			s.Hours = append(s.Hours, *row)
+		}
+		sort.Slice(s.Hours, func(i, j int) bool { return s.Hours[i].Hour < s.Hours[j].Hour })
+		t.Sections = append(t.Sections, s)
+	}
+	sort.Slice(t.Sections, func(i, j int) bool {
+		a, b := t.Sections[i], t.Sections[j]
+		if a.Route.Line != b.Route.Line {
+			return search.LineLess(a.Route.Line, b.Route.Line)
+		}
+		return a.Direction < b.Direction
+	})
+	t.Footnotes = cal.notes.list
+	return t, true
+}
+
+// Line builds the timetable of a route, given by ID or short name.
+func Line(idx *search.Index, route string, validFrom, validTo time.Time) (*LineTimetable, bool) {
+	r, ok := idx.Route(route)
+	if !ok {
+		for _, candidate := range idx.Routes {
+			if candidate.ShortName == route {
+				r, ok = candidate, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	cal := newCalendar(idx, validFrom, validTo)
+	parent := stationOf(idx)
+
+	type tableKey struct{ direction, dayType int }
+	trips := make(map[tableKey][]string)
+	for tripID, routeID := range idx.TripRoute {
+		if routeID != r.ID || len(idx.TripStops[tripID]) < 2 {
+			continue
+		}
+		runs, _ := cal.service(idx.TripService[tripID])
+		for dt := range dayTypes {
+			if runs[dt] {
+				key := tableKey{idx.TripDirection[tripID], dt}
+				trips[key] = append(trips[key], tripID)
+			}
+		}
+	}
+
+	t := &LineTimetable{Route: routeOf(idx, r.ID), ValidFrom: cal.from, ValidTo: cal.to}
+	for direction := range 2 {
+		for dt := range dayTypes {
+			ids := trips[tableKey{direction, dt}]
+			if len(ids) == 0 {
+				continue
+			}
+			sort.Slice(ids, func(i, j int) bool {
+				a, b := idx.TripStops[ids[i]][0].DepartureTime, idx.TripStops[ids[j]][0].DepartureTime
+				if a != b {
+					return a < b
+				}
+				return ids[i] < ids[j]
+			})
+			t.Tables = append(t.Tables, lineTable(idx, cal, parent, ids, dt))
+		}
+	}
+	t.Footnotes = cal.notes.list
+	return t, true
+}
+
+// lineTable lays out trips as columns over the union of their stations,
+// in the order the trips visit them.
+func lineTable(idx *search.Index, cal *calendar, parent map[string]string, trips []string, dt int) LineTable {
+	patterns := make([][]string, len(trips))
+	for i, id := range trips {
+		for _, ts := range idx.TripStops[id] {
+			patterns[i] = append(patterns[i], parentOr(parent, ts.StopID))
+		}
+	}
+	longest := 0
+	for i, p := range patterns {
+		if len(p) > len(patterns[longest]) {
+			longest = i
+		}
+	}
+	rows := append([]string(nil), patterns[longest]...)
+	for _, p := range patterns {
+		rows = mergeStops(rows, p)
+	}
+
+	table := LineTable{DayType: dt, Direction: idx.StopName[rows[len(rows)-1]]}
+	for _, station := range rows {
+		table.Stops = append(table.Stops, idx.StopName[station])
+	}
+	for i, id := range trips {
+		_, marks := cal.service(idx.TripService[id])
+		col := TripColumn{Mark: marks[dt], Times: make([]int, len(rows))}
+		for j := range col.Times {
+			col.Times[j] = -1
+		}
+		next := 0
+		for k, ts := range idx.TripStops[id] {
+			for j := next; j < len(rows); j++ {
+				if rows[j] == patterns[i][k] {
+					col.Times[j] = ts.DepartureTime
+					next = j + 1
+					break
+				}
+			}
+		}
+		table.Trips = append(table.Trips, col)
+	}
+	return table
+}
+
+// mergeStops inserts the stations of pattern missing from rows after the
+// last station they have in common.
+func mergeStops(rows, pattern []string) []string {
+	pos := 0
+	for _, station := range pattern {
+		found := -1
+		for j := pos; j < len(rows); j++ {
+			if rows[j] == station {
+				found = j
+				break
+			}
+		}
+		if found >= 0 {
+			pos = found + 1
+			continue
+		}
+		rows = append(rows[:pos], append([]string{station}, rows[pos:]...)...)
+		pos++
+	}
+	return rows
+}
+
+func parentOr(parent map[string]string, stopID string) string {
+	if p, ok := parent[stopID]; ok {
+		return p
+	}
+	return stopID
+}
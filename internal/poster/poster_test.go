@@ -0,0 +1,237 @@
+package poster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+// The tests cover four weeks from Monday 2 March 2026.
+var (
+	periodFrom = time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	periodTo   = time.Date(2026, 3, 29, 0, 0, 0, 0, time.Local)
+)
+
+// weekly returns a calendar running on the given days ("1111100" is Monday
+// to Friday) over the test period, or between the given dates.
+func weekly(service, days string, dates ...string) gtfs.Calendar {
+	start, end := "20260302", "20260329"
+	if len(dates) == 2 {
+		start, end = dates[0], dates[1]
+	}
+	on := func(i int) bool { return days[i] == '1' }
+	return gtfs.Calendar{ServiceID: service, Monday: on(0), Tuesday: on(1), Wednesday: on(2), Thursday: on(3),
+		Friday: on(4), Saturday: on(5), Sunday: on(6), StartDate: start, EndDate: end}
+}
+
+func TestRestriction(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar []gtfs.Calendar
+		dates    []gtfs.CalendarDate
+		dayType  int
+		// to extends the test period.
+		to       time.Time
+		want     string
+		wantRuns bool
+	}{
+		{
+			name:     "weekdays",
+			calendar: []gtfs.Calendar{weekly("S", "1111100")},
+			dayType:  Weekday, wantRuns: true,
+		},
+		{
+			name:     "weekdays on Saturday",
+			calendar: []gtfs.Calendar{weekly("S", "1111100")},
+			dayType:  Saturday,
+		},
+		{
+			name:     "some weekdays",
+			calendar: []gtfs.Calendar{weekly("S", "1010100")},
+			dayType:  Weekday, wantRuns: true,
+			want: "jede v pondělí, ve středu, v pátek",
+		},
+		{
+			name:     "weekend on Sunday",
+			calendar: []gtfs.Calendar{weekly("S", "0000011")},
+			dayType:  Sunday, wantRuns: true,
+		},
+		{
+			name:     "weekdays with a holiday",
+			calendar: []gtfs.Calendar{weekly("S", "1111100")},
+			dates:    []gtfs.CalendarDate{{ServiceID: "S", Date: "20260310", ExceptionType: 2}},
+			dayType:  Weekday, wantRuns: true,
+			want: "nejede 10.3.",
+		},
+		{
+			name:     "extra day",
+			calendar: []gtfs.Calendar{weekly("S", "1111000")},
+			dates:    []gtfs.CalendarDate{{ServiceID: "S", Date: "20260313", ExceptionType: 1}},
+			dayType:  Weekday, wantRuns: true,
+			want: "jede v pondělí, v úterý, ve středu, ve čtvrtek; jede také 13.3.",
+		},
+		{
+			name:     "Saturday service on a weekday",
+			calendar: []gtfs.Calendar{weekly("S", "0000010")},
+			dates:    []gtfs.CalendarDate{{ServiceID: "S", Date: "20260311", ExceptionType: 1}},
+			dayType:  Weekday, wantRuns: true,
+			want: "jede jen 11.3.",
+		},
+		{
+			name:    "only exceptions",
+			dates:   []gtfs.CalendarDate{{ServiceID: "S", Date: "20260307", ExceptionType: 1}, {ServiceID: "S", Date: "20260321", ExceptionType: 1}},
+			dayType: Saturday, wantRuns: true,
+			want: "jede jen 7.3., 21.3.",
+		},
+		{
+			name:     "starts later",
+			calendar: []gtfs.Calendar{weekly("S", "1111100", "20260316", "20260426")},
+			dayType:  Weekday, wantRuns: true,
+			to:   time.Date(2026, 4, 26, 0, 0, 0, 0, time.Local),
+			want: "jede od 16.3.",
+		},
+		{
+			name:     "ends earlier",
+			calendar: []gtfs.Calendar{weekly("S", "1111100", "20260302", "20260410")},
+			dayType:  Weekday, wantRuns: true,
+			to:   time.Date(2026, 4, 26, 0, 0, 0, 0, time.Local),
+			want: "jede do 10.4.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := &search.Index{Calendars: tt.calendar, CalendarDates: tt.dates}
+			to := periodTo
+			if !tt.to.IsZero() {
+				to = tt.to
+			}
+			c := newCalendar(idx, periodFrom, to)
+			got, runs := c.restriction("S", tt.dayType)
+			if got != tt.want || runs != tt.wantRuns {
+				t.Errorf("restriction = %q, %v; want %q, %v", got, runs, tt.want, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	idx := &search.Index{
+		Calendars: []gtfs.Calendar{
+			weekly("full", "1111111"),
+			weekly("holiday", "1111100"),
+			weekly("holiday2", "1111100"),
+			weekly("mwf", "1010100"),
+		},
+		CalendarDates: []gtfs.CalendarDate{
+			{ServiceID: "holiday", Date: "20260310", ExceptionType: 2},
+			{ServiceID: "holiday2", Date: "20260310", ExceptionType: 2},
+		},
+	}
+	c := newCalendar(idx, periodFrom, periodTo)
+
+	marks := func(service string) [dayTypes]string {
+		_, m := c.service(service)
+		return m
+	}
+	if m := marks("full"); m != [dayTypes]string{} {
+		t.Errorf("unrestricted service has marks %q", m)
+	}
+	if m := marks("mwf"); m != [dayTypes]string{Weekday: "a"} {
+		t.Errorf("first restricted service has marks %q, want a on weekdays", m)
+	}
+	if m := marks("holiday"); m != [dayTypes]string{Weekday: "b"} {
+		t.Errorf("second restricted service has marks %q, want b on weekdays", m)
+	}
+	// The same restriction shares its mark, also when asked again.
+	if m := marks("holiday2"); m != [dayTypes]string{Weekday: "b"} {
+		t.Errorf("service with the same restriction has marks %q, want b", m)
+	}
+	if m := marks("mwf"); m != [dayTypes]string{Weekday: "a"} {
+		t.Errorf("repeated lookup has marks %q, want a", m)
+	}
+
+	want := []Footnote{{"a", "jede v pondělí, ve středu, v pátek"}, {"b", "nejede 10.3."}}
+	if !slices.Equal(c.notes.list, want) {
+		t.Errorf("footnotes = %v, want %v", c.notes.list, want)
+	}
+}
+
+func TestFootnoteMark(t *testing.T) {
+	for n, want := range map[int]string{0: "a", 1: "b", 25: "z", 26: "aa", 27: "ab", 51: "az", 52: "ba", 701: "zz", 702: "aaa"} {
+		if got := footnoteMark(n); got != want {
+			t.Errorf("footnoteMark(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestMergeStops(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows, pattern []string
+		want          []string
+	}{
+		{"same pattern", []string{"A", "B", "C"}, []string{"A", "B", "C"}, []string{"A", "B", "C"}},
+		{"subset", []string{"A", "B", "C"}, []string{"A", "C"}, []string{"A", "B", "C"}},
+		{"detour", []string{"A", "B", "C"}, []string{"A", "X", "C"}, []string{"A", "X", "B", "C"}},
+		{"new start", []string{"B", "C"}, []string{"A", "B"}, []string{"A", "B", "C"}},
+		{"extension", []string{"A", "B"}, []string{"B", "C", "D"}, []string{"A", "B", "C", "D"}},
+		{"loop visits a station twice", []string{"A", "B", "A"}, []string{"A", "B", "C", "A"}, []string{"A", "B", "C", "A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := append([]string(nil), tt.rows...)
+			if got := mergeStops(rows, tt.pattern); !slices.Equal(got, tt.want) {
+				t.Errorf("mergeStops(%v, %v) = %v, want %v", tt.rows, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLineMergesPlatforms checks that trips calling at different platforms
+// of a station share its row.
+func TestLineMergesPlatforms(t *testing.T) {
+	feed := &gtfs.Feed{
+		Routes:    []gtfs.Route{{ID: "R", ShortName: "5"}},
+		Calendars: []gtfs.Calendar{weekly("S", "1111100")},
+	}
+	for _, s := range []struct{ id, name, parent string }{
+		{"A", "Alpha", ""}, {"A1", "Alpha", "A"}, {"A2", "Alpha", "A"},
+		{"B", "Beta", ""}, {"B1", "Beta", "B"},
+		{"C", "Gamma", ""}, {"C1", "Gamma", "C"},
+	} {
+		typ := 0
+		if s.parent == "" {
+			typ = 1
+		}
+		feed.Stops = append(feed.Stops, gtfs.Stop{ID: s.id, Name: s.name, ParentStation: s.parent, LocationType: typ})
+	}
+	trip := func(id string, start int, stops ...string) {
+		feed.Trips = append(feed.Trips, gtfs.Trip{RouteID: "R", ServiceID: "S", TripID: id})
+		for i, stop := range stops {
+			at := start + i*300
+			feed.StopTimes = append(feed.StopTimes, gtfs.StopTime{TripID: id, StopID: stop, StopSequence: i + 1, ArrivalTime: at, DepartureTime: at})
+		}
+	}
+	trip("T1", 7*3600, "A1", "B1", "C1")
+	trip("T2", 8*3600, "A2", "C1")
+
+	lt, ok := Line(search.BuildIndex(feed), "5", periodFrom, periodTo)
+	if !ok {
+		t.Fatal("line not found")
+	}
+	if len(lt.Tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(lt.Tables))
+	}
+	table := lt.Tables[0]
+	if want := []string{"Alpha", "Beta", "Gamma"}; !slices.Equal(table.Stops, want) {
+		t.Errorf("stops = %v, want %v", table.Stops, want)
+	}
+	got := fmt.Sprint(table.Trips)
+	if want := fmt.Sprint([]TripColumn{{Times: []int{25200, 25500, 25800}}, {Times: []int{28800, -1, 29100}}}); got != want {
+		t.Errorf("trips = %s, want %s", got, want)
+	}
+}
@@ -1,7 +1,10 @@
 package search
 
 import (
+	"slices"
+	"sort"
 	"time"
+
 	"timetable/internal/gtfs"
 )
 
@@ -53,3 +56,83 @@ func matchesWeekday(cal gtfs.Calendar, wd time.Weekday) bool {
 	}
 	return false
 }
+
+// ServicePattern describes the days a service runs as a weekly rule from
+// calendar.txt plus the exceptions from calendar_dates.txt.
+type ServicePattern struct {
+	// Weekdays is empty for services defined only by calendar_dates.txt.
+	Weekdays   []time.Weekday
+	Start, End time.Time
+	// Removed are days matching the rule the service does not run on;
+	// Added are days outside the rule it does run on.
+	Removed []time.Time
+	Added   []time.Time
+}
+
+// ServiceDays returns the pattern of the given service, with dates at
+// midnight local time. It reports false if the service never runs.
+func ServiceDays(calendars []gtfs.Calendar, calendarDates []gtfs.CalendarDate, serviceID string) (ServicePattern, bool) {
+	var p ServicePattern
+	ruleDay := func(time.Time) bool { return false }
+	for _, cal := range calendars {
+		if cal.ServiceID != serviceID {
+			continue
+		}
+		start, err1 := time.ParseInLocation("20060102", cal.StartDate, time.Local)
+		end, err2 := time.ParseInLocation("20060102", cal.EndDate, time.Local)
+		if err1 != nil || err2 != nil {
+			break
+		}
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if matchesWeekday(cal, wd) {
+				p.Weekdays = append(p.Weekdays, wd)
+			}
+		}
+		p.Start, p.End = start, end
+		ruleDay = func(d time.Time) bool {
+			return !d.Before(start) && !d.After(end) && matchesWeekday(cal, d.Weekday())
+		}
+		break
+	}
+
+	for _, cd := range calendarDates {
+		if cd.ServiceID != serviceID {
+			continue
+		}
+		d, err := time.ParseInLocation("20060102", cd.Date, time.Local)
+		if err != nil {
+			continue
+		}
+		switch {
+		case cd.ExceptionType == 1 && !ruleDay(d):
+			p.Added = append(p.Added, d)
+		case cd.ExceptionType == 2 && ruleDay(d):
+			p.Removed = append(p.Removed, d)
+		}
+	}
+	sortDates(p.Added)
+	sortDates(p.Removed)
+
+	if len(p.Weekdays) == 0 {
+		p.Start, p.End = time.Time{}, time.Time{}
+		return p, len(p.Added) > 0
+	}
+	return p, true
+}
+
+// First returns the first day of the weekly rule, if it has any.
+func (p ServicePattern) First() (time.Time, bool) {
+	if len(p.Weekdays) == 0 {
+		return time.Time{}, false
+	}
+	for d := p.Start; !d.After(p.End); d = d.AddDate(0, 0, 1) {
+		if slices.Contains(p.Weekdays, d.Weekday()) {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"time"
+
+	"timetable/internal/gtfs"
 )
 
 type Connection struct {
@@ -16,9 +18,16 @@ type Connection struct {
 	Duration      int
 	FromStop      string
 	ToStop        string
+	FromStopID    string
+	ToStopID      string
 }
 
+// FormatTime formats a GTFS time as HH:MM on a 24-hour clock, or returns
+// an empty string for gtfs.NoTime.
 func FormatTime(seconds int) string {
+	if seconds == gtfs.NoTime {
+		return ""
+	}
 	h := seconds / 3600
 	m := (seconds % 3600) / 60
 	if h >= 24 {
@@ -27,6 +36,24 @@ func FormatTime(seconds int) string {
 	return fmt.Sprintf("%02d:%02d", h, m)
 }
 
+// ResolveTime converts a GTFS time of day on the given service date to an
+// absolute time. GTFS times are measured from noon minus 12 hours, which
+// differs from midnight on days with a DST change.
+func ResolveTime(date time.Time, seconds int) time.Time {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.Local)
+	return noon.Add(time.Duration(seconds-12*3600) * time.Second)
+}
+
+// ResolveStopTime is ResolveTime for a stop time that may be gtfs.NoTime,
+// which resolves to nil.
+func ResolveStopTime(date time.Time, seconds int) *time.Time {
+	if seconds == gtfs.NoTime {
+		return nil
+	}
+	t := ResolveTime(date, seconds)
+	return &t
+}
+
 func (idx *Index) FindConnections(fromStationID, toStationID string, currentTime int, windowMinutes int, date time.Time) []Connection {
 	activeServices := ActiveServices(idx.Calendars, idx.CalendarDates, date)
 
@@ -56,7 +83,7 @@ func (idx *Index) FindConnections(fromStationID, toStationID string, currentTime
 			if dep.DepartureTime > endTime {
 				break
 			}
-			if c, ok := idx.checkTrip(dep, toPlatformSet, activeServices); ok {
+			if c, ok := idx.checkTrip(platformID, dep, toPlatformSet, activeServices); ok {
 				connections = append(connections, c)
 			}
 		}
@@ -74,7 +101,7 @@ func (idx *Index) FindConnections(fromStationID, toStationID string, currentTime
 				if dep.DepartureTime > searchTo {
 					break
 				}
-				if c, ok := idx.checkTrip(dep, toPlatformSet, prevDayServices); ok {
+				if c, ok := idx.checkTrip(platformID, dep, toPlatformSet, prevDayServices); ok {
 					c.DepartureTime -= 24 * 3600
 					c.ArrivalTime -= 24 * 3600
 					connections = append(connections, c)
@@ -92,7 +119,32 @@ func (idx *Index) FindConnections(fromStationID, toStationID string, currentTime
 	return connections
 }
 
-func (idx *Index) checkTrip(dep Departure, toPlatformSet map[string]bool, activeServices map[string]bool) (Connection, bool) {
+// TripConnection returns the ride on a trip from one station to another,
+// regardless of the days the trip runs.
+func (idx *Index) TripConnection(tripID, fromStationID, toStationID string) (Connection, bool) {
+	fromPlatforms := make(map[string]bool)
+	for _, p := range idx.StationPlatforms[fromStationID] {
+		fromPlatforms[p] = true
+	}
+	toPlatformSet := make(map[string]bool)
+	for _, p := range idx.StationPlatforms[toStationID] {
+		toPlatformSet[p] = true
+	}
+	always := map[string]bool{idx.TripService[tripID]: true}
+
+	for _, ts := range idx.TripStops[tripID] {
+		if !fromPlatforms[ts.StopID] || ts.DepartureTime == gtfs.NoTime {
+			continue
+		}
+		dep := Departure{TripID: tripID, DepartureTime: ts.DepartureTime, StopSequence: ts.StopSequence}
+		if c, ok := idx.checkTrip(ts.StopID, dep, toPlatformSet, always); ok {
+			return c, true
+		}
+	}
+	return Connection{}, false
+}
+
+func (idx *Index) checkTrip(platformID string, dep Departure, toPlatformSet map[string]bool, activeServices map[string]bool) (Connection, bool) {
 	serviceID := idx.TripService[dep.TripID]
 	if !activeServices[serviceID] {
 		return Connection{}, false
@@ -103,7 +155,7 @@ func (idx *Index) checkTrip(dep Departure, toPlatformSet map[string]bool, active
 		if ts.StopSequence <= dep.StopSequence {
 			continue
 		}
-		if toPlatformSet[ts.StopID] {
+		if toPlatformSet[ts.StopID] && ts.ArrivalTime != gtfs.NoTime {
 			routeID := idx.TripRoute[dep.TripID]
 			return Connection{
 				TripID:        dep.TripID,
@@ -113,8 +165,10 @@ func (idx *Index) checkTrip(dep Departure, toPlatformSet map[string]bool, active
 				DepartureTime: dep.DepartureTime,
 				ArrivalTime:   ts.ArrivalTime,
 				Duration:      ts.ArrivalTime - dep.DepartureTime,
-				FromStop:      idx.StopName[dep.TripID],
+				FromStop:      idx.StopName[platformID],
 				ToStop:        idx.StopName[ts.StopID],
+				FromStopID:    platformID,
+				ToStopID:      ts.StopID,
 			}, true
 		}
 	}
@@ -6,6 +6,8 @@ import (
 )
 
 type DepartureInfo struct {
+	TripID        string
+	StopID        string
 	Line          string
 	RouteType     int
 	Headsign      string
@@ -42,6 +44,8 @@ func (idx *Index) DepartureBoard(stationID string, currentTime int, windowMinute
 			}
 			routeID := idx.TripRoute[dep.TripID]
 			results = append(results, DepartureInfo{
+				TripID:        dep.TripID,
+				StopID:        platformID,
 				Line:          idx.RouteShortName[routeID],
 				RouteType:     idx.RouteType[routeID],
 				Headsign:      idx.TripHeadsign[dep.TripID],
@@ -69,6 +73,8 @@ func (idx *Index) DepartureBoard(stationID string, currentTime int, windowMinute
 				}
 				routeID := idx.TripRoute[dep.TripID]
 				results = append(results, DepartureInfo{
+					TripID:        dep.TripID,
+					StopID:        platformID,
 					Line:          idx.RouteShortName[routeID],
 					RouteType:     idx.RouteType[routeID],
 					Headsign:      idx.TripHeadsign[dep.TripID],
@@ -30,10 +30,13 @@ type Index struct {
 	TripService      map[string]string
 	TripRoute        map[string]string
 	TripHeadsign     map[string]string
+	TripDirection    map[string]int
 	RouteShortName   map[string]string
 	RouteType        map[string]int
 	StopName         map[string]string
+	PlatformCode     map[string]string
 	Stations         []Station
+	Routes           []gtfs.Route
 	Calendars        []gtfs.Calendar
 	CalendarDates    []gtfs.CalendarDate
 }
@@ -52,9 +55,11 @@ func BuildIndex(feed *gtfs.Feed) *Index {
 		TripService:      make(map[string]string),
 		TripRoute:        make(map[string]string),
 		TripHeadsign:     make(map[string]string),
+		TripDirection:    make(map[string]int),
 		RouteShortName:   make(map[string]string),
 		RouteType:        make(map[string]int),
 		StopName:         make(map[string]string),
+		PlatformCode:     make(map[string]string),
 		Calendars:        feed.Calendars,
 		CalendarDates:    feed.CalendarDates,
 	}
@@ -62,16 +67,31 @@ func BuildIndex(feed *gtfs.Feed) *Index {
 	for _, r := range feed.Routes {
 		idx.RouteShortName[r.ID] = r.ShortName
 		idx.RouteType[r.ID] = r.Type
+		idx.Routes = append(idx.Routes, r)
 	}
+	sort.SliceStable(idx.Routes, func(i, j int) bool {
+		a, b := idx.Routes[i], idx.Routes[j]
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		if len(a.ShortName) != len(b.ShortName) {
+			return len(a.ShortName) < len(b.ShortName)
+		}
+		return a.ShortName < b.ShortName
+	})
 
 	for _, t := range feed.Trips {
 		idx.TripService[t.TripID] = t.ServiceID
 		idx.TripRoute[t.TripID] = t.RouteID
 		idx.TripHeadsign[t.TripID] = t.Headsign
+		idx.TripDirection[t.TripID] = t.DirectionID
 	}
 
 	for _, s := range feed.Stops {
 		idx.StopName[s.ID] = s.Name
+		if s.PlatformCode != "" {
+			idx.PlatformCode[s.ID] = s.PlatformCode
+		}
 		if s.LocationType == 1 {
 			idx.Stations = append(idx.Stations, Station{
 				ID:             s.ID,
@@ -89,11 +109,14 @@ func BuildIndex(feed *gtfs.Feed) *Index {
 	})
 
 	for _, st := range feed.StopTimes {
-		idx.StopDepartures[st.StopID] = append(idx.StopDepartures[st.StopID], Departure{
-			TripID:        st.TripID,
-			DepartureTime: st.DepartureTime,
-			StopSequence:  st.StopSequence,
-		})
+		// Stops without a time are kept on the trip but cannot be searched.
+		if st.DepartureTime != gtfs.NoTime {
+			idx.StopDepartures[st.StopID] = append(idx.StopDepartures[st.StopID], Departure{
+				TripID:        st.TripID,
+				DepartureTime: st.DepartureTime,
+				StopSequence:  st.StopSequence,
+			})
+		}
 		idx.TripStops[st.TripID] = append(idx.TripStops[st.TripID], TripStop{
 			StopID:        st.StopID,
 			ArrivalTime:   st.ArrivalTime,
@@ -146,3 +169,23 @@ func (idx *Index) SearchStations(query string) []Station {
 	}
 	return results
 }
+
+// Route returns the route with the given ID.
+func (idx *Index) Route(id string) (gtfs.Route, bool) {
+	for _, r := range idx.Routes {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return gtfs.Route{}, false
+}
+
+// Station returns the station with the given ID.
+func (idx *Index) Station(id string) (Station, bool) {
+	for _, s := range idx.Stations {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Station{}, false
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"strconv"
+
+	"timetable/internal/gtfs"
+)
+
+// RouteColors returns the badge colours of route r as RGB: its route_color
+// and route_text_color, falling back to the web page's colours for trams
+// and other routes.
+func RouteColors(r gtfs.Route) (bg, fg [3]int) {
+	bg, ok := parseColor(r.Color)
+	if !ok {
+		bg = [3]int{0x29, 0x80, 0xb9}
+		if r.Type == 0 {
+			bg = [3]int{0xe7, 0x4c, 0x3c}
+		}
+	}
+	fg, ok = parseColor(r.TextColor)
+	if !ok {
+		fg = [3]int{0xff, 0xff, 0xff}
+	}
+	return bg, fg
+}
+
+func parseColor(hex string) ([3]int, bool) {
+	var c [3]int
+	if len(hex) != 6 {
+		return c, false
+	}
+	for i := range c {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return c, false
+		}
+		c[i] = int(v)
+	}
+	return c, true
+}
+
+// LineLess orders line names by their leading number, then by the rest of
+// the name, so that 2 < 11 < 11A < 12. Names without a leading number sort
+// after all others.
+func LineLess(a, b string) bool {
+	x, restA, okA := leadingNumber(a)
+	y, restB, okB := leadingNumber(b)
+	switch {
+	case okA && okB && x != y:
+		return x < y
+	case okA && okB:
+		return restA < restB
+	case okA != okB:
+		return okA
+	}
+	return a < b
+}
+
+func leadingNumber(s string) (int, string, bool) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	return n, s[i:], err == nil
+}
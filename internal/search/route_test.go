@@ -0,0 +1,44 @@
+package search
+
+import (
+	"slices"
+	"testing"
+
+	"timetable/internal/gtfs"
+)
+
+func TestLineLess(t *testing.T) {
+	lines := []string{"N1", "11A", "2", "X", "12", "11", "B", "101", "N10"}
+	slices.SortFunc(lines, func(a, b string) int {
+		switch {
+		case LineLess(a, b):
+			return -1
+		case LineLess(b, a):
+			return 1
+		}
+		return 0
+	})
+	want := []string{"2", "11", "11A", "12", "101", "B", "N1", "N10", "X"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("sorted lines = %v, want %v", lines, want)
+	}
+}
+
+func TestRouteColors(t *testing.T) {
+	white := [3]int{0xff, 0xff, 0xff}
+	tests := []struct {
+		name   string
+		route  gtfs.Route
+		bg, fg [3]int
+	}{
+		{"route colours", gtfs.Route{Color: "FFCC00", TextColor: "000000", Type: 3}, [3]int{0xff, 0xcc, 0x00}, [3]int{}},
+		{"tram without colours", gtfs.Route{Type: 0}, [3]int{0xe7, 0x4c, 0x3c}, white},
+		{"bus without colours", gtfs.Route{Type: 3}, [3]int{0x29, 0x80, 0xb9}, white},
+		{"invalid colours", gtfs.Route{Color: "#FFCC00", TextColor: "zz0000", Type: 3}, [3]int{0x29, 0x80, 0xb9}, white},
+	}
+	for _, tt := range tests {
+		if bg, fg := RouteColors(tt.route); bg != tt.bg || fg != tt.fg {
+			t.Errorf("%s: RouteColors = %v, %v; want %v, %v", tt.name, bg, fg, tt.bg, tt.fg)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"unsafe"
+
+	"timetable/internal/gtfs"
+)
+
+// mapEntryOverhead approximates the bookkeeping of a map entry beyond its
+// key and value: control bytes and the slack of partly filled groups.
+const mapEntryOverhead = 8
+
+// Size estimates the memory held by the index in bytes, from the number and
+// size of its entries. Strings are counted in full even where entries share
+// them, so the estimate errs on the high side.
+func (idx *Index) Size() uint64 {
+	var n uint64
+	n += mapSize(idx.StationPlatforms, func(v []string) uint64 { return sliceSize(v, stringSize) })
+	n += mapSize(idx.StopDepartures, func(v []Departure) uint64 {
+		return sliceSize(v, func(d Departure) uint64 { return uint64(len(d.TripID)) })
+	})
+	n += mapSize(idx.TripStops, func(v []TripStop) uint64 {
+		return sliceSize(v, func(s TripStop) uint64 { return uint64(len(s.StopID)) })
+	})
+	for _, m := range []map[string]string{idx.TripService, idx.TripRoute, idx.TripHeadsign, idx.RouteShortName, idx.StopName, idx.PlatformCode} {
+		n += mapSize(m, stringSize)
+	}
+	for _, m := range []map[string]int{idx.TripDirection, idx.RouteType} {
+		n += mapSize(m, func(int) uint64 { return 0 })
+	}
+	n += sliceSize(idx.Stations, func(s Station) uint64 {
+		return uint64(len(s.ID) + len(s.Name) + len(s.NormalizedName))
+	})
+	n += sliceSize(idx.Routes, func(r gtfs.Route) uint64 {
+		return uint64(len(r.ID) + len(r.AgencyID) + len(r.ShortName) + len(r.LongName) + len(r.Desc) + len(r.URL) + len(r.Color) + len(r.TextColor))
+	})
+	n += sliceSize(idx.Calendars, func(c gtfs.Calendar) uint64 {
+		return uint64(len(c.ServiceID) + len(c.StartDate) + len(c.EndDate))
+	})
+	n += sliceSize(idx.CalendarDates, func(d gtfs.CalendarDate) uint64 {
+		return uint64(len(d.ServiceID) + len(d.Date))
+	})
+	return n
+}
+
+// mapSize is the size of m's entries plus what value refers to outside
+// its own header.
+func mapSize[V any](m map[string]V, value func(V) uint64) uint64 {
+	var zero V
+	n := uint64(len(m)) * uint64(unsafe.Sizeof("")+unsafe.Sizeof(zero)+mapEntryOverhead)
+	for k, v := range m {
+		n += uint64(len(k)) + value(v)
+	}
+	return n
+}
+
+// sliceSize is the size of s's backing array plus what elem refers to
+// outside each element.
+func sliceSize[E any](s []E, elem func(E) uint64) uint64 {
+	var zero E
+	n := uint64(cap(s)) * uint64(unsafe.Sizeof(zero))
+	for _, e := range s {
+		n += elem(e)
+	}
+	return n
+}
+
+func stringSize(s string) uint64 {
+	return uint64(len(s))
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrBoardNotFound = errors.New("board not found")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Board is a live board definition. A board with To set lists connections
+// from From to To; without it, it lists all departures from From. Lines
+// optionally restricts the board to the given route short names.
+type Board struct {
+	Slug   string   `json:"slug"`
+	Name   string   `json:"name"`
+	From   string   `json:"from"`
+	To     string   `json:"to,omitempty"`
+	Lines  []string `json:"lines,omitempty"`
+	Window int      `json:"window,omitempty"`
+}
+
+// Validate checks the board definition and fills in the default window.
+func (b *Board) Validate() error {
+	if !slugPattern.MatchString(b.Slug) {
+		return fmt.Errorf("board slug %q must be lowercase letters, digits and dashes", b.Slug)
+	}
+	if b.Name == "" || b.From == "" {
+		return fmt.Errorf("board %s: name and from are required", b.Slug)
+	}
+	if b.Window == 0 {
+		b.Window = 60
+	}
+	if b.Window < 0 || b.Window > 1440 {
+		return fmt.Errorf("board %s: window must be between 1 and 1440 minutes", b.Slug)
+	}
+	return nil
+}
+
+const boardColumns = "slug, name, from_station, to_station, lines, window_minutes"
+
+func (s *Store) Boards() ([]Board, error) {
+	rows, err := s.db.Query("SELECT " + boardColumns + " FROM boards ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boards []Board
+	for rows.Next() {
+		b, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, b)
+	}
+	return boards, rows.Err()
+}
+
+func (s *Store) Board(slug string) (Board, error) {
+	b, err := scanBoard(s.db.QueryRow("SELECT "+boardColumns+" FROM boards WHERE slug = ?", slug))
+	if err == sql.ErrNoRows {
+		return Board{}, ErrBoardNotFound
+	}
+	return b, err
+}
+
+// SaveBoard creates or replaces the board with b's slug.
+func (s *Store) SaveBoard(b Board) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("INSERT OR REPLACE INTO boards ("+boardColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		b.Slug, b.Name, b.From, b.To, strings.Join(b.Lines, ","), b.Window)
+	if err != nil {
+		return fmt.Errorf("save board: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteBoard(slug string) error {
+	res, err := s.db.Exec("DELETE FROM boards WHERE slug = ?", slug)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrBoardNotFound
+	}
+	return nil
+}
+
+func scanBoard(row interface{ Scan(...any) error }) (Board, error) {
+	var (
+		b     Board
+		lines string
+	)
+	if err := row.Scan(&b.Slug, &b.Name, &b.From, &b.To, &lines, &b.Window); err != nil {
+		return Board{}, err
+	}
+	if lines != "" {
+		b.Lines = strings.Split(lines, ",")
+	}
+	return b, nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrFavoriteNotFound = errors.New("favorite not found")
+	ErrFavoriteExists   = errors.New("favorite already saved")
+	ErrTooManyFavorites = fmt.Errorf("at most %d favorites can be saved", MaxFavorites)
+)
+
+// MaxFavorites is the number of favorites a single token can hold.
+const MaxFavorites = 50
+
+const (
+	FavoriteStation    = "station"
+	FavoriteConnection = "connection"
+	FavoriteBoard      = "board"
+)
+
+// Favorite is a saved station, connection or board. Favorites belong to an
+// anonymous token rather than an account; only a hash of the token is
+// stored.
+type Favorite struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Board     string    `json:"board,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks that f has the fields its kind needs and clears the others.
+func (f *Favorite) Validate() error {
+	switch f.Kind {
+	case FavoriteStation:
+		if f.From == "" {
+			return errors.New("station favorite needs from")
+		}
+		f.To, f.Board = "", ""
+	case FavoriteConnection:
+		if f.From == "" || f.To == "" {
+			return errors.New("connection favorite needs from and to")
+		}
+		f.Board = ""
+	case FavoriteBoard:
+		if f.Board == "" {
+			return errors.New("board favorite needs board")
+		}
+		f.From, f.To = "", ""
+	default:
+		return fmt.Errorf("unknown favorite kind %q", f.Kind)
+	}
+	if len(f.Name) > 100 {
+		return errors.New("favorite name is longer than 100 characters")
+	}
+	return nil
+}
+
+// ownerKey is the value stored for a token, so a leaked database does not
+// give away usable tokens.
+func ownerKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const favoriteColumns = "id, kind, name, from_station, to_station, board, created_at"
+
+// Favorites lists the favorites of token in the order they were saved.
+func (s *Store) Favorites(token string) ([]Favorite, error) {
+	rows, err := s.db.Query("SELECT "+favoriteColumns+" FROM favorites WHERE owner = ? ORDER BY id", ownerKey(token))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var favorites []Favorite
+	for rows.Next() {
+		f, err := scanFavorite(rows)
+		if err != nil {
+			return nil, err
+		}
+		favorites = append(favorites, f)
+	}
+	return favorites, rows.Err()
+}
+
+// AddFavorite saves f for token and returns it with its ID.
+func (s *Store) AddFavorite(token string, f Favorite) (Favorite, error) {
+	if err := f.Validate(); err != nil {
+		return Favorite{}, err
+	}
+	owner := ownerKey(token)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Favorite{}, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM favorites WHERE owner = ?", owner).Scan(&n); err != nil {
+		return Favorite{}, err
+	}
+	if n >= MaxFavorites {
+		return Favorite{}, ErrTooManyFavorites
+	}
+
+	f.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	res, err := tx.Exec(`INSERT INTO favorites (owner, kind, name, from_station, to_station, board, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		owner, f.Kind, f.Name, f.From, f.To, f.Board, f.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return Favorite{}, fmt.Errorf("add favorite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Favorite{}, ErrFavoriteExists
+	}
+	if f.ID, err = res.LastInsertId(); err != nil {
+		return Favorite{}, err
+	}
+	return f, tx.Commit()
+}
+
+// RenameFavorite changes the name of a favorite of token.
+func (s *Store) RenameFavorite(token string, id int64, name string) (Favorite, error) {
+	if len(name) > 100 {
+		return Favorite{}, errors.New("favorite name is longer than 100 characters")
+	}
+	res, err := s.db.Exec("UPDATE favorites SET name = ? WHERE id = ? AND owner = ?", name, id, ownerKey(token))
+	if err != nil {
+		return Favorite{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Favorite{}, ErrFavoriteNotFound
+	}
+	return scanFavorite(s.db.QueryRow("SELECT "+favoriteColumns+" FROM favorites WHERE id = ?", id))
+}
+
+func (s *Store) DeleteFavorite(token string, id int64) error {
+	res, err := s.db.Exec("DELETE FROM favorites WHERE id = ? AND owner = ?", id, ownerKey(token))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFavoriteNotFound
+	}
+	return nil
+}
+
+func scanFavorite(row interface{ Scan(...any) error }) (Favorite, error) {
+	var (
+		f       Favorite
+		created string
+	)
+	if err := row.Scan(&f.ID, &f.Kind, &f.Name, &f.From, &f.To, &f.Board, &created); err != nil {
+		if err == sql.ErrNoRows {
+			return Favorite{}, ErrFavoriteNotFound
+		}
+		return Favorite{}, err
+	}
+	f.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return f, nil
+}
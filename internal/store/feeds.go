@@ -0,0 +1,273 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"timetable/internal/gtfs"
+)
+
+var ErrFeedNotFound = errors.New("feed not found")
+
+// feedTables lists every table keyed by feed_id.
+var feedTables = []string{
+	"agency", "feed_info", "stops", "routes", "trips", "stop_times", "calendar", "calendar_dates",
+	"transfers", "shapes", "frequencies", "fare_attributes", "fare_rules", "levels", "pathways",
+}
+
+// FeedRecord describes one imported feed version. ValidFrom and ValidTo are
+// GTFS dates (YYYYMMDD) and may be empty if the feed does not declare them.
+type FeedRecord struct {
+	ID         int64
+	Version    string
+	ValidFrom  string
+	ValidTo    string
+	ImportedAt time.Time
+}
+
+const feedColumns = "feed_id, feed_version, valid_from, valid_to, imported_at"
+
+// Feeds returns all stored feed versions, newest first.
+func (s *Store) Feeds() ([]FeedRecord, error) {
+	rows, err := s.db.Query("SELECT " + feedColumns + " FROM feeds ORDER BY feed_id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []FeedRecord
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+// LatestFeed returns the most recently imported feed.
+func (s *Store) LatestFeed() (FeedRecord, error) {
+	return s.queryFeed("SELECT " + feedColumns + " FROM feeds ORDER BY feed_id DESC LIMIT 1")
+}
+
+// FeedByID returns the feed with the given ID.
+func (s *Store) FeedByID(id int64) (FeedRecord, error) {
+	return s.queryFeed("SELECT "+feedColumns+" FROM feeds WHERE feed_id = ?", id)
+}
+
+// FeedByVersion returns the newest import of the given feed_version.
+func (s *Store) FeedByVersion(version string) (FeedRecord, error) {
+	return s.queryFeed("SELECT "+feedColumns+" FROM feeds WHERE feed_version = ? ORDER BY feed_id DESC LIMIT 1", version)
+}
+
+// FeedAsOf returns the feed that was valid on the day of t. Where validity
+// ranges overlap, the feed that started last wins, as it replaced the
+// others; among imports of the same range the newest wins. Feeds without a
+// declared start date are only chosen if no other feed covers the day.
+func (s *Store) FeedAsOf(t time.Time) (FeedRecord, error) {
+	day := t.Format("20060102")
+	return s.queryFeed("SELECT "+feedColumns+" FROM feeds WHERE valid_from <= ? AND (valid_to = '' OR valid_to >= ?) ORDER BY valid_from DESC, feed_id DESC LIMIT 1",
+		day, day)
+}
+
+func (s *Store) queryFeed(query string, args ...any) (FeedRecord, error) {
+	f, err := scanFeed(s.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return FeedRecord{}, ErrFeedNotFound
+	}
+	return f, err
+}
+
+func scanFeed(row interface{ Scan(...any) error }) (FeedRecord, error) {
+	var (
+		f          FeedRecord
+		importedAt string
+	)
+	if err := row.Scan(&f.ID, &f.Version, &f.ValidFrom, &f.ValidTo, &importedAt); err != nil {
+		return FeedRecord{}, err
+	}
+	f.ImportedAt, _ = time.Parse(time.RFC3339, importedAt)
+	return f, nil
+}
+
+// Prune deletes all but the newest keep feeds, never deleting the feeds
+// listed in protect. It returns the number of feeds removed.
+func (s *Store) Prune(keep int, protect ...int64) (int, error) {
+	feeds, err := s.Feeds()
+	if err != nil {
+		return 0, err
+	}
+	protected := make(map[int64]bool, len(protect))
+	for _, id := range protect {
+		protected[id] = true
+	}
+
+	var stale []int64
+	for i, f := range feeds {
+		if i >= keep && !protected[f.ID] {
+			stale = append(stale, f.ID)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(stale)), ", ")
+	args := make([]any, len(stale))
+	for i, id := range stale {
+		args[i] = id
+	}
+	for _, t := range append(feedTables, "feeds") {
+		if _, err := tx.Exec("DELETE FROM "+t+" WHERE feed_id IN ("+placeholders+")", args...); err != nil {
+			return 0, fmt.Errorf("prune %s: %w", t, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	slog.Debug("Pruned feed versions", "feed_ids", stale)
+	return len(stale), nil
+}
+
+// LoadFeed reads a stored feed version back from the database. Only the
+// tables needed to build a search index are loaded.
+func (s *Store) LoadFeed(id int64) (*gtfs.Feed, error) {
+	if _, err := s.FeedByID(id); err != nil {
+		return nil, err
+	}
+
+	feed := &gtfs.Feed{}
+	loaders := []struct {
+		table string
+		load  func(int64, *gtfs.Feed) error
+	}{
+		{"feed_info", s.loadFeedInfo},
+		{"stops", s.loadStops},
+		{"routes", s.loadRoutes},
+		{"trips", s.loadTrips},
+		{"stop_times", s.loadStopTimes},
+		{"calendar", s.loadCalendars},
+		{"calendar_dates", s.loadCalendarDates},
+		{"transfers", s.loadTransfers},
+	}
+	for _, l := range loaders {
+		if err := l.load(id, feed); err != nil {
+			return nil, fmt.Errorf("load %s: %w", l.table, err)
+		}
+	}
+	return feed, nil
+}
+
+// scanRows runs query for feedID and calls fn for every row.
+func (s *Store) scanRows(query string, feedID int64, fn func(*sql.Rows) error) error {
+	rows, err := s.db.Query(query, feedID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Store) loadFeedInfo(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT feed_publisher_name, feed_publisher_url, feed_lang, default_lang, feed_start_date, feed_end_date, feed_version, feed_contact_email, feed_contact_url FROM feed_info WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var fi gtfs.FeedInfo
+		if err := rows.Scan(&fi.PublisherName, &fi.PublisherURL, &fi.Lang, &fi.DefaultLang, &fi.StartDate, &fi.EndDate, &fi.Version, &fi.ContactEmail, &fi.ContactURL); err != nil {
+			return err
+		}
+		feed.FeedInfo = &fi
+		return nil
+	})
+}
+
+func (s *Store) loadStops(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding, level_id, platform_code FROM stops WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var st gtfs.Stop
+		if err := rows.Scan(&st.ID, &st.Code, &st.Name, &st.Desc, &st.Lat, &st.Lon, &st.ZoneID, &st.URL, &st.LocationType, &st.ParentStation, &st.Timezone, &st.WheelchairBoarding, &st.LevelID, &st.PlatformCode); err != nil {
+			return err
+		}
+		feed.Stops = append(feed.Stops, st)
+		return nil
+	})
+}
+
+func (s *Store) loadRoutes(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT route_id, agency_id, route_short_name, route_long_name, route_desc, route_type, route_url, route_color, route_text_color, route_sort_order, continuous_pickup, continuous_drop_off FROM routes WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var r gtfs.Route
+		if err := rows.Scan(&r.ID, &r.AgencyID, &r.ShortName, &r.LongName, &r.Desc, &r.Type, &r.URL, &r.Color, &r.TextColor, &r.SortOrder, &r.ContinuousPickup, &r.ContinuousDropOff); err != nil {
+			return err
+		}
+		feed.Routes = append(feed.Routes, r)
+		return nil
+	})
+}
+
+func (s *Store) loadTrips(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT trip_id, route_id, service_id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed FROM trips WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var t gtfs.Trip
+		if err := rows.Scan(&t.TripID, &t.RouteID, &t.ServiceID, &t.Headsign, &t.ShortName, &t.DirectionID, &t.BlockID, &t.ShapeID, &t.Wheelchair, &t.BikesAllowed); err != nil {
+			return err
+		}
+		feed.Trips = append(feed.Trips, t)
+		return nil
+	})
+}
+
+func (s *Store) loadStopTimes(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT trip_id, arrival_time, departure_time, stop_id, stop_sequence, stop_headsign, pickup_type, drop_off_type, continuous_pickup, continuous_drop_off, shape_dist_traveled, timepoint FROM stop_times WHERE feed_id = ? ORDER BY trip_id, stop_sequence", id, func(rows *sql.Rows) error {
+		var st gtfs.StopTime
+		if err := rows.Scan(&st.TripID, &st.ArrivalTime, &st.DepartureTime, &st.StopID, &st.StopSequence, &st.StopHeadsign, &st.PickupType, &st.DropOffType, &st.ContinuousPickup, &st.ContinuousDropOff, &st.ShapeDistTraveled, &st.Timepoint); err != nil {
+			return err
+		}
+		feed.StopTimes = append(feed.StopTimes, st)
+		return nil
+	})
+}
+
+func (s *Store) loadCalendars(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday, start_date, end_date FROM calendar WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var c gtfs.Calendar
+		if err := rows.Scan(&c.ServiceID, &c.Monday, &c.Tuesday, &c.Wednesday, &c.Thursday, &c.Friday, &c.Saturday, &c.Sunday, &c.StartDate, &c.EndDate); err != nil {
+			return err
+		}
+		feed.Calendars = append(feed.Calendars, c)
+		return nil
+	})
+}
+
+func (s *Store) loadCalendarDates(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT service_id, date, exception_type FROM calendar_dates WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var cd gtfs.CalendarDate
+		if err := rows.Scan(&cd.ServiceID, &cd.Date, &cd.ExceptionType); err != nil {
+			return err
+		}
+		feed.CalendarDates = append(feed.CalendarDates, cd)
+		return nil
+	})
+}
+
+func (s *Store) loadTransfers(id int64, feed *gtfs.Feed) error {
+	return s.scanRows("SELECT from_stop_id, to_stop_id, transfer_type, min_transfer_time FROM transfers WHERE feed_id = ?", id, func(rows *sql.Rows) error {
+		var t gtfs.Transfer
+		if err := rows.Scan(&t.FromStopID, &t.ToStopID, &t.TransferType, &t.MinTransferTime); err != nil {
+			return err
+		}
+		feed.Transfers = append(feed.Transfers, t)
+		return nil
+	})
+}
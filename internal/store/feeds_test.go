@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"timetable/internal/gtfs"
+)
+
+func TestFeedAsOf(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "timetable.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ids := make(map[string]int64)
+	for _, f := range []struct{ version, from, to string }{
+		{"january", "20260101", "20260131"},
+		{"february", "20260201", "20260331"},
+		// A correction of February published mid-month, overlapping it.
+		{"february-fix", "20260215", "20260331"},
+		{"undated", "", ""},
+	} {
+		id, err := s.Import(&gtfs.Feed{FeedInfo: &gtfs.FeedInfo{Version: f.version, StartDate: f.from, EndDate: f.to}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[f.version] = id
+	}
+
+	tests := []struct {
+		day  string
+		want string
+	}{
+		{"2026-01-01", "january"},
+		{"2026-01-31", "january"},
+		{"2026-02-01", "february"},
+		{"2026-02-14", "february"},
+		{"2026-02-15", "february-fix"},
+		{"2026-03-31", "february-fix"},
+		{"2026-04-01", "undated"},
+		{"2025-12-31", "undated"},
+	}
+	for _, tt := range tests {
+		day, _ := time.ParseInLocation("2006-01-02", tt.day, time.Local)
+		got, err := s.FeedAsOf(day)
+		if err != nil {
+			t.Errorf("FeedAsOf(%s): %v", tt.day, err)
+			continue
+		}
+		if got.ID != ids[tt.want] {
+			t.Errorf("FeedAsOf(%s) = %q, want %q", tt.day, got.Version, tt.want)
+		}
+	}
+
+	if _, err := s.Prune(0, ids["january"]); err != nil {
+		t.Fatal(err)
+	}
+	day, _ := time.ParseInLocation("2006-01-02", "2026-03-01", time.Local)
+	if _, err := s.FeedAsOf(day); !errors.Is(err, ErrFeedNotFound) {
+		t.Errorf("FeedAsOf after pruning = %v, want ErrFeedNotFound", err)
+	}
+}
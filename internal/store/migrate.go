@@ -0,0 +1,228 @@
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// migration is one numbered schema change, read from migrations/NNNN_name.sql.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the embedded migrations in order. Versions must
+// start at 1 and have no gaps.
+func loadMigrations() ([]migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []migration
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		num, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.sql", name)
+		}
+		body, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: label, sql: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %d_%s: expected version %d", m.version, m.name, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the version of the newest migration applied to the
+// database, or 0 for a new database.
+func (s *Store) SchemaVersion() (int, error) {
+	return schemaVersion(s.db)
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// migrate brings the database schema up to date, applying each pending
+// migration in its own transaction. It refuses databases written by a newer
+// binary.
+func migrate(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TEXT NOT NULL
+)`); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, current, len(migrations))
+	}
+	if current == 0 {
+		if err := upgradeUnversioned(db, migrations[0]); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations[current:] {
+		if err := apply(db, m); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+		slog.Info("Applied schema migration", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+func apply(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// upgradeUnversioned prepares a database written before schema versions
+// were tracked for the first migration. Those binaries created their tables
+// with CREATE TABLE IF NOT EXISTS, so the migration would keep an older,
+// narrower table and later fail with "no such column". Any existing table
+// whose columns differ from the ones the migration creates is dropped. The
+// GTFS tables only ever held copies of the feed on disk and are dropped
+// together, so the feed is imported again on startup.
+func upgradeUnversioned(db *sql.DB, initial migration) error {
+	want, err := migrationColumns(initial)
+	if err != nil {
+		return fmt.Errorf("inspect migration %d_%s: %w", initial.version, initial.name, err)
+	}
+	var stale []string
+	for table, cols := range want {
+		have, err := tableColumns(db, table)
+		if err != nil {
+			return err
+		}
+		if len(have) > 0 && !slices.Equal(have, cols) {
+			stale = append(stale, table)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	drop := stale
+	if slices.ContainsFunc(stale, isFeedTable) {
+		drop = append(slices.DeleteFunc(stale, isFeedTable), feedTables...)
+		drop = append(drop, "feeds")
+	}
+	slices.Sort(drop)
+	for _, t := range drop {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + t); err != nil {
+			return fmt.Errorf("drop legacy %s: %w", t, err)
+		}
+	}
+	slog.Info("Dropped tables from an unversioned schema; the feed is imported again", "tables", drop)
+	return nil
+}
+
+func isFeedTable(name string) bool {
+	return name == "feeds" || slices.Contains(feedTables, name)
+}
+
+// migrationColumns applies m to an empty in-memory database and returns the
+// columns of every table it creates.
+func migrationColumns(m migration) (map[string][]string, error) {
+	mem, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer mem.Close()
+	mem.SetMaxOpenConns(1)
+	if _, err := mem.Exec(m.sql); err != nil {
+		return nil, err
+	}
+	rows, err := mem.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cols := make(map[string][]string, len(tables))
+	for _, t := range tables {
+		if cols[t], err = tableColumns(mem, t); err != nil {
+			return nil, err
+		}
+	}
+	return cols, nil
+}
+
+// tableColumns returns the column names of table in order, or nil if the
+// table does not exist.
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"timetable/internal/gtfs"
+)
+
+// createDB writes a database with the given schema, as an older binary
+// would have left it, and returns its path.
+func createDB(t *testing.T, schema string, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "timetable.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range append([]string{schema}, stmts...) {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("exec %.40q: %v", s, err)
+		}
+	}
+	return path
+}
+
+func readSchema(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestOpenMigratesOlderDatabases(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		schema    string
+		stmts     []string
+		wantFeeds int
+		wantMeta  string
+	}{
+		{
+			name:   "new database",
+			schema: "",
+		},
+		{
+			name:   "baseline schema",
+			schema: readSchema(t, "baseline.sql"),
+			stmts: []string{
+				"INSERT INTO stops (stop_id, stop_name) VALUES ('S1', 'Old stop')",
+				"INSERT INTO feed_meta (key, value) VALUES ('source_etag', 'abc')",
+			},
+			wantMeta: "abc",
+		},
+		{
+			name:   "full model without feed versions",
+			schema: readSchema(t, "full_model.sql"),
+			stmts: []string{
+				"INSERT INTO stops (stop_id, stop_name, stop_desc) VALUES ('S1', 'Old stop', 'x')",
+			},
+		},
+		{
+			name:   "unversioned schema matching the first migration",
+			schema: migrations[0].sql,
+			stmts: []string{
+				"INSERT INTO feeds (feed_version, valid_from, valid_to, imported_at) VALUES ('v1', '20260101', '20260131', '2026-01-01T00:00:00Z')",
+			},
+			wantFeeds: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(createDB(t, tt.schema, tt.stmts...))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer s.Close()
+
+			version, err := s.SchemaVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != len(migrations) {
+				t.Errorf("schema version = %d, want %d", version, len(migrations))
+			}
+			feeds, err := s.Feeds()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feeds) != tt.wantFeeds {
+				t.Errorf("got %d feeds, want %d", len(feeds), tt.wantFeeds)
+			}
+			if tt.wantMeta != "" {
+				if v, err := s.GetMeta("source_etag"); err != nil || v != tt.wantMeta {
+					t.Errorf("GetMeta = %q, %v; want %q", v, err, tt.wantMeta)
+				}
+			}
+
+			// The migrated tables accept the full model.
+			_, err = s.Import(&gtfs.Feed{
+				Stops: []gtfs.Stop{{ID: "S1", Name: "Stop", Desc: "Platform A", PlatformCode: "A"}},
+			})
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	path := createDB(t, "CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)",
+		"INSERT INTO schema_version VALUES (999, 'future', '2030-01-01T00:00:00Z')")
+	s, err := Open(path)
+	if err == nil {
+		s.Close()
+		t.Fatal("Open succeeded on a newer schema")
+	}
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Open error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestOpenKeepsMigratedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timetable.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Import(&gtfs.Feed{Stops: []gtfs.Stop{{ID: "S1", Name: "Stop"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveBoard(Board{Slug: "home", Name: "Home", From: "S1", Window: 60}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if feeds, err := s.Feeds(); err != nil || len(feeds) != 1 {
+		t.Errorf("Feeds = %d, %v; want 1 feed", len(feeds), err)
+	}
+	if _, err := s.Board("home"); err != nil {
+		t.Errorf("Board: %v", err)
+	}
+	var applied int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if migrations, _ := loadMigrations(); applied != len(migrations) {
+		t.Errorf("%d migrations recorded, want %d", applied, len(migrations))
+	}
+}
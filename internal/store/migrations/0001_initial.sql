@@ -0,0 +1,220 @@
+CREATE TABLE IF NOT EXISTS feeds (
+    feed_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_version TEXT,
+    valid_from TEXT,
+    valid_to TEXT,
+    imported_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agency (
+    feed_id INTEGER NOT NULL,
+    agency_id TEXT NOT NULL,
+    agency_name TEXT NOT NULL,
+    agency_url TEXT,
+    agency_timezone TEXT,
+    agency_lang TEXT,
+    agency_phone TEXT,
+    agency_fare_url TEXT,
+    agency_email TEXT,
+    PRIMARY KEY (feed_id, agency_id)
+);
+
+CREATE TABLE IF NOT EXISTS feed_info (
+    feed_id INTEGER NOT NULL,
+    feed_publisher_name TEXT,
+    feed_publisher_url TEXT,
+    feed_lang TEXT,
+    default_lang TEXT,
+    feed_start_date TEXT,
+    feed_end_date TEXT,
+    feed_version TEXT,
+    feed_contact_email TEXT,
+    feed_contact_url TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stops (
+    feed_id INTEGER NOT NULL,
+    stop_id TEXT NOT NULL,
+    stop_code TEXT,
+    stop_name TEXT NOT NULL,
+    stop_desc TEXT,
+    stop_lat REAL,
+    stop_lon REAL,
+    zone_id TEXT,
+    stop_url TEXT,
+    location_type INTEGER NOT NULL DEFAULT 0,
+    parent_station TEXT,
+    stop_timezone TEXT,
+    wheelchair_boarding INTEGER NOT NULL DEFAULT 0,
+    level_id TEXT,
+    platform_code TEXT,
+    PRIMARY KEY (feed_id, stop_id)
+);
+
+CREATE TABLE IF NOT EXISTS routes (
+    feed_id INTEGER NOT NULL,
+    route_id TEXT NOT NULL,
+    agency_id TEXT,
+    route_short_name TEXT,
+    route_long_name TEXT,
+    route_desc TEXT,
+    route_type INTEGER NOT NULL DEFAULT 0,
+    route_url TEXT,
+    route_color TEXT,
+    route_text_color TEXT,
+    route_sort_order INTEGER NOT NULL DEFAULT 0,
+    continuous_pickup INTEGER NOT NULL DEFAULT 1,
+    continuous_drop_off INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (feed_id, route_id)
+);
+
+CREATE TABLE IF NOT EXISTS trips (
+    feed_id INTEGER NOT NULL,
+    trip_id TEXT NOT NULL,
+    route_id TEXT NOT NULL,
+    service_id TEXT NOT NULL,
+    trip_headsign TEXT,
+    trip_short_name TEXT,
+    direction_id INTEGER,
+    block_id TEXT,
+    shape_id TEXT,
+    wheelchair_accessible INTEGER NOT NULL DEFAULT 0,
+    bikes_allowed INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (feed_id, trip_id)
+);
+
+CREATE TABLE IF NOT EXISTS stop_times (
+    feed_id INTEGER NOT NULL,
+    trip_id TEXT NOT NULL,
+    arrival_time INTEGER NOT NULL,
+    departure_time INTEGER NOT NULL,
+    stop_id TEXT NOT NULL,
+    stop_sequence INTEGER NOT NULL,
+    stop_headsign TEXT,
+    pickup_type INTEGER NOT NULL DEFAULT 0,
+    drop_off_type INTEGER NOT NULL DEFAULT 0,
+    continuous_pickup INTEGER NOT NULL DEFAULT 1,
+    continuous_drop_off INTEGER NOT NULL DEFAULT 1,
+    shape_dist_traveled REAL,
+    timepoint INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (feed_id, trip_id, stop_sequence)
+);
+
+CREATE TABLE IF NOT EXISTS calendar (
+    feed_id INTEGER NOT NULL,
+    service_id TEXT NOT NULL,
+    monday INTEGER NOT NULL,
+    tuesday INTEGER NOT NULL,
+    wednesday INTEGER NOT NULL,
+    thursday INTEGER NOT NULL,
+    friday INTEGER NOT NULL,
+    saturday INTEGER NOT NULL,
+    sunday INTEGER NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT NOT NULL,
+    PRIMARY KEY (feed_id, service_id)
+);
+
+CREATE TABLE IF NOT EXISTS calendar_dates (
+    feed_id INTEGER NOT NULL,
+    service_id TEXT NOT NULL,
+    date TEXT NOT NULL,
+    exception_type INTEGER NOT NULL,
+    PRIMARY KEY (feed_id, service_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    feed_id INTEGER NOT NULL,
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    transfer_type INTEGER NOT NULL,
+    min_transfer_time INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (feed_id, from_stop_id, to_stop_id)
+);
+
+CREATE TABLE IF NOT EXISTS shapes (
+    feed_id INTEGER NOT NULL,
+    shape_id TEXT NOT NULL,
+    shape_pt_lat REAL NOT NULL,
+    shape_pt_lon REAL NOT NULL,
+    shape_pt_sequence INTEGER NOT NULL,
+    shape_dist_traveled REAL,
+    PRIMARY KEY (feed_id, shape_id, shape_pt_sequence)
+);
+
+CREATE TABLE IF NOT EXISTS frequencies (
+    feed_id INTEGER NOT NULL,
+    trip_id TEXT NOT NULL,
+    start_time INTEGER NOT NULL,
+    end_time INTEGER NOT NULL,
+    headway_secs INTEGER NOT NULL,
+    exact_times INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (feed_id, trip_id, start_time)
+);
+
+CREATE TABLE IF NOT EXISTS fare_attributes (
+    feed_id INTEGER NOT NULL,
+    fare_id TEXT NOT NULL,
+    price REAL NOT NULL,
+    currency_type TEXT NOT NULL,
+    payment_method INTEGER NOT NULL,
+    transfers TEXT,
+    agency_id TEXT,
+    transfer_duration INTEGER,
+    PRIMARY KEY (feed_id, fare_id)
+);
+
+CREATE TABLE IF NOT EXISTS fare_rules (
+    feed_id INTEGER NOT NULL,
+    fare_id TEXT NOT NULL,
+    route_id TEXT,
+    origin_id TEXT,
+    destination_id TEXT,
+    contains_id TEXT
+);
+
+CREATE TABLE IF NOT EXISTS levels (
+    feed_id INTEGER NOT NULL,
+    level_id TEXT NOT NULL,
+    level_index REAL NOT NULL,
+    level_name TEXT,
+    PRIMARY KEY (feed_id, level_id)
+);
+
+CREATE TABLE IF NOT EXISTS pathways (
+    feed_id INTEGER NOT NULL,
+    pathway_id TEXT NOT NULL,
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    pathway_mode INTEGER NOT NULL,
+    is_bidirectional INTEGER NOT NULL,
+    length REAL,
+    traversal_time INTEGER,
+    stair_count INTEGER,
+    max_slope REAL,
+    min_width REAL,
+    signposted_as TEXT,
+    reversed_signposted_as TEXT,
+    PRIMARY KEY (feed_id, pathway_id)
+);
+
+CREATE TABLE IF NOT EXISTS feed_meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stop_times_stop_dep ON stop_times(feed_id, stop_id, departure_time);
+CREATE INDEX IF NOT EXISTS idx_stops_parent ON stops(feed_id, parent_station);
+CREATE INDEX IF NOT EXISTS idx_trips_service ON trips(feed_id, service_id);
+CREATE INDEX IF NOT EXISTS idx_fare_rules_feed ON fare_rules(feed_id);
+CREATE INDEX IF NOT EXISTS idx_feed_info_feed ON feed_info(feed_id);
+
+CREATE TABLE IF NOT EXISTS update_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    started_at TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    feed_version TEXT,
+    changed INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
@@ -0,0 +1,8 @@
+CREATE TABLE boards (
+    slug TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    from_station TEXT NOT NULL,
+    to_station TEXT NOT NULL DEFAULT '',
+    lines TEXT NOT NULL DEFAULT '',
+    window_minutes INTEGER NOT NULL DEFAULT 60
+);
@@ -0,0 +1,11 @@
+CREATE TABLE favorites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    from_station TEXT NOT NULL DEFAULT '',
+    to_station TEXT NOT NULL DEFAULT '',
+    board TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    UNIQUE (owner, kind, from_station, to_station, board)
+);
@@ -2,16 +2,15 @@ package store
 
 import (
 	"database/sql"
-	_ "embed"
 	"fmt"
+	"log/slog"
+	"time"
+
 	"timetable/internal/gtfs"
 
 	_ "modernc.org/sqlite"
 )
 
-//go:embed schema.sql
-var schemaSQL string
-
 type Store struct {
 	db *sql.DB
 }
@@ -21,9 +20,9 @@ func Open(dbPath string) (*Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-	if _, err := db.Exec(schemaSQL); err != nil {
+	if err := migrate(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("create schema: %w", err)
+		return nil, fmt.Errorf("migrate: %w", err)
 	}
 	return &Store{db: db}, nil
 }
@@ -34,150 +33,300 @@ func (s *Store) Close() error {
 
 func (s *Store) IsEmpty() (bool, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM stops").Scan(&count)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM feeds").Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count == 0, nil
 }
 
-func (s *Store) Import(feed *gtfs.Feed) error {
+// Import stores feed as a new version alongside the ones already in the
+// database and returns its feed ID.
+func (s *Store) Import(feed *gtfs.Feed) (int64, error) {
+	start := time.Now()
 	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback()
 
-	tables := []string{"stop_times", "trips", "routes", "stops", "calendar", "calendar_dates", "transfers", "feed_meta"}
-	for _, t := range tables {
-		if _, err := tx.Exec("DELETE FROM " + t); err != nil {
-			return fmt.Errorf("clear %s: %w", t, err)
-		}
+	version := ""
+	if feed.FeedInfo != nil {
+		version = feed.FeedInfo.Version
+	}
+	validFrom, validTo := feed.ValidityRange()
+	res, err := tx.Exec("INSERT INTO feeds (feed_version, valid_from, valid_to, imported_at) VALUES (?, ?, ?, ?)",
+		version, validFrom, validTo, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("insert feed: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
 	}
 
-	if err := importStops(tx, feed.Stops); err != nil {
-		return err
+	if err := importAgencies(tx, id, feed.Agencies); err != nil {
+		return 0, err
+	}
+	if err := importFeedInfo(tx, id, feed.FeedInfo); err != nil {
+		return 0, err
+	}
+	if err := importStops(tx, id, feed.Stops); err != nil {
+		return 0, err
+	}
+	if err := importRoutes(tx, id, feed.Routes); err != nil {
+		return 0, err
 	}
-	if err := importRoutes(tx, feed.Routes); err != nil {
-		return err
+	if err := importTrips(tx, id, feed.Trips); err != nil {
+		return 0, err
 	}
-	if err := importTrips(tx, feed.Trips); err != nil {
-		return err
+	if err := importCalendars(tx, id, feed.Calendars); err != nil {
+		return 0, err
 	}
-	if err := importCalendars(tx, feed.Calendars); err != nil {
-		return err
+	if err := importCalendarDates(tx, id, feed.CalendarDates); err != nil {
+		return 0, err
 	}
-	if err := importCalendarDates(tx, feed.CalendarDates); err != nil {
-		return err
+	if err := importStopTimes(tx, id, feed.StopTimes); err != nil {
+		return 0, err
 	}
-	if err := importStopTimes(tx, feed.StopTimes); err != nil {
-		return err
+	if err := importTransfers(tx, id, feed.Transfers); err != nil {
+		return 0, err
 	}
-	if err := importTransfers(tx, feed.Transfers); err != nil {
-		return err
+	if err := importShapes(tx, id, feed.Shapes); err != nil {
+		return 0, err
+	}
+	if err := importFrequencies(tx, id, feed.Frequencies); err != nil {
+		return 0, err
+	}
+	if err := importFareAttributes(tx, id, feed.FareAttributes); err != nil {
+		return 0, err
+	}
+	if err := importFareRules(tx, id, feed.FareRules); err != nil {
+		return 0, err
+	}
+	if err := importLevels(tx, id, feed.Levels); err != nil {
+		return 0, err
+	}
+	if err := importPathways(tx, id, feed.Pathways); err != nil {
+		return 0, err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	slog.Info("Imported feed", "feed_id", id, "feed_version", version,
+		"stop_times", len(feed.StopTimes), "duration", time.Since(start).Round(time.Millisecond).String())
+	return id, nil
 }
 
-func importStops(tx *sql.Tx, stops []gtfs.Stop) error {
-	stmt, err := tx.Prepare("INSERT INTO stops (stop_id, stop_code, stop_name, stop_lat, stop_lon, location_type, parent_station, wheelchair_boarding) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+func importAgencies(tx *sql.Tx, feedID int64, agencies []gtfs.Agency) error {
+	stmt, err := tx.Prepare("INSERT INTO agency (feed_id, agency_id, agency_name, agency_url, agency_timezone, agency_lang, agency_phone, agency_fare_url, agency_email) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare agency: %w", err)
+	}
+	defer stmt.Close()
+	for _, a := range agencies {
+		if _, err := stmt.Exec(feedID, a.ID, a.Name, a.URL, a.Timezone, a.Lang, a.Phone, a.FareURL, a.Email); err != nil {
+			return fmt.Errorf("insert agency %s: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+func importFeedInfo(tx *sql.Tx, feedID int64, info *gtfs.FeedInfo) error {
+	if info == nil {
+		return nil
+	}
+	_, err := tx.Exec("INSERT INTO feed_info (feed_id, feed_publisher_name, feed_publisher_url, feed_lang, default_lang, feed_start_date, feed_end_date, feed_version, feed_contact_email, feed_contact_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		feedID, info.PublisherName, info.PublisherURL, info.Lang, info.DefaultLang, info.StartDate, info.EndDate, info.Version, info.ContactEmail, info.ContactURL)
+	if err != nil {
+		return fmt.Errorf("insert feed_info: %w", err)
+	}
+	return nil
+}
+
+func importStops(tx *sql.Tx, feedID int64, stops []gtfs.Stop) error {
+	stmt, err := tx.Prepare("INSERT INTO stops (feed_id, stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding, level_id, platform_code) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare stops: %w", err)
 	}
 	defer stmt.Close()
 	for _, s := range stops {
-		if _, err := stmt.Exec(s.ID, s.Code, s.Name, s.Lat, s.Lon, s.LocationType, s.ParentStation, s.WheelchairBoarding); err != nil {
+		if _, err := stmt.Exec(feedID, s.ID, s.Code, s.Name, s.Desc, s.Lat, s.Lon, s.ZoneID, s.URL, s.LocationType, s.ParentStation, s.Timezone, s.WheelchairBoarding, s.LevelID, s.PlatformCode); err != nil {
 			return fmt.Errorf("insert stop %s: %w", s.ID, err)
 		}
 	}
 	return nil
 }
 
-func importRoutes(tx *sql.Tx, routes []gtfs.Route) error {
-	stmt, err := tx.Prepare("INSERT INTO routes (route_id, agency_id, route_short_name, route_long_name, route_type) VALUES (?, ?, ?, ?, ?)")
+func importRoutes(tx *sql.Tx, feedID int64, routes []gtfs.Route) error {
+	stmt, err := tx.Prepare("INSERT INTO routes (feed_id, route_id, agency_id, route_short_name, route_long_name, route_desc, route_type, route_url, route_color, route_text_color, route_sort_order, continuous_pickup, continuous_drop_off) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare routes: %w", err)
 	}
 	defer stmt.Close()
 	for _, r := range routes {
-		if _, err := stmt.Exec(r.ID, r.AgencyID, r.ShortName, r.LongName, r.Type); err != nil {
+		if _, err := stmt.Exec(feedID, r.ID, r.AgencyID, r.ShortName, r.LongName, r.Desc, r.Type, r.URL, r.Color, r.TextColor, r.SortOrder, r.ContinuousPickup, r.ContinuousDropOff); err != nil {
 			return fmt.Errorf("insert route %s: %w", r.ID, err)
 		}
 	}
 	return nil
 }
 
-func importTrips(tx *sql.Tx, trips []gtfs.Trip) error {
-	stmt, err := tx.Prepare("INSERT INTO trips (trip_id, route_id, service_id, trip_headsign, direction_id, shape_id, wheelchair_accessible) VALUES (?, ?, ?, ?, ?, ?, ?)")
+func importTrips(tx *sql.Tx, feedID int64, trips []gtfs.Trip) error {
+	stmt, err := tx.Prepare("INSERT INTO trips (feed_id, trip_id, route_id, service_id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare trips: %w", err)
 	}
 	defer stmt.Close()
 	for _, t := range trips {
-		if _, err := stmt.Exec(t.TripID, t.RouteID, t.ServiceID, t.Headsign, t.DirectionID, t.ShapeID, t.Wheelchair); err != nil {
+		if _, err := stmt.Exec(feedID, t.TripID, t.RouteID, t.ServiceID, t.Headsign, t.ShortName, t.DirectionID, t.BlockID, t.ShapeID, t.Wheelchair, t.BikesAllowed); err != nil {
 			return fmt.Errorf("insert trip %s: %w", t.TripID, err)
 		}
 	}
 	return nil
 }
 
-func importCalendars(tx *sql.Tx, cals []gtfs.Calendar) error {
-	stmt, err := tx.Prepare("INSERT INTO calendar (service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday, start_date, end_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+func importCalendars(tx *sql.Tx, feedID int64, cals []gtfs.Calendar) error {
+	stmt, err := tx.Prepare("INSERT INTO calendar (feed_id, service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday, start_date, end_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare calendar: %w", err)
 	}
 	defer stmt.Close()
 	for _, c := range cals {
-		if _, err := stmt.Exec(c.ServiceID, boolToInt(c.Monday), boolToInt(c.Tuesday), boolToInt(c.Wednesday), boolToInt(c.Thursday), boolToInt(c.Friday), boolToInt(c.Saturday), boolToInt(c.Sunday), c.StartDate, c.EndDate); err != nil {
+		if _, err := stmt.Exec(feedID, c.ServiceID, boolToInt(c.Monday), boolToInt(c.Tuesday), boolToInt(c.Wednesday), boolToInt(c.Thursday), boolToInt(c.Friday), boolToInt(c.Saturday), boolToInt(c.Sunday), c.StartDate, c.EndDate); err != nil {
 			return fmt.Errorf("insert calendar %s: %w", c.ServiceID, err)
 		}
 	}
 	return nil
 }
 
-func importCalendarDates(tx *sql.Tx, dates []gtfs.CalendarDate) error {
-	stmt, err := tx.Prepare("INSERT INTO calendar_dates (service_id, date, exception_type) VALUES (?, ?, ?)")
+func importCalendarDates(tx *sql.Tx, feedID int64, dates []gtfs.CalendarDate) error {
+	stmt, err := tx.Prepare("INSERT INTO calendar_dates (feed_id, service_id, date, exception_type) VALUES (?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare calendar_dates: %w", err)
 	}
 	defer stmt.Close()
 	for _, d := range dates {
-		if _, err := stmt.Exec(d.ServiceID, d.Date, d.ExceptionType); err != nil {
+		if _, err := stmt.Exec(feedID, d.ServiceID, d.Date, d.ExceptionType); err != nil {
 			return fmt.Errorf("insert calendar_date %s/%s: %w", d.ServiceID, d.Date, err)
 		}
 	}
 	return nil
 }
 
-func importStopTimes(tx *sql.Tx, times []gtfs.StopTime) error {
-	stmt, err := tx.Prepare("INSERT INTO stop_times (trip_id, arrival_time, departure_time, stop_id, stop_sequence) VALUES (?, ?, ?, ?, ?)")
+func importStopTimes(tx *sql.Tx, feedID int64, times []gtfs.StopTime) error {
+	stmt, err := tx.Prepare("INSERT INTO stop_times (feed_id, trip_id, arrival_time, departure_time, stop_id, stop_sequence, stop_headsign, pickup_type, drop_off_type, continuous_pickup, continuous_drop_off, shape_dist_traveled, timepoint) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare stop_times: %w", err)
 	}
 	defer stmt.Close()
 	for _, st := range times {
-		if _, err := stmt.Exec(st.TripID, st.ArrivalTime, st.DepartureTime, st.StopID, st.StopSequence); err != nil {
+		if _, err := stmt.Exec(feedID, st.TripID, st.ArrivalTime, st.DepartureTime, st.StopID, st.StopSequence, st.StopHeadsign, st.PickupType, st.DropOffType, st.ContinuousPickup, st.ContinuousDropOff, st.ShapeDistTraveled, st.Timepoint); err != nil {
 			return fmt.Errorf("insert stop_time %s/%d: %w", st.TripID, st.StopSequence, err)
 		}
 	}
 	return nil
 }
 
-func importTransfers(tx *sql.Tx, transfers []gtfs.Transfer) error {
-	stmt, err := tx.Prepare("INSERT INTO transfers (from_stop_id, to_stop_id, transfer_type, min_transfer_time) VALUES (?, ?, ?, ?)")
+func importTransfers(tx *sql.Tx, feedID int64, transfers []gtfs.Transfer) error {
+	stmt, err := tx.Prepare("INSERT INTO transfers (feed_id, from_stop_id, to_stop_id, transfer_type, min_transfer_time) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare transfers: %w", err)
 	}
 	defer stmt.Close()
 	for _, t := range transfers {
-		if _, err := stmt.Exec(t.FromStopID, t.ToStopID, t.TransferType, t.MinTransferTime); err != nil {
+		if _, err := stmt.Exec(feedID, t.FromStopID, t.ToStopID, t.TransferType, t.MinTransferTime); err != nil {
 			return fmt.Errorf("insert transfer: %w", err)
 		}
 	}
 	return nil
 }
 
+func importShapes(tx *sql.Tx, feedID int64, points []gtfs.ShapePoint) error {
+	stmt, err := tx.Prepare("INSERT INTO shapes (feed_id, shape_id, shape_pt_lat, shape_pt_lon, shape_pt_sequence, shape_dist_traveled) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare shapes: %w", err)
+	}
+	defer stmt.Close()
+	for _, p := range points {
+		if _, err := stmt.Exec(feedID, p.ShapeID, p.Lat, p.Lon, p.Sequence, p.DistTraveled); err != nil {
+			return fmt.Errorf("insert shape %s/%d: %w", p.ShapeID, p.Sequence, err)
+		}
+	}
+	return nil
+}
+
+func importFrequencies(tx *sql.Tx, feedID int64, freqs []gtfs.Frequency) error {
+	stmt, err := tx.Prepare("INSERT INTO frequencies (feed_id, trip_id, start_time, end_time, headway_secs, exact_times) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare frequencies: %w", err)
+	}
+	defer stmt.Close()
+	for _, f := range freqs {
+		if _, err := stmt.Exec(feedID, f.TripID, f.StartTime, f.EndTime, f.HeadwaySecs, f.ExactTimes); err != nil {
+			return fmt.Errorf("insert frequency %s: %w", f.TripID, err)
+		}
+	}
+	return nil
+}
+
+func importFareAttributes(tx *sql.Tx, feedID int64, fares []gtfs.FareAttribute) error {
+	stmt, err := tx.Prepare("INSERT INTO fare_attributes (feed_id, fare_id, price, currency_type, payment_method, transfers, agency_id, transfer_duration) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare fare_attributes: %w", err)
+	}
+	defer stmt.Close()
+	for _, f := range fares {
+		if _, err := stmt.Exec(feedID, f.FareID, f.Price, f.CurrencyType, f.PaymentMethod, f.Transfers, f.AgencyID, f.TransferDuration); err != nil {
+			return fmt.Errorf("insert fare_attribute %s: %w", f.FareID, err)
+		}
+	}
+	return nil
+}
+
+func importFareRules(tx *sql.Tx, feedID int64, rules []gtfs.FareRule) error {
+	stmt, err := tx.Prepare("INSERT INTO fare_rules (feed_id, fare_id, route_id, origin_id, destination_id, contains_id) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare fare_rules: %w", err)
+	}
+	defer stmt.Close()
+	for _, r := range rules {
+		if _, err := stmt.Exec(feedID, r.FareID, r.RouteID, r.OriginID, r.DestinationID, r.ContainsID); err != nil {
+			return fmt.Errorf("insert fare_rule %s: %w", r.FareID, err)
+		}
+	}
+	return nil
+}
+
+func importLevels(tx *sql.Tx, feedID int64, levels []gtfs.Level) error {
+	stmt, err := tx.Prepare("INSERT INTO levels (feed_id, level_id, level_index, level_name) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare levels: %w", err)
+	}
+	defer stmt.Close()
+	for _, l := range levels {
+		if _, err := stmt.Exec(feedID, l.ID, l.Index, l.Name); err != nil {
+			return fmt.Errorf("insert level %s: %w", l.ID, err)
+		}
+	}
+	return nil
+}
+
+func importPathways(tx *sql.Tx, feedID int64, pathways []gtfs.Pathway) error {
+	stmt, err := tx.Prepare("INSERT INTO pathways (feed_id, pathway_id, from_stop_id, to_stop_id, pathway_mode, is_bidirectional, length, traversal_time, stair_count, max_slope, min_width, signposted_as, reversed_signposted_as) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare pathways: %w", err)
+	}
+	defer stmt.Close()
+	for _, p := range pathways {
+		if _, err := stmt.Exec(feedID, p.ID, p.FromStopID, p.ToStopID, p.Mode, p.IsBidirectional, p.Length, p.TraversalTime, p.StairCount, p.MaxSlope, p.MinWidth, p.SignpostedAs, p.ReversedSignpostedAs); err != nil {
+			return fmt.Errorf("insert pathway %s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
 func (s *Store) SetMeta(key, value string) error {
 	_, err := s.db.Exec("INSERT OR REPLACE INTO feed_meta (key, value) VALUES (?, ?)", key, value)
 	return err
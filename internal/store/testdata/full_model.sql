@@ -0,0 +1,178 @@
+CREATE TABLE IF NOT EXISTS agency (
+    agency_id TEXT PRIMARY KEY,
+    agency_name TEXT NOT NULL,
+    agency_url TEXT,
+    agency_timezone TEXT,
+    agency_lang TEXT,
+    agency_phone TEXT,
+    agency_fare_url TEXT,
+    agency_email TEXT
+);
+
+CREATE TABLE IF NOT EXISTS feed_info (
+    feed_publisher_name TEXT,
+    feed_publisher_url TEXT,
+    feed_lang TEXT,
+    default_lang TEXT,
+    feed_start_date TEXT,
+    feed_end_date TEXT,
+    feed_version TEXT,
+    feed_contact_email TEXT,
+    feed_contact_url TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stops (
+    stop_id TEXT PRIMARY KEY,
+    stop_code TEXT,
+    stop_name TEXT NOT NULL,
+    stop_desc TEXT,
+    stop_lat REAL,
+    stop_lon REAL,
+    zone_id TEXT,
+    stop_url TEXT,
+    location_type INTEGER NOT NULL DEFAULT 0,
+    parent_station TEXT,
+    stop_timezone TEXT,
+    wheelchair_boarding INTEGER NOT NULL DEFAULT 0,
+    level_id TEXT,
+    platform_code TEXT
+);
+
+CREATE TABLE IF NOT EXISTS routes (
+    route_id TEXT PRIMARY KEY,
+    agency_id TEXT,
+    route_short_name TEXT,
+    route_long_name TEXT,
+    route_desc TEXT,
+    route_type INTEGER NOT NULL DEFAULT 0,
+    route_url TEXT,
+    route_color TEXT,
+    route_text_color TEXT,
+    route_sort_order INTEGER NOT NULL DEFAULT 0,
+    continuous_pickup INTEGER NOT NULL DEFAULT 1,
+    continuous_drop_off INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS trips (
+    trip_id TEXT PRIMARY KEY,
+    route_id TEXT NOT NULL,
+    service_id TEXT NOT NULL,
+    trip_headsign TEXT,
+    trip_short_name TEXT,
+    direction_id INTEGER,
+    block_id TEXT,
+    shape_id TEXT,
+    wheelchair_accessible INTEGER NOT NULL DEFAULT 0,
+    bikes_allowed INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS stop_times (
+    trip_id TEXT NOT NULL,
+    arrival_time INTEGER NOT NULL,
+    departure_time INTEGER NOT NULL,
+    stop_id TEXT NOT NULL,
+    stop_sequence INTEGER NOT NULL,
+    stop_headsign TEXT,
+    pickup_type INTEGER NOT NULL DEFAULT 0,
+    drop_off_type INTEGER NOT NULL DEFAULT 0,
+    continuous_pickup INTEGER NOT NULL DEFAULT 1,
+    continuous_drop_off INTEGER NOT NULL DEFAULT 1,
+    shape_dist_traveled REAL,
+    timepoint INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (trip_id, stop_sequence)
+);
+
+CREATE TABLE IF NOT EXISTS calendar (
+    service_id TEXT PRIMARY KEY,
+    monday INTEGER NOT NULL,
+    tuesday INTEGER NOT NULL,
+    wednesday INTEGER NOT NULL,
+    thursday INTEGER NOT NULL,
+    friday INTEGER NOT NULL,
+    saturday INTEGER NOT NULL,
+    sunday INTEGER NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS calendar_dates (
+    service_id TEXT NOT NULL,
+    date TEXT NOT NULL,
+    exception_type INTEGER NOT NULL,
+    PRIMARY KEY (service_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    transfer_type INTEGER NOT NULL,
+    min_transfer_time INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (from_stop_id, to_stop_id)
+);
+
+CREATE TABLE IF NOT EXISTS shapes (
+    shape_id TEXT NOT NULL,
+    shape_pt_lat REAL NOT NULL,
+    shape_pt_lon REAL NOT NULL,
+    shape_pt_sequence INTEGER NOT NULL,
+    shape_dist_traveled REAL,
+    PRIMARY KEY (shape_id, shape_pt_sequence)
+);
+
+CREATE TABLE IF NOT EXISTS frequencies (
+    trip_id TEXT NOT NULL,
+    start_time INTEGER NOT NULL,
+    end_time INTEGER NOT NULL,
+    headway_secs INTEGER NOT NULL,
+    exact_times INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (trip_id, start_time)
+);
+
+CREATE TABLE IF NOT EXISTS fare_attributes (
+    fare_id TEXT PRIMARY KEY,
+    price REAL NOT NULL,
+    currency_type TEXT NOT NULL,
+    payment_method INTEGER NOT NULL,
+    transfers TEXT,
+    agency_id TEXT,
+    transfer_duration INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS fare_rules (
+    fare_id TEXT NOT NULL,
+    route_id TEXT,
+    origin_id TEXT,
+    destination_id TEXT,
+    contains_id TEXT
+);
+
+CREATE TABLE IF NOT EXISTS levels (
+    level_id TEXT PRIMARY KEY,
+    level_index REAL NOT NULL,
+    level_name TEXT
+);
+
+CREATE TABLE IF NOT EXISTS pathways (
+    pathway_id TEXT PRIMARY KEY,
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    pathway_mode INTEGER NOT NULL,
+    is_bidirectional INTEGER NOT NULL,
+    length REAL,
+    traversal_time INTEGER,
+    stair_count INTEGER,
+    max_slope REAL,
+    min_width REAL,
+    signposted_as TEXT,
+    reversed_signposted_as TEXT
+);
+
+CREATE TABLE IF NOT EXISTS feed_meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stop_times_stop_dep ON stop_times(stop_id, departure_time);
+CREATE INDEX IF NOT EXISTS idx_stops_parent ON stops(parent_station);
+CREATE INDEX IF NOT EXISTS idx_trips_service ON trips(service_id);
+CREATE INDEX IF NOT EXISTS idx_stop_times_trip ON stop_times(trip_id, stop_sequence);
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// UpdateRecord is one attempt to check, update, reload or roll back the
+// feed, as listed by the admin history.
+type UpdateRecord struct {
+	ID          int64
+	Kind        string
+	StartedAt   time.Time
+	Duration    time.Duration
+	FeedVersion string
+	Changed     bool
+	Error       string
+}
+
+func (s *Store) RecordUpdate(r UpdateRecord) error {
+	_, err := s.db.Exec("INSERT INTO update_log (kind, started_at, duration_ms, feed_version, changed, error) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Kind, r.StartedAt.UTC().Format(time.RFC3339), r.Duration.Milliseconds(), r.FeedVersion, boolToInt(r.Changed), r.Error)
+	if err != nil {
+		return fmt.Errorf("record update: %w", err)
+	}
+	return nil
+}
+
+// UpdateHistory returns the most recent update attempts, newest first.
+func (s *Store) UpdateHistory(limit int) ([]UpdateRecord, error) {
+	rows, err := s.db.Query("SELECT id, kind, started_at, duration_ms, feed_version, changed, error FROM update_log ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []UpdateRecord
+	for rows.Next() {
+		var (
+			r         UpdateRecord
+			startedAt string
+			ms        int64
+			changed   int
+		)
+		if err := rows.Scan(&r.ID, &r.Kind, &startedAt, &ms, &r.FeedVersion, &changed, &r.Error); err != nil {
+			return nil, err
+		}
+		r.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+		r.Duration = time.Duration(ms) * time.Millisecond
+		r.Changed = changed == 1
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,405 @@
+// Package tui draws a full-screen departure board in a terminal.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+const (
+	defaultRefresh = 5 * time.Second
+	defaultWindow  = 90
+)
+
+type Config struct {
+	// Stations are the boards to switch between.
+	Stations []search.Station
+	// Index returns the index to use for a service date.
+	Index func(date time.Time) *search.Index
+	// Refresh is the time between updates of the board.
+	Refresh time.Duration
+	// Window is how many minutes ahead departures are listed.
+	Window int
+}
+
+// Run shows the board on the terminal attached to stdin and stdout until
+// the user quits or ctx is cancelled.
+func Run(ctx context.Context, cfg Config) error {
+	if len(cfg.Stations) == 0 {
+		return fmt.Errorf("no stations to show")
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = defaultRefresh
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("tui needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+
+	// Alternate screen, hidden cursor; both undone on exit.
+	io.WriteString(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan key)
+	go readKeys(os.Stdin, keys)
+
+	m := &model{cfg: cfg}
+	ticker := time.NewTicker(cfg.Refresh)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		m.refresh(now)
+		width, height, err := term.GetSize(out)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		io.WriteString(os.Stdout, m.render(now, width, height))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok || m.handle(k) {
+				return nil
+			}
+		}
+	}
+}
+
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keyBack
+	keyQuit
+	// keyStation1 and above select a station by number.
+	keyStation1
+)
+
+// readKeys decodes key presses from r until it fails.
+func readKeys(r io.Reader, keys chan<- key) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		if k := decodeKey(buf[:n]); k != keyNone {
+			keys <- k
+		}
+	}
+}
+
+func decodeKey(b []byte) key {
+	switch s := string(b); s {
+	case "\x1b[A", "\x1bOA", "k":
+		return keyUp
+	case "\x1b[B", "\x1bOB", "j":
+		return keyDown
+	case "\x1b[D", "\x1bOD", "h", "\x1b[Z":
+		return keyLeft
+	case "\x1b[C", "\x1bOC", "l", "\t":
+		return keyRight
+	case "\r", "\n", " ":
+		return keyEnter
+	case "\x1b", "\x7f", "\b":
+		return keyBack
+	case "q", "Q", "\x03", "\x04":
+		return keyQuit
+	default:
+		if len(s) == 1 && s[0] >= '1' && s[0] <= '9' {
+			return keyStation1 + key(s[0]-'1')
+		}
+	}
+	return keyNone
+}
+
+// model is the state of the board, independent of the terminal.
+type model struct {
+	cfg     Config
+	station int
+
+	idx        *search.Index
+	departures []search.DepartureInfo
+	// selected is the trip under the cursor, kept across refreshes.
+	selected string
+	cursor   int
+	// trip is the departure whose details are open, if any.
+	trip *search.DepartureInfo
+}
+
+// refresh recomputes the departures of the current station.
+func (m *model) refresh(now time.Time) {
+	m.idx = m.cfg.Index(now)
+	st := m.cfg.Stations[m.station]
+	m.departures = m.idx.DepartureBoard(st.ID, secondsOfDay(now), m.cfg.Window, now)
+
+	m.cursor = 0
+	for i, d := range m.departures {
+		if d.TripID == m.selected {
+			m.cursor = i
+			break
+		}
+	}
+	if m.cursor < len(m.departures) {
+		m.selected = m.departures[m.cursor].TripID
+	}
+}
+
+// handle applies a key press and reports whether to quit.
+func (m *model) handle(k key) bool {
+	if m.trip != nil {
+		switch k {
+		case keyQuit:
+			return true
+		case keyBack, keyEnter, keyLeft:
+			m.trip = nil
+		}
+		return false
+	}
+
+	switch k {
+	case keyQuit:
+		return true
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyLeft:
+		m.switchStation((m.station + len(m.cfg.Stations) - 1) % len(m.cfg.Stations))
+	case keyRight:
+		m.switchStation((m.station + 1) % len(m.cfg.Stations))
+	case keyEnter:
+		if m.cursor < len(m.departures) {
+			d := m.departures[m.cursor]
+			m.trip = &d
+		}
+	default:
+		if n := int(k - keyStation1); k >= keyStation1 && n < len(m.cfg.Stations) {
+			m.switchStation(n)
+		}
+	}
+	return false
+}
+
+func (m *model) move(delta int) {
+	if len(m.departures) == 0 {
+		return
+	}
+	m.cursor = min(max(m.cursor+delta, 0), len(m.departures)-1)
+	m.selected = m.departures[m.cursor].TripID
+}
+
+func (m *model) switchStation(n int) {
+	if n != m.station {
+		m.station = n
+		m.selected = ""
+	}
+}
+
+func secondsOfDay(t time.Time) int {
+	return t.Hour()*3600 + t.Minute()*60 + t.Second()
+}
+
+// ANSI sequences used by render.
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	italic  = "\x1b[3m"
+	reverse = "\x1b[7m"
+)
+
+// screen collects the lines of a frame, each cut to the terminal width.
+type screen struct {
+	width int
+	lines []string
+}
+
+// add appends a line made of segments, alternating plain text and ANSI
+// styles: only the text counts towards the width.
+func (s *screen) add(segments ...string) {
+	var b strings.Builder
+	left := s.width
+	for i, seg := range segments {
+		if i%2 == 1 {
+			b.WriteString(seg)
+			continue
+		}
+		if n := utf8.RuneCountInString(seg); n > left {
+			seg = string([]rune(seg)[:left])
+		}
+		left -= utf8.RuneCountInString(seg)
+		b.WriteString(seg)
+	}
+	b.WriteString(reset)
+	s.lines = append(s.lines, b.String())
+}
+
+func (m *model) render(now time.Time, width, height int) string {
+	s := &screen{width: width}
+
+	// Station tabs and clock.
+	var tabs []string
+	for i, st := range m.cfg.Stations {
+		style := ""
+		if i == m.station {
+			style = reverse + bold
+		}
+		tabs = append(tabs, "", style, fmt.Sprintf(" %d %s ", i+1, st.Name), reset)
+	}
+	clock := now.Format("15:04:05")
+	tabWidth := 0
+	for i := 2; i < len(tabs); i += 4 {
+		tabWidth += utf8.RuneCountInString(tabs[i])
+	}
+	tabs = append(tabs, strings.Repeat(" ", max(width-tabWidth-len(clock), 1)), bold, clock)
+	s.add(tabs...)
+	s.add("")
+
+	if m.trip != nil {
+		m.renderTrip(s, now, height)
+	} else {
+		m.renderBoard(s, now, height)
+	}
+
+	for len(s.lines) < height-1 {
+		s.add("")
+	}
+	if m.trip != nil {
+		s.add("", dim, "Esc zpět · q konec")
+	} else {
+		s.add("", dim, "←/→ zastávka · ↑/↓ výběr · Enter detail spoje · q konec")
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range s.lines[:min(len(s.lines), height)] {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	return b.String()
+}
+
+func (m *model) renderBoard(s *screen, now time.Time, height int) {
+	if len(m.departures) == 0 {
+		s.add(fmt.Sprintf("Žádné odjezdy v příštích %d minutách.", m.cfg.Window))
+		return
+	}
+	headsignWidth := max(s.width-6-1-7-8, 10)
+	s.add("", dim, fmt.Sprintf("%-6s %-*s %6s %7s", "Linka", headsignWidth, "Směr", "Odjezd", "Za"))
+
+	rows := max(height-5, 1)
+	first := max(m.cursor-rows+1, 0)
+	current := secondsOfDay(now)
+	for i := first; i < len(m.departures) && i < first+rows; i++ {
+		d := m.departures[i]
+		style := ""
+		if i == m.cursor {
+			style = reverse
+		}
+		s.add("", m.lineStyle(d.TripID), fmt.Sprintf(" %-4s ", d.Line), reset+style,
+			" "+pad(d.Headsign, headsignWidth)+" "+fmt.Sprintf("%6s %7s", search.FormatTime(d.DepartureTime), countdown(d.DepartureTime-current)))
+	}
+}
+
+func (m *model) renderTrip(s *screen, now time.Time, height int) {
+	d := m.trip
+	stops := m.idx.TripStops[d.TripID]
+	s.add("", m.lineStyle(d.TripID), fmt.Sprintf(" %s ", d.Line), reset+bold, " "+d.Headsign)
+	s.add("")
+
+	// Trips from the previous service day are listed with times past 24:00.
+	offset := 0
+	for _, ts := range stops {
+		if ts.StopID == d.StopID {
+			offset = ts.DepartureTime - d.DepartureTime
+		}
+	}
+	current := secondsOfDay(now) + offset
+
+	rows := max(height-5, 1)
+	first := 0
+	for i, ts := range stops {
+		if ts.StopID == d.StopID {
+			first = max(min(i-2, len(stops)-rows), 0)
+		}
+	}
+	// A stop without a time counts as passed once the timed stop before it
+	// has been left.
+	passed := make([]bool, len(stops))
+	last := gtfs.NoTime
+	for i, ts := range stops {
+		if ts.DepartureTime != gtfs.NoTime {
+			last = ts.DepartureTime
+		}
+		passed[i] = last != gtfs.NoTime && last < current
+	}
+	for i := first; i < len(stops) && i < first+rows; i++ {
+		ts := stops[i]
+		style := ""
+		switch {
+		case ts.StopID == d.StopID:
+			style = reverse
+		case passed[i]:
+			style = dim
+		case ts.DepartureTime == gtfs.NoTime:
+			style = italic
+		}
+		s.add("", style, fmt.Sprintf(" %6s  %s", search.FormatTime(ts.DepartureTime), m.idx.StopName[ts.StopID]))
+	}
+}
+
+// lineStyle colours a line badge with the route's colours.
+func (m *model) lineStyle(tripID string) string {
+	r, _ := m.idx.Route(m.idx.TripRoute[tripID])
+	bg, fg := search.RouteColors(r)
+	return fmt.Sprintf("\x1b[1;38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", fg[0], fg[1], fg[2], bg[0], bg[1], bg[2])
+}
+
+func countdown(seconds int) string {
+	if seconds < 60 {
+		return "<1 min"
+	}
+	return strconv.Itoa(seconds/60) + " min"
+}
+
+// pad cuts or pads s to n runes.
+func pad(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s + strings.Repeat(" ", n-len(r))
+}
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want key
+	}{
+		{"\x1b[A", keyUp}, {"\x1bOA", keyUp}, {"k", keyUp},
+		{"\x1b[B", keyDown}, {"j", keyDown},
+		{"\x1b[D", keyLeft}, {"\x1b[Z", keyLeft}, {"h", keyLeft},
+		{"\x1b[C", keyRight}, {"\t", keyRight}, {"l", keyRight},
+		{"\r", keyEnter}, {"\n", keyEnter}, {" ", keyEnter},
+		{"\x1b", keyBack}, {"\x7f", keyBack}, {"\b", keyBack},
+		{"q", keyQuit}, {"Q", keyQuit}, {"\x03", keyQuit}, {"\x04", keyQuit},
+		{"1", keyStation1}, {"9", keyStation1 + 8},
+		{"0", keyNone}, {"x", keyNone}, {"12", keyNone}, {"\x1b[1;5A", keyNone}, {"", keyNone},
+	}
+	for _, tt := range tests {
+		if got := decodeKey([]byte(tt.in)); got != tt.want {
+			t.Errorf("decodeKey(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// testIndex has two stations: P, served by trips T1 to T3, and Q, served
+// by T4. T1 passes U, a stop without a time.
+func testIndex() *search.Index {
+	feed := &gtfs.Feed{
+		Routes:    []gtfs.Route{{ID: "R", ShortName: "1"}},
+		Calendars: []gtfs.Calendar{{ServiceID: "S", Monday: true, Tuesday: true, Wednesday: true, Thursday: true, Friday: true, Saturday: true, Sunday: true, StartDate: "20260101", EndDate: "20261231"}},
+		Stops: []gtfs.Stop{
+			{ID: "P", Name: "Park", LocationType: 1}, {ID: "P1", Name: "Park", ParentStation: "P"},
+			{ID: "Q", Name: "Quay", LocationType: 1}, {ID: "Q1", Name: "Quay", ParentStation: "Q"},
+			{ID: "X", Name: "Xenia"}, {ID: "U", Name: "Upper"}, {ID: "Y", Name: "Yard"},
+		},
+	}
+	hm := func(h, m int) int { return h*3600 + m*60 }
+	trip := func(id string, stops []string, times []int) {
+		feed.Trips = append(feed.Trips, gtfs.Trip{RouteID: "R", ServiceID: "S", TripID: id, Headsign: "Yard"})
+		for i, stop := range stops {
+			feed.StopTimes = append(feed.StopTimes, gtfs.StopTime{TripID: id, StopID: stop, StopSequence: i + 1, ArrivalTime: times[i], DepartureTime: times[i]})
+		}
+	}
+	trip("T1", []string{"X", "P1", "U", "Y"}, []int{hm(7, 50), hm(8, 0), gtfs.NoTime, hm(8, 10)})
+	trip("T2", []string{"P1", "Y"}, []int{hm(8, 10), hm(8, 20)})
+	trip("T3", []string{"P1", "Y"}, []int{hm(8, 20), hm(8, 30)})
+	trip("T4", []string{"Q1", "Y"}, []int{hm(8, 15), hm(8, 25)})
+	return search.BuildIndex(feed)
+}
+
+func newTestModel() *model {
+	idx := testIndex()
+	p, _ := idx.Station("P")
+	q, _ := idx.Station("Q")
+	return &model{cfg: Config{
+		Stations: []search.Station{p, q},
+		Index:    func(time.Time) *search.Index { return idx },
+		Window:   90,
+	}}
+}
+
+func TestModelHandle(t *testing.T) {
+	now := time.Date(2026, 3, 2, 7, 55, 0, 0, time.Local)
+	m := newTestModel()
+	m.refresh(now)
+
+	steps := []struct {
+		key      key
+		station  int
+		selected string
+		trip     string
+		quit     bool
+	}{
+		{keyDown, 0, "T2", "", false},
+		{keyDown, 0, "T3", "", false},
+		{keyDown, 0, "T3", "", false},
+		{keyUp, 0, "T2", "", false},
+		{keyEnter, 0, "T2", "T2", false},
+		// Only closing keys act on an open trip.
+		{keyDown, 0, "T2", "T2", false},
+		{keyRight, 0, "T2", "T2", false},
+		{keyLeft, 0, "T2", "", false},
+		{keyEnter, 0, "T2", "T2", false},
+		{keyBack, 0, "T2", "", false},
+		{keyRight, 1, "T4", "", false},
+		{keyRight, 0, "T1", "", false},
+		{keyLeft, 1, "T4", "", false},
+		{keyStation1, 0, "T1", "", false},
+		{keyStation1 + 5, 0, "T1", "", false},
+		{keyStation1 + 1, 1, "T4", "", false},
+		{keyQuit, 1, "T4", "", true},
+	}
+	for i, st := range steps {
+		quit := m.handle(st.key)
+		m.refresh(now)
+		trip := ""
+		if m.trip != nil {
+			trip = m.trip.TripID
+		}
+		if quit != st.quit || m.station != st.station || m.selected != st.selected || trip != st.trip {
+			t.Fatalf("step %d (key %d): quit %v, station %d, selected %q, trip %q; want %v, %d, %q, %q",
+				i, st.key, quit, m.station, m.selected, trip, st.quit, st.station, st.selected, st.trip)
+		}
+		if m.departures[m.cursor].TripID != m.selected {
+			t.Fatalf("step %d: cursor %d is on %s, not the selected %s", i, m.cursor, m.departures[m.cursor].TripID, m.selected)
+		}
+	}
+}
+
+func TestModelHandleWithoutDepartures(t *testing.T) {
+	m := newTestModel()
+	m.refresh(time.Date(2026, 3, 2, 22, 0, 0, 0, time.Local))
+	for _, k := range []key{keyDown, keyUp, keyEnter} {
+		if m.handle(k) || m.trip != nil || m.cursor != 0 {
+			t.Errorf("key %d on an empty board changed the state", k)
+		}
+	}
+}
+
+func TestRenderTripStyles(t *testing.T) {
+	tests := []struct {
+		name   string
+		at     time.Time
+		styles map[string]string
+	}{
+		{
+			name:   "before the departure",
+			at:     time.Date(2026, 3, 2, 7, 55, 0, 0, time.Local),
+			styles: map[string]string{"Xenia": dim, "Park": reverse, "Upper": italic, "Yard": ""},
+		},
+		{
+			name:   "after the departure",
+			at:     time.Date(2026, 3, 2, 8, 5, 0, 0, time.Local),
+			styles: map[string]string{"Xenia": dim, "Park": reverse, "Upper": dim, "Yard": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel()
+			m.refresh(time.Date(2026, 3, 2, 7, 55, 0, 0, time.Local))
+			m.handle(keyEnter)
+			if m.trip == nil || m.trip.TripID != "T1" {
+				t.Fatalf("opened trip %v, want T1", m.trip)
+			}
+
+			s := &screen{width: 80}
+			m.renderTrip(s, tt.at, 24)
+			for name, style := range tt.styles {
+				var line string
+				for _, l := range s.lines {
+					if strings.HasSuffix(strings.TrimSuffix(l, reset), name) {
+						line = l
+					}
+				}
+				if line == "" {
+					t.Errorf("no line for %s in %q", name, s.lines)
+					continue
+				}
+				if !strings.HasPrefix(line, style+" ") {
+					t.Errorf("%s is drawn as %q, want style %q", name, line, style)
+				}
+			}
+		})
+	}
+}
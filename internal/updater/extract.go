@@ -0,0 +1,147 @@
+package updater
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrDownloadTooLarge  = errors.New("download exceeds size limit")
+	ErrExtractedTooLarge = errors.New("extracted feed exceeds size limit")
+	ErrChecksumMismatch  = errors.New("checksum mismatch")
+	ErrUnsafePath        = errors.New("unsafe path in archive")
+	ErrSymlink           = errors.New("symlink in archive")
+	ErrDuplicateFile     = errors.New("duplicate file in archive")
+)
+
+// knownFiles are the only archive entries the updater extracts: the GTFS
+// static files plus the DPMLJ metadata.xml.
+var knownFiles = map[string]bool{
+	"agency.txt":          true,
+	"stops.txt":           true,
+	"routes.txt":          true,
+	"trips.txt":           true,
+	"stop_times.txt":      true,
+	"calendar.txt":        true,
+	"calendar_dates.txt":  true,
+	"fare_attributes.txt": true,
+	"fare_rules.txt":      true,
+	"shapes.txt":          true,
+	"frequencies.txt":     true,
+	"transfers.txt":       true,
+	"pathways.txt":        true,
+	"levels.txt":          true,
+	"feed_info.txt":       true,
+	"translations.txt":    true,
+	"attributions.txt":    true,
+	"metadata.xml":        true,
+}
+
+// readLimited reads the response body, failing with ErrDownloadTooLarge
+// rather than buffering more than max bytes.
+func readLimited(resp *http.Response, max int64) ([]byte, error) {
+	if resp.ContentLength > max {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrDownloadTooLarge, resp.ContentLength, max)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrDownloadTooLarge, max)
+	}
+	return body, nil
+}
+
+func verifyChecksum(body []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+	sum := sha256.Sum256(body)
+	got := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(got, strings.TrimSpace(want)) {
+		return fmt.Errorf("%w: got sha256 %s, want %s", ErrChecksumMismatch, got, want)
+	}
+	return nil
+}
+
+// extractArchive writes the known feed files in the zip body into dir.
+// Entries with unsafe paths or symlinks reject the whole archive. Unknown
+// files and directories are skipped on purpose rather than rejected:
+// publishers add readme and extension files over time, and refusing them
+// would stop updates without making extraction any safer.
+func extractArchive(body []byte, dir string, maxExtracted int64) error {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("open zip: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	remaining := maxExtracted
+	for _, f := range zr.File {
+		name := f.Name
+		if strings.Contains(name, `\`) || !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: %q", ErrUnsafePath, name)
+		}
+		mode := f.Mode()
+		if mode&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %q", ErrSymlink, name)
+		}
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() || !knownFiles[name] {
+			slog.Warn("Skipping unexpected archive entry", "name", name)
+			continue
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: %q", ErrDuplicateFile, name)
+		}
+		seen[name] = true
+
+		if f.UncompressedSize64 > uint64(remaining) {
+			return fmt.Errorf("%w: %s declares %d bytes (max %d total)", ErrExtractedTooLarge, name, f.UncompressedSize64, maxExtracted)
+		}
+		n, err := extractFile(f, filepath.Join(dir, name), remaining)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", name, err)
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// extractFile copies at most limit bytes of f to outPath. The declared size
+// in the zip header is not trusted, so the limit is enforced while copying.
+func extractFile(f *zip.File, outPath string, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err == nil && n > limit {
+		err = ErrExtractedTooLarge
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
@@ -0,0 +1,136 @@
+package updater
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type zipEntry struct {
+	name string
+	mode fs.FileMode
+	data string
+}
+
+func buildZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	stops := zipEntry{name: "stops.txt", data: "stop_id,stop_name\nA,Alpha\n"}
+	tests := []struct {
+		name      string
+		entries   []zipEntry
+		max       int64
+		wantErr   error
+		wantFiles []string
+	}{
+		{
+			name:      "feed files",
+			entries:   []zipEntry{stops, {name: "trips.txt", data: "trip_id\n"}},
+			wantFiles: []string{"stops.txt", "trips.txt"},
+		},
+		{
+			name:      "unknown files and directories are skipped",
+			entries:   []zipEntry{{name: "docs/", mode: fs.ModeDir | 0o755}, {name: "docs/readme.txt", data: "hi"}, {name: "extra.txt", data: "x"}, stops},
+			wantFiles: []string{"stops.txt"},
+		},
+		{
+			name:    "parent directory",
+			entries: []zipEntry{{name: "../stops.txt", data: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "nested parent directory",
+			entries: []zipEntry{{name: "gtfs/../../stops.txt", data: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "absolute path",
+			entries: []zipEntry{{name: "/etc/stops.txt", data: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "backslash",
+			entries: []zipEntry{{name: `..\stops.txt`, data: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "symlink",
+			entries: []zipEntry{{name: "stops.txt", mode: fs.ModeSymlink | 0o777, data: "/etc/passwd"}},
+			wantErr: ErrSymlink,
+		},
+		{
+			name:    "symlink with an unknown name",
+			entries: []zipEntry{{name: "link", mode: fs.ModeSymlink | 0o777, data: "/etc"}, stops},
+			wantErr: ErrSymlink,
+		},
+		{
+			name:    "duplicate file",
+			entries: []zipEntry{stops, stops},
+			wantErr: ErrDuplicateFile,
+		},
+		{
+			name:    "too large",
+			entries: []zipEntry{stops, {name: "trips.txt", data: "trip_id\nT1\nT2\n"}},
+			max:     int64(len(stops.data)) + 4,
+			wantErr: ErrExtractedTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			max := tt.max
+			if max == 0 {
+				max = 1 << 20
+			}
+			err := extractArchive(buildZip(t, tt.entries...), dir, max)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extractArchive error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("extracted %v, want %v", got, tt.wantFiles)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "stops.txt"))
+			if err != nil || string(data) != stops.data {
+				t.Errorf("stops.txt = %q, %v; want %q", data, err, stops.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package updater
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+	"timetable/internal/store"
+)
+
+const (
+	defaultKeepFeeds = 10
+	// indexCacheSize is the number of historical indexes kept in memory.
+	indexCacheSize = 2
+)
+
+// Feeds returns the stored feed versions, newest first.
+func (u *Updater) Feeds() ([]store.FeedRecord, error) {
+	return u.store.Feeds()
+}
+
+// LoadFeed reads a stored feed version back from the database.
+func (u *Updater) LoadFeed(id int64) (*gtfs.Feed, error) {
+	return u.store.LoadFeed(id)
+}
+
+// LiveFeedID returns the ID of the feed behind Index.
+func (u *Updater) LiveFeedID() int64 {
+	return u.liveFeed.Load()
+}
+
+// LookupFeed resolves ref, either a feed ID or a feed_version, to a stored
+// feed.
+func (u *Updater) LookupFeed(ref string) (store.FeedRecord, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		if f, err := u.store.FeedByID(id); err != store.ErrFeedNotFound {
+			return f, err
+		}
+	}
+	return u.store.FeedByVersion(ref)
+}
+
+// FeedAsOf returns the feed that was valid on the day of t.
+func (u *Updater) FeedAsOf(t time.Time) (store.FeedRecord, error) {
+	return u.store.FeedAsOf(t)
+}
+
+// IndexForFeed returns a search index for a stored feed version. Indexes of
+// feeds other than the live one are built from the database on demand and
+// the most recently used ones are cached.
+func (u *Updater) IndexForFeed(id int64) (*search.Index, error) {
+	if id == u.LiveFeedID() {
+		return u.Index(), nil
+	}
+	if up := u.upcoming.Load(); up != nil && id == up.id {
+		return up.index, nil
+	}
+
+	u.cacheMu.Lock()
+	defer u.cacheMu.Unlock()
+	for i, e := range u.indexCache {
+		if e.feedID == id {
+			copy(u.indexCache[1:i+1], u.indexCache[:i])
+			u.indexCache[0] = e
+			return e.index, nil
+		}
+	}
+
+	start := time.Now()
+	feed, err := u.store.LoadFeed(id)
+	if err != nil {
+		return nil, fmt.Errorf("load feed %d: %w", id, err)
+	}
+	idx := search.BuildIndex(feed)
+	slog.Info("Built index for historical feed", "feed_id", id, "duration", time.Since(start).Round(time.Millisecond).String())
+
+	u.indexCache = append([]cachedIndex{{id, idx}}, u.indexCache...)
+	if len(u.indexCache) > indexCacheSize {
+		u.indexCache = u.indexCache[:indexCacheSize]
+	}
+	return idx, nil
+}
+
+type cachedIndex struct {
+	feedID int64
+	index  *search.Index
+}
+
+// importFeed stores feed as a new version, marks it live and applies the
+// retention policy. hash is the content hash of its files, recorded so
+// reload can tell whether the files on disk are already imported. The
+// caller must hold mu.
+func (u *Updater) importFeed(feed *gtfs.Feed, hash string) error {
+	id, err := u.storeFeed(feed)
+	if err != nil {
+		return err
+	}
+	u.liveFeed.Store(id)
+	u.setMeta(metaLiveFeed, strconv.FormatInt(id, 10))
+	u.setMeta(metaLiveHash, hash)
+	u.prune()
+	return nil
+}
+
+// prune drops feed versions beyond the retention limit, keeping the live and
+// upcoming ones.
+func (u *Updater) prune() {
+	protect := []int64{u.LiveFeedID()}
+	if up := u.upcoming.Load(); up != nil {
+		protect = append(protect, up.id)
+	}
+	n, err := u.store.Prune(u.cfg.KeepFeeds, protect...)
+	if err != nil {
+		slog.Warn("Pruning feed history failed", "err", err)
+		return
+	}
+	if n == 0 {
+		return
+	}
+	slog.Info("Pruned old feed versions", "count", n)
+
+	u.cacheMu.Lock()
+	u.indexCache = nil
+	u.cacheMu.Unlock()
+}
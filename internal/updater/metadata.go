@@ -26,11 +26,15 @@ func ParseMetadata(path string) (*Metadata, error) {
 	return &meta, nil
 }
 
+func (m *Metadata) ValidFromTime() (time.Time, error) {
+	return parseCzechDate(m.ValidFrom)
+}
+
 func (m *Metadata) ValidToTime() (time.Time, error) {
 	return parseCzechDate(m.ValidTo)
 }
 
 func parseCzechDate(s string) (time.Time, error) {
 	s = strings.TrimSpace(s)
-	return time.Parse("02.01.2006", s)
+	return time.ParseInLocation("02.01.2006", s, time.Local)
 }
@@ -0,0 +1,219 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"timetable/internal/store"
+)
+
+var (
+	ErrUpdateInProgress = errors.New("an update is already in progress")
+	ErrStopped          = errors.New("updater is stopped")
+)
+
+// StartUpdate downloads the source in the background and reloads it if it
+// changed, or unconditionally with force. It fails with ErrUpdateInProgress
+// instead of queueing behind a running update; progress is visible in
+// History.
+func (u *Updater) StartUpdate(force bool) error {
+	if u.cfg.SourceURL == "" {
+		return errors.New("no GTFS source URL configured")
+	}
+	if !u.mu.TryLock() {
+		return ErrUpdateInProgress
+	}
+
+	started := u.goTracked(func() {
+		defer u.mu.Unlock()
+
+		start := time.Now()
+		changed, err := u.downloadAndReload(u.ctx, force)
+		u.record("update", start, changed, err)
+		if err != nil {
+			slog.Error("Update failed", "err", err)
+		}
+	})
+	if !started {
+		u.mu.Unlock()
+		return ErrStopped
+	}
+	return nil
+}
+
+// Update downloads the source and reloads it like StartUpdate, but waits
+// for the result. It reports whether a new feed was loaded.
+func (u *Updater) Update(ctx context.Context, force bool) (bool, error) {
+	if u.cfg.SourceURL == "" {
+		return false, errors.New("no GTFS source URL configured")
+	}
+	if !u.mu.TryLock() {
+		return false, ErrUpdateInProgress
+	}
+	defer u.mu.Unlock()
+
+	start := time.Now()
+	changed, err := u.downloadAndReload(ctx, force)
+	u.record("update", start, changed, err)
+	return changed, err
+}
+
+// Reload re-imports the feed files currently in the data directory into the
+// database and rebuilds the index. Files that are already the live feed in
+// the database, for example after the import command, are not imported
+// again, and nothing happens if the index is built from them too.
+func (u *Updater) Reload() error {
+	if !u.mu.TryLock() {
+		return ErrUpdateInProgress
+	}
+	defer u.mu.Unlock()
+
+	start := time.Now()
+	changed, err := u.reload()
+	u.record("reload", start, changed, err)
+	return err
+}
+
+func (u *Updater) reload() (bool, error) {
+	hash, err := hashFeedFiles(u.cfg.DataDir)
+	if err != nil {
+		return false, fmt.Errorf("hash: %w", err)
+	}
+	live, _ := u.store.GetMeta(metaLiveHash)
+	imported := hash == live
+	if imported && hash == u.contentHash {
+		slog.Info("Feed files unchanged, nothing to reload", "feed_version", u.feedVersion)
+		return false, nil
+	}
+
+	feed, err := parseFeed(u.cfg.DataDir)
+	if err != nil {
+		return false, fmt.Errorf("parse: %w", err)
+	}
+	if err := validateFeed(feed); err != nil {
+		return false, err
+	}
+	if imported {
+		slog.Info("Feed files already imported, rebuilding index")
+		if err := u.restoreLiveFeedID(); err != nil {
+			return false, err
+		}
+	} else if err := u.importFeed(feed, hash); err != nil {
+		return false, fmt.Errorf("reimport: %w", err)
+	}
+	if err := u.setLive(feed, u.cfg.DataDir); err != nil {
+		slog.Warn("Live feed state not recorded", "err", err)
+	}
+
+	idx := u.buildIndex(feed)
+	u.index.Store(idx)
+	u.notifyIndexChanged()
+	slog.Info("Reloaded index from disk", "stations", len(idx.Stations), "trips", len(idx.TripService))
+	return true, nil
+}
+
+// Rollback makes the feed kept from before the last update live again, both
+// on disk and in the database and index. The feed it replaces becomes the
+// new previous feed, so a second Rollback undoes the first. If the last
+// update brought an upcoming feed, Rollback discards it instead and forgets
+// the download, so the next check fetches the source again.
+func (u *Updater) Rollback() error {
+	if !u.mu.TryLock() {
+		return ErrUpdateInProgress
+	}
+	defer u.mu.Unlock()
+
+	start := time.Now()
+	err := u.rollback()
+	u.record("rollback", start, err == nil, err)
+	return err
+}
+
+func (u *Updater) rollback() error {
+	if u.upcoming.Load() != nil {
+		if err := u.discardUpcoming(); err != nil {
+			return err
+		}
+		// The source still publishes the discarded feed; without this the
+		// next check would take it as unchanged and never fetch it again.
+		return u.clearSource()
+	}
+
+	previous := filepath.Join(u.cfg.DataDir, previousDirName)
+	names, err := listFeedFiles(previous)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(names) == 0 {
+		return ErrNoPreviousFeed
+	}
+
+	feed, err := parseFeed(previous)
+	if err != nil {
+		return fmt.Errorf("parse previous feed: %w", err)
+	}
+	if err := validateFeed(feed); err != nil {
+		return err
+	}
+	hash, err := hashFeedFiles(previous)
+	if err != nil {
+		return fmt.Errorf("hash previous feed: %w", err)
+	}
+
+	staging := filepath.Join(u.cfg.DataDir, stagingDirName)
+	if err := demote(u.cfg.DataDir, staging, previous); err != nil {
+		return err
+	}
+	if err := u.importFeed(feed, hash); err != nil {
+		if perr := promote(u.cfg.DataDir, staging, previous); perr != nil {
+			slog.Error("Failed to restore current feed files", "err", perr)
+		}
+		return fmt.Errorf("reimport: %w", err)
+	}
+	// The feed we rolled back from now sits in staging; keep it as the
+	// previous feed.
+	if err := resetDir(previous); err != nil {
+		return err
+	}
+	if err := moveFeedFiles(staging, previous); err != nil {
+		return err
+	}
+
+	if err := u.setLive(feed, u.cfg.DataDir); err != nil {
+		slog.Warn("Live feed state not recorded", "err", err)
+	}
+
+	idx := u.buildIndex(feed)
+	u.index.Store(idx)
+	u.notifyIndexChanged()
+	slog.Info("Rolled back to previous feed", "stations", len(idx.Stations), "trips", len(idx.TripService))
+	return nil
+}
+
+// History returns the most recent update attempts, newest first.
+func (u *Updater) History(limit int) ([]store.UpdateRecord, error) {
+	return u.store.UpdateHistory(limit)
+}
+
+// record logs an update attempt to the store. The caller must hold mu.
+func (u *Updater) record(kind string, start time.Time, changed bool, err error) {
+	r := store.UpdateRecord{
+		Kind:        kind,
+		StartedAt:   start,
+		Duration:    time.Since(start),
+		FeedVersion: u.feedVersion,
+		Changed:     changed,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	u.countResult(kind, err)
+	if rerr := u.store.RecordUpdate(r); rerr != nil {
+		slog.Warn("Update not recorded", "err", rerr)
+	}
+}
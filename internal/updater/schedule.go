@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultRetryAttempts is the number of retries GTFS_RETRY_ATTEMPTS
+// defaults to.
+const DefaultRetryAttempts = 5
+
+const (
+	defaultCheckInterval  = 24 * time.Hour
+	defaultRetryBaseDelay = time.Minute
+	maxRetryDelay         = time.Hour
+)
+
+// StartBackgroundCheck checks the source once right away and then on the
+// configured schedule until Stop is called.
+func (u *Updater) StartBackgroundCheck() {
+	if u.cfg.SourceURL == "" {
+		slog.Info("No GTFS_SOURCE_URL configured, skipping periodic updates")
+		return
+	}
+
+	ctx := u.ctx
+	u.goTracked(func() {
+		u.checkWithRetry(ctx)
+		for ctx.Err() == nil {
+			next := u.nextCheck(time.Now())
+			slog.Info("Next GTFS update check scheduled", "at", next.Format("2006-01-02 15:04"))
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			u.checkWithRetry(ctx)
+		}
+	})
+}
+
+// Stop cancels the background check and any update started by StartUpdate,
+// aborting in-flight downloads, and waits for them to exit or ctx to expire.
+func (u *Updater) Stop(ctx context.Context) error {
+	u.stopMu.Lock()
+	u.cancel()
+	u.stopMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goTracked runs fn in a goroutine that Stop waits for. It reports false
+// and does not run fn once Stop has been called.
+func (u *Updater) goTracked(fn func()) bool {
+	u.stopMu.Lock()
+	defer u.stopMu.Unlock()
+	if u.ctx.Err() != nil {
+		return false
+	}
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// checkWithRetry runs a check, retrying failures with exponential backoff
+// up to the configured number of attempts.
+func (u *Updater) checkWithRetry(ctx context.Context) {
+	delay := u.cfg.RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := u.checkAndUpdate(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if attempt > u.cfg.RetryAttempts {
+			slog.Error("Update failed, giving up", "err", err, "attempts", attempt)
+			return
+		}
+		slog.Warn("Update failed, retrying", "err", err, "retry_in", delay.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// nextCheck returns the time of the next scheduled check after now. With a
+// CheckAt time of day, checks are aligned to it and repeat every
+// CheckInterval; otherwise they simply run CheckInterval apart.
+func (u *Updater) nextCheck(now time.Time) time.Time {
+	interval := u.cfg.CheckInterval
+	if u.cfg.CheckAt == "" {
+		return now.Add(interval)
+	}
+	at, err := time.Parse("15:04", u.cfg.CheckAt)
+	if err != nil {
+		return now.Add(interval)
+	}
+	anchor := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	// Division truncates towards zero; round down before the anchor unless
+	// now is exactly on a check, which must not be returned itself.
+	k := now.Sub(anchor) / interval
+	if now.Before(anchor) && now.Sub(anchor)%interval != 0 {
+		k--
+	}
+	return anchor.Add((k + 1) * interval)
+}
@@ -0,0 +1,143 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"timetable/internal/store"
+)
+
+// newTestUpdater returns an updater with cfg and a fresh database, stopped
+// and closed when the test ends.
+func newTestUpdater(t *testing.T, cfg Config) *Updater {
+	t.Helper()
+	if cfg.DataDir == "" {
+		cfg.DataDir = t.TempDir()
+	}
+	u := New(cfg)
+	s, err := store.Open(filepath.Join(t.TempDir(), "timetable.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.store = s
+	t.Cleanup(func() {
+		u.Stop(context.Background())
+		s.Close()
+	})
+	return u
+}
+
+func TestNextCheck(t *testing.T) {
+	day := func(h, m, s int) time.Time { return time.Date(2026, 3, 2, h, m, s, 0, time.Local) }
+	tests := []struct {
+		name     string
+		at       string
+		interval time.Duration
+		now      time.Time
+		want     time.Time
+	}{
+		{"no time of day", "", time.Hour, day(5, 17, 3), day(6, 17, 3)},
+		{"invalid time of day", "6am", time.Hour, day(5, 17, 3), day(6, 17, 3)},
+		{"before the anchor", "06:00", time.Hour, day(5, 30, 0), day(6, 0, 0)},
+		{"exact multiple before the anchor", "06:00", time.Hour, day(5, 0, 0), day(6, 0, 0)},
+		{"exact multiples before the anchor", "06:00", 2 * time.Hour, day(2, 0, 0), day(4, 0, 0)},
+		{"at the anchor", "06:00", time.Hour, day(6, 0, 0), day(7, 0, 0)},
+		{"after the anchor", "06:00", time.Hour, day(6, 0, 1), day(7, 0, 0)},
+		{"exact multiple after the anchor", "06:00", 6 * time.Hour, day(12, 0, 0), day(18, 0, 0)},
+		{"daily, before the anchor", "06:00", 24 * time.Hour, day(5, 59, 59), day(6, 0, 0)},
+		{"daily, after the anchor", "06:00", 24 * time.Hour, day(23, 0, 0), time.Date(2026, 3, 3, 6, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := New(Config{CheckAt: tt.at, CheckInterval: tt.interval})
+			got := u.nextCheck(tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextCheck(%s) = %s, want %s", tt.now.Format("15:04:05"), got, tt.want)
+			}
+			if !got.After(tt.now) {
+				t.Errorf("nextCheck(%s) = %s, not after now", tt.now.Format("15:04:05"), got)
+			}
+		})
+	}
+}
+
+func TestCheckWithRetry(t *testing.T) {
+	const base = 20 * time.Millisecond
+	tests := []struct {
+		name     string
+		attempts int
+		// failures is how many requests fail before the source answers
+		// 304 Not Modified.
+		failures  int
+		wantCalls int
+	}{
+		{"succeeds at once", 3, 0, 1},
+		{"succeeds on a retry", 3, 2, 3},
+		{"gives up", 3, 10, 4},
+		{"retries disabled", 0, 10, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []time.Time
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				calls = append(calls, time.Now())
+				n := len(calls)
+				mu.Unlock()
+				if n <= tt.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNotModified)
+			}))
+			defer srv.Close()
+
+			u := newTestUpdater(t, Config{SourceURL: srv.URL, RetryAttempts: tt.attempts, RetryBaseDelay: base})
+			u.checkWithRetry(context.Background())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("source fetched %d times, want %d", len(calls), tt.wantCalls)
+			}
+			// The delay doubles with each retry.
+			for i := 1; i < len(calls); i++ {
+				want := base << (i - 1)
+				if gap := calls[i].Sub(calls[i-1]); gap < want {
+					t.Errorf("retry %d after %s, want at least %s", i, gap, want)
+				}
+			}
+			if got := u.Stats().Failures["check"]; got != min(tt.failures, tt.wantCalls) {
+				t.Errorf("%d failed checks recorded, want %d", got, min(tt.failures, tt.wantCalls))
+			}
+		})
+	}
+}
+
+func TestCheckWithRetryStops(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	u := newTestUpdater(t, Config{SourceURL: srv.URL, RetryAttempts: 5, RetryBaseDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		u.checkWithRetry(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("checkWithRetry kept waiting after its context was cancelled")
+	}
+}
@@ -0,0 +1,131 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Keys in the store's feed_meta table describing the last download from
+// the GTFS source.
+const (
+	metaSourceETag         = "source_etag"
+	metaSourceLastModified = "source_last_modified"
+	metaSourceSHA256       = "source_sha256"
+)
+
+type download struct {
+	body         []byte
+	sha256       string
+	etag         string
+	lastModified string
+	notModified  bool
+}
+
+// fetch downloads the GTFS zip. Unless force is set, the request carries the
+// validators of the last download so an unchanged source answers with 304
+// Not Modified.
+func (u *Updater) fetch(ctx context.Context, force bool) (*download, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.cfg.SourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		if etag, _ := u.store.GetMeta(metaSourceETag); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm, _ := u.store.GetMeta(metaSourceLastModified); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &download{notModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download status: %d", resp.StatusCode)
+	}
+
+	body, err := readLimited(resp, u.cfg.MaxDownloadSize)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if err := verifyChecksum(body, u.cfg.SourceSHA256); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return &download{
+		body:         body,
+		sha256:       hex.EncodeToString(sum[:]),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// saveSource remembers the validators and hash of a download so the next
+// check can skip it if the source has not changed.
+func (u *Updater) saveSource(d *download) error {
+	meta := map[string]string{
+		metaSourceETag:         d.etag,
+		metaSourceLastModified: d.lastModified,
+		metaSourceSHA256:       d.sha256,
+	}
+	for k, v := range meta {
+		if err := u.store.SetMeta(k, v); err != nil {
+			return fmt.Errorf("save %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// clearSource forgets the last download, so the next check fetches the
+// source unconditionally and does not skip it as unchanged.
+func (u *Updater) clearSource() error {
+	return u.saveSource(&download{})
+}
+
+func (u *Updater) loadSource() *download {
+	d := &download{}
+	d.etag, _ = u.store.GetMeta(metaSourceETag)
+	d.lastModified, _ = u.store.GetMeta(metaSourceLastModified)
+	d.sha256, _ = u.store.GetMeta(metaSourceSHA256)
+	return d
+}
+
+// hashFeedFiles hashes the names and contents of the feed files in dir.
+// Unlike the zip hash it does not change when the publisher merely
+// repackages an identical feed.
+func hashFeedFiles(dir string) (string, error) {
+	names, err := listFeedFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
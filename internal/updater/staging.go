@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	stagingDirName  = ".staging"
+	previousDirName = ".previous"
+)
+
+var ErrNoPreviousFeed = errors.New("no previous feed to roll back to")
+
+// isFeedFile reports whether name is one of the files that make up a feed
+// in the data directory, as opposed to the database or staging areas.
+func isFeedFile(name string) bool {
+	return knownFiles[name]
+}
+
+func listFeedFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && isFeedFile(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// resetDir removes dir and recreates it empty.
+func resetDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// moveFeedFiles moves all feed files from src into dst. Both directories
+// must be on the same filesystem so each move is an atomic rename.
+func moveFeedFiles(src, dst string) error {
+	names, err := listFeedFiles(src)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := os.Rename(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return fmt.Errorf("move %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// promote makes the feed in staging the live feed in dataDir and keeps the
+// current live feed in previous. If a move fails, it tries to put the old
+// live files back.
+func promote(dataDir, staging, previous string) error {
+	if err := resetDir(previous); err != nil {
+		return fmt.Errorf("reset %s: %w", previous, err)
+	}
+	if err := moveFeedFiles(dataDir, previous); err != nil {
+		moveFeedFiles(previous, dataDir)
+		return fmt.Errorf("archive current feed: %w", err)
+	}
+	if err := moveFeedFiles(staging, dataDir); err != nil {
+		moveFeedFiles(dataDir, staging)
+		moveFeedFiles(previous, dataDir)
+		return fmt.Errorf("promote staged feed: %w", err)
+	}
+	return nil
+}
+
+// demote reverses promote: the live feed goes back to staging and the
+// previous feed becomes live again.
+func demote(dataDir, staging, previous string) error {
+	if err := resetDir(staging); err != nil {
+		return fmt.Errorf("reset %s: %w", staging, err)
+	}
+	if err := moveFeedFiles(dataDir, staging); err != nil {
+		moveFeedFiles(staging, dataDir)
+		return fmt.Errorf("stage current feed: %w", err)
+	}
+	if err := moveFeedFiles(previous, dataDir); err != nil {
+		moveFeedFiles(dataDir, previous)
+		moveFeedFiles(staging, dataDir)
+		return fmt.Errorf("restore previous feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"maps"
+	"time"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+// Stats describes the updater's work since the process started.
+type Stats struct {
+	// LastSuccess is when an update or scheduled check last completed
+	// without error, whether or not it loaded a new feed.
+	LastSuccess time.Time
+	// Failures counts failed operations by kind ("update", "check",
+	// "reload", "rollback", "switch").
+	Failures map[string]int
+	// IndexBuild and IndexSize describe the most recently built live or
+	// upcoming index; the size is an estimate of the memory it holds.
+	IndexBuild time.Duration
+	IndexSize  uint64
+	// Import is how long the last import into the database took.
+	Import time.Duration
+}
+
+// Stats returns a snapshot of the updater's statistics.
+func (u *Updater) Stats() Stats {
+	u.statsMu.Lock()
+	defer u.statsMu.Unlock()
+	s := u.stats
+	s.Failures = maps.Clone(u.stats.Failures)
+	return s
+}
+
+// buildIndex builds the index of feed, recording its build time and size.
+// The size is estimated from the index's contents rather than read from the
+// runtime, which would stop the world and count unrelated allocations.
+func (u *Updater) buildIndex(feed *gtfs.Feed) *search.Index {
+	start := time.Now()
+	idx := search.BuildIndex(feed)
+	elapsed := time.Since(start)
+	size := idx.Size()
+
+	u.statsMu.Lock()
+	u.stats.IndexBuild = elapsed
+	u.stats.IndexSize = size
+	u.statsMu.Unlock()
+	return idx
+}
+
+// storeFeed imports feed into the database, recording how long it took.
+func (u *Updater) storeFeed(feed *gtfs.Feed) (int64, error) {
+	start := time.Now()
+	id, err := u.store.Import(feed)
+	if err == nil {
+		u.statsMu.Lock()
+		u.stats.Import = time.Since(start)
+		u.statsMu.Unlock()
+	}
+	return id, err
+}
+
+// countResult updates the success and failure statistics for an operation.
+func (u *Updater) countResult(kind string, err error) {
+	u.statsMu.Lock()
+	defer u.statsMu.Unlock()
+	if err != nil {
+		if u.stats.Failures == nil {
+			u.stats.Failures = make(map[string]int)
+		}
+		u.stats.Failures[kind]++
+		return
+	}
+	if kind == "update" || kind == "check" {
+		u.stats.LastSuccess = time.Now()
+	}
+}
@@ -0,0 +1,232 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"timetable/internal/gtfs"
+	"timetable/internal/search"
+)
+
+const (
+	upcomingDirName = ".upcoming"
+
+	metaLiveFeed     = "live_feed_id"
+	metaLiveHash     = "live_feed_hash"
+	metaUpcomingFeed = "upcoming_feed_id"
+)
+
+// upcomingFeed is a feed published ahead of its start date. It is kept in
+// upcomingDirName next to the live feed until its validity begins.
+type upcomingFeed struct {
+	id       int64
+	index    *search.Index
+	version  string
+	validity Validity
+	hash     string
+}
+
+// UpcomingValidity returns the validity of the feed waiting to become live,
+// or a zero Validity if there is none.
+func (u *Updater) UpcomingValidity() Validity {
+	if up := u.upcoming.Load(); up != nil {
+		return up.validity
+	}
+	return Validity{}
+}
+
+// UpcomingFeedID returns the stored ID of the upcoming feed, or 0.
+func (u *Updater) UpcomingFeedID() int64 {
+	if up := u.upcoming.Load(); up != nil {
+		return up.id
+	}
+	return 0
+}
+
+// IndexForDate returns the index covering the service date: the upcoming
+// feed's from its start date on, the live one otherwise.
+func (u *Updater) IndexForDate(date time.Time) *search.Index {
+	if up := u.upcoming.Load(); up != nil && date.Format("20060102") >= up.validity.From.Format("20060102") {
+		return up.index
+	}
+	return u.Index()
+}
+
+// isUpcoming reports whether a feed with validity v starts after today.
+func isUpcoming(v Validity, now time.Time) bool {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return v.From.After(today)
+}
+
+// stageUpcoming keeps the validated feed in staging as the upcoming feed
+// instead of replacing the live one, and schedules the switch-over. The
+// feed is imported before the files of an earlier upcoming feed are
+// replaced, so a failed import leaves that feed and its switch intact. The
+// caller must hold mu.
+func (u *Updater) stageUpcoming(feed *gtfs.Feed, staging string, v Validity, hash string) error {
+	id, err := u.storeFeed(feed)
+	if err != nil {
+		return fmt.Errorf("import upcoming: %w", err)
+	}
+
+	dir := filepath.Join(u.cfg.DataDir, upcomingDirName)
+	err = resetDir(dir)
+	if err == nil {
+		err = moveFeedFiles(staging, dir)
+	}
+	if err != nil {
+		// The files of the earlier upcoming feed may be gone; do not
+		// switch to it.
+		u.dropUpcoming()
+		os.RemoveAll(dir)
+		return fmt.Errorf("keep upcoming feed: %w", err)
+	}
+	u.setMeta(metaUpcomingFeed, strconv.FormatInt(id, 10))
+
+	idx := u.buildIndex(feed)
+	u.upcoming.Store(&upcomingFeed{id: id, index: idx, version: feedVersion(feed), validity: v, hash: hash})
+	u.notifyIndexChanged()
+	u.prune()
+	u.scheduleSwitch(v.From)
+	slog.Info("Upcoming feed staged", "feed_version", feedVersion(feed), "valid_from", v.From.Format("2006-01-02"),
+		"stations", len(idx.Stations), "trips", len(idx.TripService))
+	return nil
+}
+
+// loadUpcoming restores the upcoming feed kept on disk at startup, or makes
+// it live right away if its start date has passed. The caller must hold mu.
+func (u *Updater) loadUpcoming() error {
+	dir := filepath.Join(u.cfg.DataDir, upcomingDirName)
+	names, err := listFeedFiles(dir)
+	if err != nil || len(names) == 0 {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	feed, err := parseFeed(dir)
+	if err != nil {
+		return fmt.Errorf("parse upcoming feed: %w", err)
+	}
+	hash, err := hashFeedFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	if s, _ := u.store.GetMeta(metaUpcomingFeed); s != "" {
+		id, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if _, err := u.store.FeedByID(id); err != nil {
+		if id, err = u.storeFeed(feed); err != nil {
+			return fmt.Errorf("import upcoming: %w", err)
+		}
+		u.setMeta(metaUpcomingFeed, strconv.FormatInt(id, 10))
+	}
+
+	v := computeValidity(feed, dir)
+	u.upcoming.Store(&upcomingFeed{id: id, index: u.buildIndex(feed), version: feedVersion(feed), validity: v, hash: hash})
+	if !isUpcoming(v, time.Now()) {
+		return u.switchToUpcoming()
+	}
+	u.scheduleSwitch(v.From)
+	slog.Info("Upcoming feed restored", "feed_version", feedVersion(feed), "valid_from", v.From.Format("2006-01-02"))
+	return nil
+}
+
+// scheduleSwitch makes the upcoming feed live at the given time, replacing
+// any switch scheduled before. The caller must hold mu.
+func (u *Updater) scheduleSwitch(at time.Time) {
+	if u.cancelSwitch != nil {
+		u.cancelSwitch()
+	}
+	ctx, cancel := context.WithCancel(u.ctx)
+	u.cancelSwitch = cancel
+
+	u.goTracked(func() {
+		timer := time.NewTimer(time.Until(at))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		start := time.Now()
+		err := u.switchToUpcoming()
+		u.record("switch", start, err == nil, err)
+		if err != nil {
+			slog.Error("Switch to upcoming feed failed", "err", err)
+		}
+	})
+}
+
+// switchToUpcoming makes the upcoming feed live. The live feed files are
+// kept as the previous feed. The caller must hold mu.
+func (u *Updater) switchToUpcoming() error {
+	up := u.upcoming.Load()
+	if up == nil {
+		return nil
+	}
+	dir := filepath.Join(u.cfg.DataDir, upcomingDirName)
+	previous := filepath.Join(u.cfg.DataDir, previousDirName)
+	if err := promote(u.cfg.DataDir, dir, previous); err != nil {
+		return err
+	}
+	os.Remove(dir)
+
+	u.feedVersion = up.version
+	u.contentHash = up.hash
+	u.validity.Store(up.validity)
+	u.liveFeed.Store(up.id)
+	u.index.Store(up.index)
+	u.upcoming.Store(nil)
+	u.notifyIndexChanged()
+	u.setMeta(metaLiveFeed, strconv.FormatInt(up.id, 10))
+	u.setMeta(metaLiveHash, up.hash)
+	u.setMeta(metaUpcomingFeed, "")
+
+	slog.Info("Switched to upcoming feed", "feed_version", up.version, "valid_from", up.validity.From.Format("2006-01-02"))
+	return nil
+}
+
+// discardUpcoming drops the upcoming feed without making it live. The
+// caller must hold mu.
+func (u *Updater) discardUpcoming() error {
+	if err := os.RemoveAll(filepath.Join(u.cfg.DataDir, upcomingDirName)); err != nil {
+		return err
+	}
+	u.dropUpcoming()
+	slog.Info("Discarded upcoming feed")
+	return nil
+}
+
+// dropUpcoming forgets the upcoming feed and cancels its switch, leaving
+// its files alone. The caller must hold mu.
+func (u *Updater) dropUpcoming() {
+	if u.cancelSwitch != nil {
+		u.cancelSwitch()
+		u.cancelSwitch = nil
+	}
+	if u.upcoming.Swap(nil) != nil {
+		u.notifyIndexChanged()
+	}
+	u.setMeta(metaUpcomingFeed, "")
+}
+
+func (u *Updater) setMeta(key, value string) {
+	if err := u.store.SetMeta(key, value); err != nil {
+		slog.Warn("Metadata not saved", "key", key, "err", err)
+	}
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"timetable/internal/gtfs"
+)
+
+func TestStageUpcomingFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail breaks the second staging of an upcoming feed.
+		fail func(u *Updater, staging string)
+		// wantKept is whether the first upcoming feed survives.
+		wantKept bool
+	}{
+		{
+			name:     "import fails",
+			fail:     func(u *Updater, _ string) { u.store.Close() },
+			wantKept: true,
+		},
+		{
+			name: "moving the files fails",
+			fail: func(_ *Updater, staging string) { os.RemoveAll(staging) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			u := newTestUpdater(t, Config{DataDir: dataDir})
+
+			staging := filepath.Join(dataDir, stagingDirName)
+			stage := func(version string, fail func(*Updater, string)) error {
+				if err := os.MkdirAll(staging, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(staging, "stops.txt"), []byte("stop_id\n"+version+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				hash, err := hashFeedFiles(staging)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fail != nil {
+					fail(u, staging)
+				}
+				feed := &gtfs.Feed{FeedInfo: &gtfs.FeedInfo{Version: version}}
+				v := Validity{From: time.Now().AddDate(0, 0, 7), To: time.Now().AddDate(0, 1, 0)}
+				u.mu.Lock()
+				defer u.mu.Unlock()
+				return u.stageUpcoming(feed, staging, v, hash)
+			}
+
+			if err := stage("first", nil); err != nil {
+				t.Fatal(err)
+			}
+			first := u.upcoming.Load()
+			if err := stage("second", tt.fail); err == nil {
+				t.Fatal("stageUpcoming succeeded, want an error")
+			}
+
+			u.mu.Lock()
+			defer u.mu.Unlock()
+			up := u.upcoming.Load()
+			if !tt.wantKept {
+				if up != nil || u.cancelSwitch != nil {
+					t.Errorf("upcoming feed %v still set or scheduled", up)
+				}
+				if meta, _ := u.store.GetMeta(metaUpcomingFeed); meta != "" {
+					t.Errorf("%s = %q, want it cleared", metaUpcomingFeed, meta)
+				}
+				if names, _ := listFeedFiles(filepath.Join(dataDir, upcomingDirName)); len(names) != 0 {
+					t.Errorf("upcoming files %v left behind", names)
+				}
+				return
+			}
+			if up != first || u.cancelSwitch == nil {
+				t.Fatalf("upcoming feed = %v, want the first one still scheduled", up)
+			}
+			// The scheduled switch must find the files of the feed it
+			// switches to.
+			hash, err := hashFeedFiles(filepath.Join(dataDir, upcomingDirName))
+			if err != nil || hash != first.hash {
+				t.Errorf("upcoming files hash %q, %v; want %q", hash, err, first.hash)
+			}
+		})
+	}
+}
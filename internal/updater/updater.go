@@ -1,40 +1,129 @@
 package updater
 
 import (
-	"archive/zip"
-	"bytes"
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"timetable/internal/gtfs"
 	"timetable/internal/search"
 	"timetable/internal/store"
+	"timetable/internal/validate"
 )
 
+// maxParseErrors is the number of strict-mode parse errors tolerated before
+// a feed is refused.
+const maxParseErrors = 100
+
+const (
+	defaultMaxDownloadSize  = 50 << 20
+	defaultMaxExtractedSize = 300 << 20
+)
+
+type Config struct {
+	DataDir   string
+	DBPath    string
+	SourceURL string
+
+	// MaxDownloadSize and MaxExtractedSize cap the downloaded zip and the
+	// total size of the files extracted from it, in bytes.
+	MaxDownloadSize  int64
+	MaxExtractedSize int64
+	// SourceSHA256 is an optional hex checksum the downloaded zip must match.
+	SourceSHA256 string
+
+	// CheckInterval is the time between source checks. If CheckAt is set
+	// ("15:04"), checks are aligned to that local time of day.
+	CheckInterval time.Duration
+	CheckAt       string
+	// RetryAttempts failed checks are retried, waiting RetryBaseDelay before
+	// the first retry and doubling the delay each time. Zero or a negative
+	// value disables retries; DefaultRetryAttempts is a sensible setting.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+
+	// KeepFeeds is the number of feed versions kept in the database.
+	KeepFeeds int
+}
+
 type Updater struct {
-	dataDir   string
-	dbPath    string
-	sourceURL string
-	index     atomic.Value
-	store     *store.Store
+	cfg      Config
+	client   *http.Client
+	index    atomic.Value
+	validity atomic.Value
+	store    *store.Store
+
+	// feedVersion and contentHash describe the live feed; guarded by mu.
+	feedVersion string
+	contentHash string
+	liveFeed    atomic.Int64
+	upcoming    atomic.Pointer[upcomingFeed]
+	// cancelSwitch cancels the scheduled switch to the upcoming feed;
+	// guarded by mu.
+	cancelSwitch context.CancelFunc
+
+	// indexCache holds indexes of historical feeds, most recently used first.
+	cacheMu    sync.Mutex
+	indexCache []cachedIndex
+
+	// mu serialises updates and rollbacks.
+	mu sync.Mutex
+
+	// changed is closed and replaced whenever the live or upcoming index
+	// changes; guarded by changedMu.
+	changedMu sync.Mutex
+	changed   chan struct{}
+
+	// ctx is cancelled by Stop; wg tracks the goroutines using it. stopMu
+	// orders wg.Add in goTracked with the cancel in Stop, so Stop never
+	// waits while a goroutine is still being added.
+	ctx    context.Context
+	cancel context.CancelFunc
+	stopMu sync.Mutex
+	wg     sync.WaitGroup
+
+	statsMu sync.Mutex
+	stats   Stats
 }
 
-func New(dataDir, dbPath, sourceURL string) *Updater {
+func New(cfg Config) *Updater {
+	if cfg.MaxDownloadSize <= 0 {
+		cfg.MaxDownloadSize = defaultMaxDownloadSize
+	}
+	if cfg.MaxExtractedSize <= 0 {
+		cfg.MaxExtractedSize = defaultMaxExtractedSize
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultCheckInterval
+	}
+	if cfg.RetryAttempts < 0 {
+		cfg.RetryAttempts = 0
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	if cfg.KeepFeeds <= 0 {
+		cfg.KeepFeeds = defaultKeepFeeds
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Updater{
-		dataDir:   dataDir,
-		dbPath:    dbPath,
-		sourceURL: sourceURL,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		changed: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
 func (u *Updater) LoadOrImport() (*search.Index, error) {
-	s, err := store.Open(u.dbPath)
+	s, err := store.Open(u.cfg.DBPath)
 	if err != nil {
 		return nil, fmt.Errorf("open store: %w", err)
 	}
@@ -45,139 +134,276 @@ func (u *Updater) LoadOrImport() (*search.Index, error) {
 		return nil, fmt.Errorf("check empty: %w", err)
 	}
 
+	var feed *gtfs.Feed
 	if empty {
-		log.Println("Database empty, importing GTFS data...")
-		if err := u.importFromDisk(); err != nil {
+		slog.Info("Database empty, importing GTFS data")
+		if feed, err = u.importFromDisk(); err != nil {
 			return nil, fmt.Errorf("import: %w", err)
 		}
+	} else {
+		if err := u.restoreLiveFeedID(); err != nil {
+			return nil, err
+		}
+		if feed, err = parseFeed(u.cfg.DataDir); err != nil {
+			return nil, fmt.Errorf("parse feed for index: %w", err)
+		}
 	}
 
-	log.Println("Building in-memory index...")
-	feed, err := gtfs.ParseFeed(u.dataDir)
-	if err != nil {
-		return nil, fmt.Errorf("parse feed for index: %w", err)
+	if err := u.setLive(feed, u.cfg.DataDir); err != nil {
+		return nil, err
 	}
 
-	idx := search.BuildIndex(feed)
+	slog.Info("Building in-memory index")
+	idx := u.buildIndex(feed)
 	u.index.Store(idx)
-	log.Printf("Index built: %d stations, %d trips", len(idx.Stations), len(idx.TripService))
-	return idx, nil
-}
+	slog.Info("Index built", "stations", len(idx.Stations), "trips", len(idx.TripService))
 
-func (u *Updater) Index() *search.Index {
-	return u.index.Load().(*search.Index)
+	u.mu.Lock()
+	err = u.loadUpcoming()
+	u.mu.Unlock()
+	if err != nil {
+		slog.Warn("Upcoming feed not loaded", "err", err)
+	}
+	return u.Index(), nil
 }
 
-func (u *Updater) StartBackgroundCheck() {
-	if u.sourceURL == "" {
-		log.Println("No GTFS_SOURCE_URL configured, skipping periodic updates")
-		return
+// LoadReadOnly builds the live and upcoming indexes from the feed files on
+// disk without opening the database. Nothing is migrated, imported, moved
+// or switched, so it is safe to use next to a running server. The updater
+// has no store afterwards and only answers Index and IndexForDate.
+func (u *Updater) LoadReadOnly() (*search.Index, error) {
+	feed, err := parseFeed(u.cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("parse feed for index: %w", err)
 	}
+	u.feedVersion = feedVersion(feed)
+	u.validity.Store(computeValidity(feed, u.cfg.DataDir))
+	u.index.Store(search.BuildIndex(feed))
 
-	go func() {
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			u.checkAndUpdate()
+	dir := filepath.Join(u.cfg.DataDir, upcomingDirName)
+	if names, _ := listFeedFiles(dir); len(names) > 0 {
+		up, err := parseFeed(dir)
+		if err != nil {
+			slog.Warn("Upcoming feed not loaded", "err", err)
+		} else {
+			u.upcoming.Store(&upcomingFeed{index: search.BuildIndex(up), version: feedVersion(up), validity: computeValidity(up, dir)})
 		}
-	}()
+	}
+	return u.Index(), nil
 }
 
-func (u *Updater) checkAndUpdate() {
-	metaPath := filepath.Join(u.dataDir, "metadata.xml")
-	meta, err := ParseMetadata(metaPath)
-	if err != nil {
-		log.Printf("Warning: cannot read metadata: %v", err)
-		return
+// restoreLiveFeedID finds the stored version of the live feed, falling back
+// to the newest one for databases written before it was recorded.
+func (u *Updater) restoreLiveFeedID() error {
+	if s, _ := u.store.GetMeta(metaLiveFeed); s != "" {
+		if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+			u.liveFeed.Store(id)
+			return nil
+		}
 	}
-
-	validTo, err := meta.ValidToTime()
+	latest, err := u.store.LatestFeed()
 	if err != nil {
-		log.Printf("Warning: cannot parse ValidTo: %v", err)
-		return
+		return fmt.Errorf("latest feed: %w", err)
 	}
+	u.liveFeed.Store(latest.ID)
+	return nil
+}
 
-	daysLeft := time.Until(validTo).Hours() / 24
-	if daysLeft > 3 {
-		log.Printf("Feed valid until %s (%.0f days left), no update needed", meta.ValidTo, daysLeft)
-		return
-	}
+// Store returns the database the updater imports into.
+func (u *Updater) Store() *store.Store {
+	return u.store
+}
 
-	log.Printf("Feed expires in %.0f days, downloading update...", daysLeft)
-	if err := u.downloadAndReload(); err != nil {
-		log.Printf("Update failed: %v", err)
+func (u *Updater) Index() *search.Index {
+	return u.index.Load().(*search.Index)
+}
+
+// IndexChanged returns a channel that is closed the next time the live or
+// upcoming index is replaced.
+func (u *Updater) IndexChanged() <-chan struct{} {
+	u.changedMu.Lock()
+	defer u.changedMu.Unlock()
+	return u.changed
+}
+
+func (u *Updater) notifyIndexChanged() {
+	u.changedMu.Lock()
+	close(u.changed)
+	u.changed = make(chan struct{})
+	u.changedMu.Unlock()
+}
+
+func (u *Updater) checkAndUpdate(ctx context.Context) error {
+	if v := u.FeedValidity(); !v.IsZero() {
+		slog.Info("Checking source for changes", "valid_until", v.To.Format("2006-01-02"),
+			"days_left", v.DaysLeft(time.Now()), "validity_source", v.Source)
+	} else {
+		slog.Info("Checking source for changes", "valid_until", "unknown")
 	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	start := time.Now()
+	changed, err := u.downloadAndReload(ctx, false)
+	u.record("check", start, changed, err)
+	return err
 }
 
-func (u *Updater) downloadAndReload() error {
-	resp, err := http.Get(u.sourceURL)
+// downloadAndReload fetches the source and reloads if the published feed
+// differs from the live one. With force set, the feed is downloaded and
+// reloaded even if it appears unchanged. It reports whether a new feed was
+// loaded. The caller must hold mu.
+func (u *Updater) downloadAndReload(ctx context.Context, force bool) (bool, error) {
+	d, err := u.fetch(ctx, force)
 	if err != nil {
-		return fmt.Errorf("download: %w", err)
+		return false, err
+	}
+	if d.notModified {
+		slog.Info("GTFS source not modified")
+		return false, nil
+	}
+	if !force && d.sha256 == u.loadSource().sha256 {
+		slog.Info("GTFS source unchanged (same checksum)")
+		return false, u.saveSource(d)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("download status: %d", resp.StatusCode)
+	staging := filepath.Join(u.cfg.DataDir, stagingDirName)
+	if err := resetDir(staging); err != nil {
+		return false, fmt.Errorf("prepare staging: %w", err)
+	}
+	if err := extractArchive(d.body, staging, u.cfg.MaxExtractedSize); err != nil {
+		return false, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	contentHash, err := hashFeedFiles(staging)
 	if err != nil {
-		return fmt.Errorf("read body: %w", err)
+		return false, fmt.Errorf("hash staged feed: %w", err)
+	}
+	if up := u.upcoming.Load(); !force && (contentHash == u.contentHash || up != nil && contentHash == up.hash) {
+		slog.Info("GTFS source repackaged but feed content unchanged")
+		return false, u.saveSource(d)
 	}
 
-	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	feed, err := parseFeed(staging)
 	if err != nil {
-		return fmt.Errorf("open zip: %w", err)
+		return false, fmt.Errorf("parse: %w", err)
+	}
+	if err := validateFeed(feed); err != nil {
+		return false, err
+	}
+	if v := feedVersion(feed); v == u.feedVersion && v != "" {
+		slog.Info("Feed content changed without a new feed_version, reloading", "feed_version", v)
+	} else {
+		slog.Info("New feed_version, reloading", "feed_version", v, "previous", u.feedVersion)
 	}
 
-	for _, f := range zr.File {
-		outPath := filepath.Join(u.dataDir, f.Name)
-		if err := extractFile(f, outPath); err != nil {
-			return fmt.Errorf("extract %s: %w", f.Name, err)
+	if v := computeValidity(feed, staging); isUpcoming(v, time.Now()) {
+		if err := u.stageUpcoming(feed, staging, v, contentHash); err != nil {
+			return false, err
 		}
+		if err := u.saveSource(d); err != nil {
+			slog.Warn("Source state not saved", "err", err)
+		}
+		return true, nil
 	}
 
-	if err := u.importFromDisk(); err != nil {
-		return fmt.Errorf("reimport: %w", err)
+	previous := filepath.Join(u.cfg.DataDir, previousDirName)
+	if err := promote(u.cfg.DataDir, staging, previous); err != nil {
+		return false, err
 	}
-
-	feed, err := gtfs.ParseFeed(u.dataDir)
-	if err != nil {
-		return fmt.Errorf("rebuild index: %w", err)
+	if err := u.importFeed(feed, contentHash); err != nil {
+		if derr := demote(u.cfg.DataDir, staging, previous); derr != nil {
+			slog.Error("Failed to restore previous feed files", "err", derr)
+		}
+		return false, fmt.Errorf("reimport: %w", err)
+	}
+	if err := u.saveSource(d); err != nil {
+		slog.Warn("Source state not saved", "err", err)
+	}
+	if err := u.setLive(feed, u.cfg.DataDir); err != nil {
+		slog.Warn("Live feed state not recorded", "err", err)
 	}
 
-	idx := search.BuildIndex(feed)
+	idx := u.buildIndex(feed)
 	u.index.Store(idx)
-	log.Printf("Updated index: %d stations, %d trips", len(idx.Stations), len(idx.TripService))
-	return nil
+	u.notifyIndexChanged()
+	slog.Info("Updated index", "stations", len(idx.Stations), "trips", len(idx.TripService))
+	return true, nil
 }
 
-func (u *Updater) importFromDisk() error {
-	feed, err := gtfs.ParseFeed(u.dataDir)
+// setLive records the version and content hash of the feed now live in dir.
+func (u *Updater) setLive(feed *gtfs.Feed, dir string) error {
+	u.feedVersion = feedVersion(feed)
+	u.validity.Store(computeValidity(feed, dir))
+
+	hash, err := hashFeedFiles(dir)
 	if err != nil {
-		return err
+		return fmt.Errorf("hash live feed: %w", err)
 	}
-	return u.store.Import(feed)
+	u.contentHash = hash
+	return nil
 }
 
-func extractFile(f *zip.File, outPath string) error {
-	rc, err := f.Open()
-	if err != nil {
-		return err
+func feedVersion(feed *gtfs.Feed) string {
+	if feed.FeedInfo == nil {
+		return ""
 	}
-	defer rc.Close()
+	return feed.FeedInfo.Version
+}
 
-	out, err := os.Create(outPath)
-	if err != nil {
+func parseFeed(dir string) (*gtfs.Feed, error) {
+	feed, diags, err := gtfs.ParseFeedWithOptions(dir, gtfs.ParseOptions{Strict: true, MaxErrors: maxParseErrors})
+	if len(diags) > 0 {
+		slog.Warn("Feed has parse problems", "dir", dir, "errors", diags.Errors(), "warnings", diags.Warnings())
+		for i, d := range diags {
+			if i == 10 {
+				slog.Warn("More parse problems not shown", "count", len(diags)-i)
+				break
+			}
+			slog.Warn("Parse problem", "dir", dir, "diagnostic", d.String())
+		}
+	}
+	return feed, err
+}
+
+// validateFeed runs the feed validator and refuses feeds with errors.
+func validateFeed(feed *gtfs.Feed) error {
+	report := validate.Feed(feed, validate.DefaultOptions())
+	if report.Warnings() > 0 {
+		slog.Warn("Feed validation warnings", "count", report.Warnings())
+	}
+	if err := report.Err(); err != nil {
+		var b strings.Builder
+		report.WriteText(&b)
+		slog.Error("Feed failed validation", "report", b.String())
 		return err
 	}
-	defer out.Close()
+	return nil
+}
 
-	_, err = io.Copy(out, rc)
-	return err
+// importFromDisk imports the feed in the data directory and returns it, so
+// that the index can be built without parsing it again.
+func (u *Updater) importFromDisk() (*gtfs.Feed, error) {
+	feed, err := parseFeed(u.cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashFeedFiles(u.cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.importFeed(feed, hash); err != nil {
+		return nil, err
+	}
+	return feed, nil
 }
 
+// Close closes the database. It waits for a running update, rollback or
+// switch to finish first, so that an import is never cut off mid-way.
 func (u *Updater) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	if u.store != nil {
 		return u.store.Close()
 	}
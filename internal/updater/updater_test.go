@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFeed writes a minimal feed with one trip over two stations to dir.
+func writeFeed(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]string{
+		"stops.txt":          "stop_id,stop_name,location_type,parent_station\nA,Alpha,1,\nA1,Alpha,0,A\nB,Beta,1,\nB1,Beta,0,B\n",
+		"routes.txt":         "route_id,route_short_name,route_type\nR,1,0\n",
+		"trips.txt":          "route_id,service_id,trip_id\nR,S,T\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nS,1,1,1,1,1,0,0,20260101,20261231\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+		"transfers.txt":      "from_stop_id,to_stop_id,transfer_type\n",
+		"stop_times.txt":     "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nT,08:00:00,08:00:00,A1,1\nT,08:10:00,08:10:00,B1,2\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestLoadOrImport starts on an empty database, which imports the feed, and
+// then again on the filled one.
+func TestLoadOrImport(t *testing.T) {
+	dataDir := t.TempDir()
+	writeFeed(t, dataDir)
+	cfg := Config{DataDir: dataDir, DBPath: filepath.Join(t.TempDir(), "timetable.db")}
+
+	var liveID int64
+	for _, start := range []string{"first start", "restart"} {
+		u := New(cfg)
+		idx, err := u.LoadOrImport()
+		if err != nil {
+			t.Fatalf("%s: %v", start, err)
+		}
+		if _, ok := idx.Station("A"); !ok || len(idx.TripService) != 1 {
+			t.Errorf("%s: index has %d stations and %d trips, want station A and 1 trip", start, len(idx.Stations), len(idx.TripService))
+		}
+		if u.LiveFeedID() == 0 || liveID != 0 && u.LiveFeedID() != liveID {
+			t.Errorf("%s: live feed %d, want the imported one", start, u.LiveFeedID())
+		}
+		liveID = u.LiveFeedID()
+		if u.contentHash == "" {
+			t.Errorf("%s: live feed hash not set", start)
+		}
+		if err := u.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
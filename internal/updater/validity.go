@@ -0,0 +1,76 @@
+package updater
+
+import (
+	"path/filepath"
+	"time"
+
+	"timetable/internal/gtfs"
+)
+
+// Validity is the date range a feed covers. To is the last service day,
+// inclusive. Source names where the range was taken from: "feed_info",
+// "calendar" or "metadata".
+type Validity struct {
+	From   time.Time
+	To     time.Time
+	Source string
+}
+
+func (v Validity) IsZero() bool {
+	return v.To.IsZero()
+}
+
+// DaysLeft is the number of whole days from now until the end of the last
+// service day.
+func (v Validity) DaysLeft(now time.Time) int {
+	if v.IsZero() {
+		return 0
+	}
+	end := v.To.AddDate(0, 0, 1)
+	return int(end.Sub(now).Hours() / 24)
+}
+
+// FeedValidity returns the validity of the live feed, or a zero Validity if
+// it cannot be determined.
+func (u *Updater) FeedValidity() Validity {
+	v, _ := u.validity.Load().(Validity)
+	return v
+}
+
+// computeValidity derives a feed's validity from feed_info.txt, falling back
+// to the calendar and finally to the DPMLJ metadata.xml in dir.
+func computeValidity(feed *gtfs.Feed, dir string) Validity {
+	if info := feed.FeedInfo; info != nil && info.EndDate != "" {
+		from, _ := parseGTFSDate(info.StartDate)
+		if to, err := parseGTFSDate(info.EndDate); err == nil {
+			if from.IsZero() {
+				from, _ = calendarRange(feed)
+			}
+			return Validity{From: from, To: to, Source: "feed_info"}
+		}
+	}
+
+	if from, to := calendarRange(feed); !to.IsZero() {
+		return Validity{From: from, To: to, Source: "calendar"}
+	}
+
+	if meta, err := ParseMetadata(filepath.Join(dir, "metadata.xml")); err == nil {
+		from, _ := meta.ValidFromTime()
+		if to, err := meta.ValidToTime(); err == nil {
+			return Validity{From: from, To: to, Source: "metadata"}
+		}
+	}
+	return Validity{}
+}
+
+// calendarRange parses the feed's service range into local dates.
+func calendarRange(feed *gtfs.Feed) (from, to time.Time) {
+	start, end := feed.ServiceRange()
+	from, _ = parseGTFSDate(start)
+	to, _ = parseGTFSDate(end)
+	return from, to
+}
+
+func parseGTFSDate(s string) (time.Time, error) {
+	return time.ParseInLocation("20060102", s, time.Local)
+}
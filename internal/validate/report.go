@@ -0,0 +1,121 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"timetable/internal/gtfs"
+)
+
+// maxTextExamples limits how many issues of one code WriteText lists.
+const maxTextExamples = 10
+
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+// AddDiagnostics merges strict-mode parse diagnostics into the report.
+func (r *Report) AddDiagnostics(diags gtfs.Diagnostics) {
+	for _, d := range diags {
+		msg := d.Message
+		if d.Column != "" {
+			msg = fmt.Sprintf("%s: %s", d.Column, msg)
+		}
+		if d.Value != "" {
+			msg = fmt.Sprintf("%s (%q)", msg, d.Value)
+		}
+		r.Issues = append(r.Issues, Issue{
+			Severity: d.Severity,
+			Code:     "parse",
+			File:     d.File,
+			Line:     d.Line,
+			Message:  msg,
+		})
+	}
+}
+
+func (r *Report) Errors() int {
+	n := 0
+	for _, is := range r.Issues {
+		if is.Severity == gtfs.SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) Warnings() int {
+	return len(r.Issues) - r.Errors()
+}
+
+// Err returns an error summarising the report if it contains any errors.
+func (r *Report) Err() error {
+	if n := r.Errors(); n > 0 {
+		return fmt.Errorf("feed validation failed: %d errors, %d warnings", n, r.Warnings())
+	}
+	return nil
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	out := struct {
+		Errors   int     `json:"errors"`
+		Warnings int     `json:"warnings"`
+		Issues   []Issue `json:"issues"`
+	}{r.Errors(), r.Warnings(), r.Issues}
+	if out.Issues == nil {
+		out.Issues = []Issue{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteText prints a human-readable report grouped by issue code, errors
+// first, with at most maxTextExamples issues listed per code.
+func (r *Report) WriteText(w io.Writer) error {
+	type group struct {
+		code     string
+		severity gtfs.Severity
+		issues   []Issue
+	}
+	groups := make(map[string]*group)
+	for _, is := range r.Issues {
+		key := is.Severity.String() + "|" + is.Code
+		g, ok := groups[key]
+		if !ok {
+			g = &group{code: is.Code, severity: is.Severity}
+			groups[key] = g
+		}
+		g.issues = append(g.issues, is)
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].severity != sorted[j].severity {
+			return sorted[i].severity > sorted[j].severity
+		}
+		return sorted[i].code < sorted[j].code
+	})
+
+	for _, g := range sorted {
+		fmt.Fprintf(w, "%s %s (%d)\n", g.severity, g.code, len(g.issues))
+		for i, is := range g.issues {
+			if i == maxTextExamples {
+				fmt.Fprintf(w, "  ... and %d more\n", len(g.issues)-i)
+				break
+			}
+			if is.Line > 0 {
+				fmt.Fprintf(w, "  %s:%d: %s\n", is.File, is.Line, is.Message)
+			} else {
+				fmt.Fprintf(w, "  %s: %s\n", is.File, is.Message)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d errors, %d warnings\n", r.Errors(), r.Warnings())
+	return err
+}
@@ -0,0 +1,292 @@
+package validate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"timetable/internal/gtfs"
+)
+
+type Issue struct {
+	Severity gtfs.Severity `json:"severity"`
+	Code     string        `json:"code"`
+	File     string        `json:"file"`
+	Line     int           `json:"line,omitempty"`
+	Message  string        `json:"message"`
+}
+
+type Options struct {
+	// MaxSpeedKmh is the highest plausible average speed between two
+	// consecutive stops of a trip.
+	MaxSpeedKmh float64
+}
+
+func DefaultOptions() Options {
+	return Options{MaxSpeedKmh: 100}
+}
+
+// Feed runs all referential integrity and plausibility checks on feed.
+func Feed(feed *gtfs.Feed, opts Options) *Report {
+	v := &validator{feed: feed, opts: opts, report: &Report{}}
+
+	v.checkDuplicates()
+	v.checkTrips()
+	v.checkStopTimes()
+	v.checkStops()
+	v.checkTransfers()
+	v.checkCalendarRange()
+
+	return v.report
+}
+
+type validator struct {
+	feed   *gtfs.Feed
+	opts   Options
+	report *Report
+
+	stops    map[string]*gtfs.Stop
+	trips    map[string]bool
+	services map[string]bool
+}
+
+// add records an issue about the i-th entry parsed from file.
+func (v *validator) add(sev gtfs.Severity, code, file string, i int, format string, args ...any) {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Severity: sev,
+		Code:     code,
+		File:     file,
+		Line:     v.feed.Line(file, i),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) errorf(code, file string, i int, format string, args ...any) {
+	v.add(gtfs.SeverityError, code, file, i, format, args...)
+}
+
+func (v *validator) warnf(code, file string, i int, format string, args ...any) {
+	v.add(gtfs.SeverityWarning, code, file, i, format, args...)
+}
+
+func (v *validator) checkDuplicates() {
+	f := v.feed
+
+	v.stops = make(map[string]*gtfs.Stop, len(f.Stops))
+	for i := range f.Stops {
+		s := &f.Stops[i]
+		if _, ok := v.stops[s.ID]; ok {
+			v.errorf("duplicate_key", "stops.txt", i, "duplicate stop_id %s", s.ID)
+		}
+		v.stops[s.ID] = s
+	}
+
+	routes := make(map[string]bool, len(f.Routes))
+	for i, r := range f.Routes {
+		if routes[r.ID] {
+			v.errorf("duplicate_key", "routes.txt", i, "duplicate route_id %s", r.ID)
+		}
+		routes[r.ID] = true
+	}
+
+	v.trips = make(map[string]bool, len(f.Trips))
+	for i, t := range f.Trips {
+		if v.trips[t.TripID] {
+			v.errorf("duplicate_key", "trips.txt", i, "duplicate trip_id %s", t.TripID)
+		}
+		v.trips[t.TripID] = true
+	}
+
+	v.services = make(map[string]bool)
+	for i, c := range f.Calendars {
+		if v.services[c.ServiceID] {
+			v.errorf("duplicate_key", "calendar.txt", i, "duplicate service_id %s", c.ServiceID)
+		}
+		v.services[c.ServiceID] = true
+	}
+
+	dates := make(map[string]bool, len(f.CalendarDates))
+	for i, cd := range f.CalendarDates {
+		key := cd.ServiceID + "|" + cd.Date
+		if dates[key] {
+			v.errorf("duplicate_key", "calendar_dates.txt", i, "duplicate service_id %s on %s", cd.ServiceID, cd.Date)
+		}
+		dates[key] = true
+		v.services[cd.ServiceID] = true
+	}
+
+	stopTimes := make(map[string]bool, len(f.StopTimes))
+	for i, st := range f.StopTimes {
+		key := fmt.Sprintf("%s|%d", st.TripID, st.StopSequence)
+		if stopTimes[key] {
+			v.errorf("duplicate_key", "stop_times.txt", i, "duplicate stop_sequence %d in trip %s", st.StopSequence, st.TripID)
+		}
+		stopTimes[key] = true
+	}
+
+	transfers := make(map[string]bool, len(f.Transfers))
+	for i, t := range f.Transfers {
+		key := t.FromStopID + "|" + t.ToStopID
+		if transfers[key] {
+			v.errorf("duplicate_key", "transfers.txt", i, "duplicate transfer %s -> %s", t.FromStopID, t.ToStopID)
+		}
+		transfers[key] = true
+	}
+}
+
+func (v *validator) checkTrips() {
+	routes := make(map[string]bool, len(v.feed.Routes))
+	for _, r := range v.feed.Routes {
+		routes[r.ID] = true
+	}
+	for i, t := range v.feed.Trips {
+		if !routes[t.RouteID] {
+			v.errorf("unknown_route", "trips.txt", i, "trip %s references unknown route_id %s", t.TripID, t.RouteID)
+		}
+		if !v.services[t.ServiceID] {
+			v.errorf("unknown_service", "trips.txt", i, "trip %s references unknown service_id %s", t.TripID, t.ServiceID)
+		}
+	}
+}
+
+func (v *validator) checkStopTimes() {
+	// byTrip holds indexes into StopTimes so issues can report their line.
+	byTrip := make(map[string][]int)
+	for i, st := range v.feed.StopTimes {
+		if !v.trips[st.TripID] {
+			v.errorf("unknown_trip", "stop_times.txt", i, "stop_time references unknown trip_id %s", st.TripID)
+		}
+		if _, ok := v.stops[st.StopID]; !ok {
+			v.errorf("unknown_stop", "stop_times.txt", i, "trip %s references unknown stop_id %s", st.TripID, st.StopID)
+		}
+		byTrip[st.TripID] = append(byTrip[st.TripID], i)
+	}
+
+	for i, t := range v.feed.Trips {
+		if len(byTrip[t.TripID]) < 2 {
+			v.warnf("trip_without_stops", "trips.txt", i, "trip %s has %d stop times", t.TripID, len(byTrip[t.TripID]))
+		}
+	}
+
+	tripIDs := make([]string, 0, len(byTrip))
+	for id := range byTrip {
+		tripIDs = append(tripIDs, id)
+	}
+	sort.Strings(tripIDs)
+
+	all := v.feed.StopTimes
+	for _, tripID := range tripIDs {
+		times := byTrip[tripID]
+		sort.Slice(times, func(i, j int) bool {
+			return all[times[i]].StopSequence < all[times[j]].StopSequence
+		})
+		// Stops between timepoints may have no times; they are compared
+		// with the last stop that has one.
+		prev := -1
+		for _, i := range times {
+			st := all[i]
+			if st.ArrivalTime == gtfs.NoTime {
+				continue
+			}
+			if st.DepartureTime < st.ArrivalTime {
+				v.errorf("non_monotonic_times", "stop_times.txt", i, "trip %s departs stop %s (sequence %d) before arriving", tripID, st.StopID, st.StopSequence)
+			}
+			if prev < 0 {
+				prev = i
+				continue
+			}
+			if st.ArrivalTime < all[prev].DepartureTime {
+				v.errorf("non_monotonic_times", "stop_times.txt", i, "trip %s arrives at stop %s (sequence %d) before leaving the previous stop", tripID, st.StopID, st.StopSequence)
+				prev = i
+				continue
+			}
+			v.checkSpeed(tripID, i, all[prev], st)
+			prev = i
+		}
+	}
+}
+
+func (v *validator) checkSpeed(tripID string, i int, prev, cur gtfs.StopTime) {
+	from, to := v.stops[prev.StopID], v.stops[cur.StopID]
+	if from == nil || to == nil || v.opts.MaxSpeedKmh <= 0 {
+		return
+	}
+	if (from.Lat == 0 && from.Lon == 0) || (to.Lat == 0 && to.Lon == 0) {
+		return
+	}
+
+	km := haversineKm(from.Lat, from.Lon, to.Lat, to.Lon)
+	seconds := cur.ArrivalTime - prev.DepartureTime
+	// GTFS times have minute resolution in most feeds, so a zero-second hop
+	// is only suspicious if the stops are far apart.
+	if seconds <= 0 {
+		seconds = 60
+	}
+	speed := km / (float64(seconds) / 3600)
+	if speed > v.opts.MaxSpeedKmh {
+		v.warnf("unrealistic_speed", "stop_times.txt", i, "trip %s travels %.1f km from %s to %s in %d s (%.0f km/h)",
+			tripID, km, from.ID, to.ID, cur.ArrivalTime-prev.DepartureTime, speed)
+	}
+}
+
+func (v *validator) checkStops() {
+	served := make(map[string]bool)
+	for _, st := range v.feed.StopTimes {
+		served[st.StopID] = true
+	}
+
+	for i, s := range v.feed.Stops {
+		if s.ParentStation != "" {
+			parent, ok := v.stops[s.ParentStation]
+			switch {
+			case !ok:
+				v.errorf("unknown_parent_station", "stops.txt", i, "stop %s references unknown parent_station %s", s.ID, s.ParentStation)
+			case parent.LocationType != 1 && s.LocationType == 0:
+				v.errorf("invalid_parent_station", "stops.txt", i, "stop %s has parent_station %s which is not a station", s.ID, s.ParentStation)
+			}
+		}
+		if s.LocationType == 0 && !served[s.ID] {
+			v.warnf("stop_without_service", "stops.txt", i, "stop %s (%s) has no stop times", s.ID, s.Name)
+		}
+	}
+}
+
+func (v *validator) checkTransfers() {
+	for i, t := range v.feed.Transfers {
+		if _, ok := v.stops[t.FromStopID]; !ok {
+			v.errorf("unknown_stop", "transfers.txt", i, "transfer references unknown from_stop_id %s", t.FromStopID)
+		}
+		if _, ok := v.stops[t.ToStopID]; !ok {
+			v.errorf("unknown_stop", "transfers.txt", i, "transfer references unknown to_stop_id %s", t.ToStopID)
+		}
+	}
+}
+
+func (v *validator) checkCalendarRange() {
+	info := v.feed.FeedInfo
+	if info == nil || info.StartDate == "" || info.EndDate == "" {
+		return
+	}
+	for i, c := range v.feed.Calendars {
+		if c.StartDate < info.StartDate || c.EndDate > info.EndDate {
+			v.warnf("calendar_outside_feed", "calendar.txt", i, "service %s runs %s-%s, outside feed validity %s-%s",
+				c.ServiceID, c.StartDate, c.EndDate, info.StartDate, info.EndDate)
+		}
+	}
+	for i, cd := range v.feed.CalendarDates {
+		if cd.Date < info.StartDate || cd.Date > info.EndDate {
+			v.warnf("calendar_outside_feed", "calendar_dates.txt", i, "service %s exception on %s is outside feed validity %s-%s",
+				cd.ServiceID, cd.Date, info.StartDate, info.EndDate)
+		}
+	}
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,137 @@
+package validate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"timetable/internal/gtfs"
+)
+
+const stopTimesHeader = "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n"
+
+// testFeed returns the files of a valid feed with one trip over three stops
+// about a kilometre apart. Tests replace individual files.
+func testFeed() map[string]string {
+	return map[string]string{
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon,location_type,parent_station\nP,Station,50.08,14.42,1,\nA,Alpha,50.08,14.42,0,P\nB,Beta,50.09,14.42,0,\nC,Gamma,50.10,14.42,0,\n",
+		"routes.txt":         "route_id,route_short_name,route_type\nR,1,0\n",
+		"trips.txt":          "route_id,service_id,trip_id\nR,S,T\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nS,1,1,1,1,1,0,0,20260101,20261231\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+		"transfers.txt":      "from_stop_id,to_stop_id,transfer_type\n",
+		"stop_times.txt":     stopTimesHeader + "T,08:00:00,08:00:00,A,1\nT,08:02:00,08:02:00,B,2\nT,08:04:00,08:04:00,C,3\n",
+	}
+}
+
+func TestFeed(t *testing.T) {
+	type issue struct {
+		severity gtfs.Severity
+		code     string
+		file     string
+		line     int
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []issue
+	}{
+		{
+			name: "valid feed",
+		},
+		{
+			name: "stops without times between timepoints",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,,,B,2\nT,08:04:00,08:04:00,C,3\n"},
+		},
+		{
+			name: "departs before arriving",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,08:03:00,08:02:00,B,2\nT,08:04:00,08:04:00,C,3\n"},
+			want: []issue{{gtfs.SeverityError, "non_monotonic_times", "stop_times.txt", 3}},
+		},
+		{
+			name: "arrives before leaving the previous stop",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,08:05:00,08:05:00,B,2\nT,08:04:00,08:04:00,C,3\n"},
+			want: []issue{{gtfs.SeverityError, "non_monotonic_times", "stop_times.txt", 4}},
+		},
+		{
+			name: "arrives before the last stop with a time",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:05:00,08:05:00,A,1\nT,,,B,2\nT,08:04:00,08:04:00,C,3\n"},
+			want: []issue{{gtfs.SeverityError, "non_monotonic_times", "stop_times.txt", 4}},
+		},
+		{
+			name: "unrealistic speed",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,08:02:00,08:02:00,B,2\nT,08:03:00,08:03:00,C,3\n",
+				"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\nA,Alpha,50.08,14.42\nB,Beta,50.09,14.42\nC,Gamma,50.20,14.42\n"},
+			want: []issue{{gtfs.SeverityWarning, "unrealistic_speed", "stop_times.txt", 4}},
+		},
+		{
+			name: "unknown stop",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,08:02:00,08:02:00,X,2\nT,08:04:00,08:04:00,C,3\n"},
+			want: []issue{
+				{gtfs.SeverityError, "unknown_stop", "stop_times.txt", 3},
+				{gtfs.SeverityWarning, "stop_without_service", "stops.txt", 4},
+			},
+		},
+		{
+			name:  "unknown route and service",
+			files: map[string]string{"trips.txt": "route_id,service_id,trip_id\nR,S,T\nQ,X,U\n"},
+			want: []issue{
+				{gtfs.SeverityError, "unknown_route", "trips.txt", 3},
+				{gtfs.SeverityError, "unknown_service", "trips.txt", 3},
+				{gtfs.SeverityWarning, "trip_without_stops", "trips.txt", 3},
+			},
+		},
+		{
+			name: "duplicate stop sequence",
+			files: map[string]string{"stop_times.txt": stopTimesHeader +
+				"T,08:00:00,08:00:00,A,1\nT,08:02:00,08:02:00,B,2\nT,08:04:00,08:04:00,C,2\n"},
+			want: []issue{{gtfs.SeverityError, "duplicate_key", "stop_times.txt", 4}},
+		},
+		{
+			name: "unknown parent station",
+			files: map[string]string{"stops.txt": "stop_id,stop_name,stop_lat,stop_lon,parent_station\n" +
+				"A,Alpha,50.08,14.42,Z\nB,Beta,50.09,14.42,\nC,Gamma,50.10,14.42,\n"},
+			want: []issue{{gtfs.SeverityError, "unknown_parent_station", "stops.txt", 2}},
+		},
+		{
+			name: "calendar outside feed validity",
+			files: map[string]string{"feed_info.txt": "feed_publisher_name,feed_publisher_url,feed_lang,feed_start_date,feed_end_date\n" +
+				"P,https://example.com,cs,20260101,20260630\n"},
+			want: []issue{{gtfs.SeverityWarning, "calendar_outside_feed", "calendar.txt", 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range testFeed() {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			for name, data := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			feed, _, err := gtfs.ParseFS(fsys, gtfs.ParseOptions{})
+			if err != nil {
+				t.Fatalf("ParseFS: %v", err)
+			}
+
+			report := Feed(feed, DefaultOptions())
+			var got []issue
+			for _, is := range report.Issues {
+				got = append(got, issue{is.Severity, is.Code, is.File, is.Line})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got issues %v, want %v", report.Issues, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("issue %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"timetable/internal/updater"
+)
+
+// requireAdmin guards next with a bearer token, also accepted as the basic
+// auth password so browsers can open the admin pages. With no token
+// configured the admin endpoints are disabled altogether.
+func requireAdmin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			httpError(w, r, "404 page not found", http.StatusNotFound)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, got, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="timetable admin"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="timetable admin"`)
+			writeJSONError(w, r, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
+	force := r.URL.Query().Get("force") == "1"
+	if err := h.updater.StartUpdate(force); err != nil {
+		writeAdminError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+func (h *Handler) HandleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.updater.Reload(); err != nil {
+		writeAdminError(w, r, err)
+		return
+	}
+	idx := h.updater.Index()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "reloaded",
+		"stations": len(idx.Stations),
+		"trips":    len(idx.TripService),
+	})
+}
+
+func (h *Handler) HandleAdminRollback(w http.ResponseWriter, r *http.Request) {
+	if err := h.updater.Rollback(); err != nil {
+		writeAdminError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rolled back"})
+}
+
+func (h *Handler) HandleAdminUpdates(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	records, err := h.updater.History(limit)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type updateResult struct {
+		ID          int64  `json:"id"`
+		Kind        string `json:"kind"`
+		StartedAt   string `json:"started_at"`
+		DurationMS  int64  `json:"duration_ms"`
+		FeedVersion string `json:"feed_version,omitempty"`
+		Changed     bool   `json:"changed"`
+		Error       string `json:"error,omitempty"`
+	}
+
+	results := make([]updateResult, len(records))
+	for i, rec := range records {
+		results[i] = updateResult{
+			ID:          rec.ID,
+			Kind:        rec.Kind,
+			StartedAt:   rec.StartedAt.Local().Format(time.RFC3339),
+			DurationMS:  rec.Duration.Milliseconds(),
+			FeedVersion: rec.FeedVersion,
+			Changed:     rec.Changed,
+			Error:       rec.Error,
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func writeAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, updater.ErrUpdateInProgress):
+		status = http.StatusConflict
+	case errors.Is(err, updater.ErrNoPreviousFeed):
+		status = http.StatusNotFound
+	case errors.Is(err, updater.ErrStopped):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONError(w, r, status, err.Error())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,385 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"timetable/internal/search"
+	"timetable/internal/store"
+)
+
+// apiError is the error body returned by every /api/v1 endpoint.
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+type apiErrorResponse struct {
+	Error     apiError `json:"error"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+func missingParam(name string) error {
+	return &apiError{http.StatusBadRequest, "missing_parameter", fmt.Sprintf("parameter %q is required", name)}
+}
+
+func invalidParam(name, want string) error {
+	return &apiError{http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("parameter %q must be %s", name, want)}
+}
+
+func notFound(format string, args ...any) error {
+	return &apiError{http.StatusNotFound, "not_found", fmt.Sprintf(format, args...)}
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	var ae *apiError
+	switch {
+	case errors.As(err, &ae):
+	case errors.Is(err, errInvalidAsOf):
+		ae = &apiError{http.StatusBadRequest, "invalid_parameter", err.Error()}
+	case errors.Is(err, store.ErrFeedNotFound):
+		ae = &apiError{http.StatusNotFound, "feed_not_found", "no matching feed version"}
+	default:
+		ae = &apiError{http.StatusInternalServerError, "internal", err.Error()}
+	}
+	writeJSON(w, ae.Status, apiErrorResponse{Error: *ae, RequestID: requestID(r.Context())})
+}
+
+type apiStopRef struct {
+	StopID string `json:"stop_id"`
+	Name   string `json:"name"`
+}
+
+type apiStation struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Platforms []string `json:"platforms"`
+}
+
+type apiStations struct {
+	Stations []apiStation `json:"stations"`
+}
+
+type apiConnection struct {
+	TripID          string     `json:"trip_id"`
+	Line            string     `json:"line"`
+	RouteType       int        `json:"route_type"`
+	Headsign        string     `json:"headsign"`
+	From            apiStopRef `json:"from"`
+	To              apiStopRef `json:"to"`
+	Departure       time.Time  `json:"departure"`
+	Arrival         time.Time  `json:"arrival"`
+	DurationMinutes int        `json:"duration_minutes"`
+}
+
+type apiConnections struct {
+	From        apiStation      `json:"from"`
+	To          apiStation      `json:"to"`
+	Date        string          `json:"date"`
+	Connections []apiConnection `json:"connections"`
+}
+
+type apiDeparture struct {
+	TripID    string     `json:"trip_id"`
+	Line      string     `json:"line"`
+	RouteType int        `json:"route_type"`
+	Headsign  string     `json:"headsign"`
+	Stop      apiStopRef `json:"stop"`
+	Departure time.Time  `json:"departure"`
+}
+
+type apiDepartures struct {
+	Station    apiStation     `json:"station"`
+	Date       string         `json:"date"`
+	Departures []apiDeparture `json:"departures"`
+}
+
+type apiLine struct {
+	ID        string `json:"id"`
+	ShortName string `json:"short_name"`
+	LongName  string `json:"long_name"`
+	RouteType int    `json:"route_type"`
+	Color     string `json:"color,omitempty"`
+	TextColor string `json:"text_color,omitempty"`
+}
+
+type apiLines struct {
+	Lines []apiLine `json:"lines"`
+}
+
+type apiTripStop struct {
+	StopID    string     `json:"stop_id"`
+	Name      string     `json:"name"`
+	Sequence  int        `json:"sequence"`
+	Arrival   *time.Time `json:"arrival,omitempty"`
+	Departure *time.Time `json:"departure,omitempty"`
+}
+
+type apiTrip struct {
+	ID       string        `json:"id"`
+	Line     apiLine       `json:"line"`
+	Headsign string        `json:"headsign"`
+	Date     string        `json:"date"`
+	Runs     bool          `json:"runs"`
+	Stops    []apiTripStop `json:"stops"`
+}
+
+// apiRequest parses the common query parameters of the search endpoints.
+type apiRequest struct {
+	date   time.Time
+	clock  int
+	window int
+}
+
+func parseAPIRequest(r *http.Request) (apiRequest, error) {
+	q := r.URL.Query()
+	now := time.Now()
+	req := apiRequest{
+		date:   time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local),
+		clock:  now.Hour()*3600 + now.Minute()*60 + now.Second(),
+		window: 60,
+	}
+	for _, key := range []string{"as_of", "date"} {
+		if s := q.Get(key); s != "" {
+			d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+			if err != nil {
+				return req, invalidParam(key, "a date in YYYY-MM-DD format")
+			}
+			req.date = d
+		}
+	}
+	if s := q.Get("time"); s != "" {
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return req, invalidParam("time", "a time in HH:MM format")
+		}
+		req.clock = t.Hour()*3600 + t.Minute()*60
+	}
+	if s := q.Get("window"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > 1440 {
+			return req, invalidParam("window", "a number of minutes between 1 and 1440")
+		}
+		req.window = n
+	}
+	return req, nil
+}
+
+func stationJSON(idx *search.Index, s search.Station) apiStation {
+	platforms := idx.StationPlatforms[s.ID]
+	if platforms == nil {
+		platforms = []string{}
+	}
+	return apiStation{ID: s.ID, Name: s.Name, Platforms: platforms}
+}
+
+func stopRef(idx *search.Index, id string) apiStopRef {
+	return apiStopRef{StopID: id, Name: idx.StopName[id]}
+}
+
+func lineJSON(idx *search.Index, routeID string) apiLine {
+	r, ok := idx.Route(routeID)
+	if !ok {
+		return apiLine{ID: routeID}
+	}
+	return apiLine{ID: r.ID, ShortName: r.ShortName, LongName: r.LongName, RouteType: r.Type, Color: r.Color, TextColor: r.TextColor}
+}
+
+// lookupStation returns the station named by the required query parameter.
+func lookupStation(idx *search.Index, r *http.Request, param string) (search.Station, error) {
+	id := r.URL.Query().Get(param)
+	if id == "" {
+		return search.Station{}, missingParam(param)
+	}
+	s, ok := idx.Station(id)
+	if !ok {
+		return search.Station{}, notFound("unknown station %q", id)
+	}
+	return s, nil
+}
+
+func (h *Handler) HandleAPIConnections(w http.ResponseWriter, r *http.Request) {
+	req, err := parseAPIRequest(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	from, err := lookupStation(idx, r, "from")
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	to, err := lookupStation(idx, r, "to")
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	conns := idx.FindConnections(from.ID, to.ID, req.clock, req.window, req.date)
+	h.metrics.observeResults("connections", len(conns))
+	resp := apiConnections{
+		From:        stationJSON(idx, from),
+		To:          stationJSON(idx, to),
+		Date:        req.date.Format("2006-01-02"),
+		Connections: make([]apiConnection, len(conns)),
+	}
+	for i, c := range conns {
+		resp.Connections[i] = apiConnection{
+			TripID:          c.TripID,
+			Line:            c.Line,
+			RouteType:       c.RouteType,
+			Headsign:        c.Headsign,
+			From:            stopRef(idx, c.FromStopID),
+			To:              stopRef(idx, c.ToStopID),
+			Departure:       search.ResolveTime(req.date, c.DepartureTime),
+			Arrival:         search.ResolveTime(req.date, c.ArrivalTime),
+			DurationMinutes: c.Duration / 60,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleAPIDepartures(w http.ResponseWriter, r *http.Request) {
+	req, err := parseAPIRequest(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	station, err := lookupStation(idx, r, "station")
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	deps := idx.DepartureBoard(station.ID, req.clock, req.window, req.date)
+	h.metrics.observeResults("departures", len(deps))
+	resp := apiDepartures{
+		Station:    stationJSON(idx, station),
+		Date:       req.date.Format("2006-01-02"),
+		Departures: make([]apiDeparture, len(deps)),
+	}
+	for i, d := range deps {
+		resp.Departures[i] = apiDeparture{
+			TripID:    d.TripID,
+			Line:      d.Line,
+			RouteType: d.RouteType,
+			Headsign:  d.Headsign,
+			Stop:      stopRef(idx, d.StopID),
+			Departure: search.ResolveTime(req.date, d.DepartureTime),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleAPIStations(w http.ResponseWriter, r *http.Request) {
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	stations := idx.Stations
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		stations = idx.SearchStations(q)
+	}
+	resp := apiStations{Stations: make([]apiStation, len(stations))}
+	for i, s := range stations {
+		resp.Stations[i] = stationJSON(idx, s)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleAPIStation(w http.ResponseWriter, r *http.Request) {
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	s, ok := idx.Station(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, r, notFound("unknown station %q", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, stationJSON(idx, s))
+}
+
+func (h *Handler) HandleAPILines(w http.ResponseWriter, r *http.Request) {
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	resp := apiLines{Lines: make([]apiLine, len(idx.Routes))}
+	for i, rt := range idx.Routes {
+		resp.Lines[i] = lineJSON(idx, rt.ID)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleAPILine(w http.ResponseWriter, r *http.Request) {
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	if _, ok := idx.Route(r.PathValue("id")); !ok {
+		writeAPIError(w, r, notFound("unknown line %q", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, lineJSON(idx, r.PathValue("id")))
+}
+
+func (h *Handler) HandleAPITrip(w http.ResponseWriter, r *http.Request) {
+	req, err := parseAPIRequest(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	idx, err := h.resolveIndex(r)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	id := r.PathValue("id")
+	routeID, ok := idx.TripRoute[id]
+	if !ok {
+		writeAPIError(w, r, notFound("unknown trip %q", id))
+		return
+	}
+
+	active := search.ActiveServices(idx.Calendars, idx.CalendarDates, req.date)
+	resp := apiTrip{
+		ID:       id,
+		Line:     lineJSON(idx, routeID),
+		Headsign: idx.TripHeadsign[id],
+		Date:     req.date.Format("2006-01-02"),
+		Runs:     active[idx.TripService[id]],
+		Stops:    make([]apiTripStop, len(idx.TripStops[id])),
+	}
+	for i, ts := range idx.TripStops[id] {
+		resp.Stops[i] = apiTripStop{
+			StopID:    ts.StopID,
+			Name:      idx.StopName[ts.StopID],
+			Sequence:  ts.StopSequence,
+			Arrival:   search.ResolveStopTime(req.date, ts.ArrivalTime),
+			Departure: search.ResolveStopTime(req.date, ts.DepartureTime),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
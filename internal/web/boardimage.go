@@ -0,0 +1,88 @@
+package web
+
+import (
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timetable/internal/eink"
+	"timetable/internal/search"
+)
+
+const (
+	defaultImageWidth  = 800
+	defaultImageHeight = 480
+	maxImageSize       = 2000
+)
+
+// HandleBoardImage serves a board as a PNG for e-paper displays:
+// /board/{slug}.png?w=800&h=480&dither=1. The image only changes once a
+// minute, so it may be cached until the next one.
+func (h *Handler) HandleBoardImage(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.boardFor(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	opt := eink.Options{
+		Width:  imageSize(q.Get("w"), defaultImageWidth),
+		Height: imageSize(q.Get("h"), defaultImageHeight),
+		Dither: q.Get("dither") == "1",
+	}
+
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+	next := minute.Add(time.Minute)
+	img := eink.Render(boardImage(h.boardData(b, now), minute), opt)
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(next.Sub(now).Seconds())))
+	w.Header().Set("Expires", next.UTC().Format(http.TimeFormat))
+	w.Header().Set("Last-Modified", minute.UTC().Format(http.TimeFormat))
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(w, img); err != nil {
+		// The headers are sent, so the client only sees a truncated image.
+		requestLogger(r).Error("Board image failed", "board", b.Slug, "err", err)
+	}
+}
+
+func imageSize(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return def
+	}
+	return min(max(n, 64), maxImageSize)
+}
+
+// boardImage lays out the board data like liveboard_data.html does.
+func boardImage(data boardData, at time.Time) eink.Board {
+	b := eink.Board{
+		Title:  data.Board.Name,
+		Clock:  at.Format("15:04"),
+		Footer: fmt.Sprintf("Aktualizováno v %s · příštích %d minut", at.Format("15:04"), data.Board.Window),
+	}
+	if data.Board.To != "" {
+		b.Empty = fmt.Sprintf("Žádné spojení v příštích %d minutách.", data.Board.Window)
+		for _, c := range data.Connections {
+			b.Rows = append(b.Rows, eink.Row{
+				Line:        c.Line,
+				Destination: c.Headsign,
+				Time:        search.FormatTime(c.DepartureTime) + "–" + search.FormatTime(c.ArrivalTime),
+				Minutes:     c.ArrivesIn,
+			})
+		}
+	} else {
+		b.Empty = fmt.Sprintf("Žádné odjezdy v příštích %d minutách.", data.Board.Window)
+		for _, d := range data.Departures {
+			b.Rows = append(b.Rows, eink.Row{
+				Line:        d.Line,
+				Destination: d.Headsign,
+				Time:        search.FormatTime(d.DepartureTime),
+				Minutes:     d.LeavesIn,
+			})
+		}
+	}
+	return b
+}
@@ -0,0 +1,245 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"timetable/internal/search"
+	"timetable/internal/store"
+)
+
+// defaultBoards are served unless the config file or the database defines a
+// board with the same slug.
+var defaultBoards = []store.Board{
+	{Slug: "z-domova", Name: "Melantrichova → Fügnerova", From: "11311", To: "911", Window: 60},
+}
+
+// LoadBoards reads board definitions from a JSON file holding an array of
+// boards.
+func LoadBoards(path string) ([]store.Board, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var boards []store.Board
+	if err := json.Unmarshal(data, &boards); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(boards))
+	for i := range boards {
+		if err := boards[i].Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if seen[boards[i].Slug] {
+			return nil, fmt.Errorf("%s: duplicate board slug %q", path, boards[i].Slug)
+		}
+		seen[boards[i].Slug] = true
+	}
+	return boards, nil
+}
+
+// allBoards lists the boards from the config file, then those stored in the
+// database and finally the defaults, each slug only once.
+func (h *Handler) allBoards() ([]store.Board, error) {
+	stored, err := h.updater.Store().Boards()
+	if err != nil {
+		return nil, err
+	}
+	var boards []store.Board
+	seen := make(map[string]bool)
+	for _, list := range [][]store.Board{h.fileBoards, stored, defaultBoards} {
+		for _, b := range list {
+			if !seen[b.Slug] {
+				seen[b.Slug] = true
+				boards = append(boards, b)
+			}
+		}
+	}
+	return boards, nil
+}
+
+func (h *Handler) lookupBoard(slug string) (store.Board, error) {
+	boards, err := h.allBoards()
+	if err != nil {
+		return store.Board{}, err
+	}
+	for _, b := range boards {
+		if b.Slug == slug {
+			return b, nil
+		}
+	}
+	return store.Board{}, store.ErrBoardNotFound
+}
+
+// boardFor resolves the {slug} path parameter, writing a 404 if unknown.
+func (h *Handler) boardFor(w http.ResponseWriter, r *http.Request) (store.Board, bool) {
+	b, err := h.lookupBoard(r.PathValue("slug"))
+	switch {
+	case errors.Is(err, store.ErrBoardNotFound):
+		httpError(w, r, "404 page not found", http.StatusNotFound)
+		return b, false
+	case err != nil:
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+		return b, false
+	}
+	return b, true
+}
+
+func (h *Handler) HandleBoard(w http.ResponseWriter, r *http.Request) {
+	// {slug} is a whole path segment, so /board/{slug}.png arrives here.
+	if slug, ok := strings.CutSuffix(r.PathValue("slug"), ".png"); ok {
+		r.SetPathValue("slug", slug)
+		h.HandleBoardImage(w, r)
+		return
+	}
+	b, ok := h.boardFor(w, r)
+	if !ok {
+		return
+	}
+	h.templates.ExecuteTemplate(w, "liveboard.html", struct{ Board store.Board }{b})
+}
+
+type liveConnection struct {
+	search.Connection
+	ArrivesIn int
+}
+
+type liveDeparture struct {
+	search.DepartureInfo
+	LeavesIn int
+}
+
+// boardData is what liveboard_data.html renders: connections for boards
+// with a destination, departures otherwise.
+type boardData struct {
+	Board       store.Board
+	Connections []liveConnection
+	Departures  []liveDeparture
+	UpdatedAt   string
+	Count       int
+}
+
+func (h *Handler) HandleBoardData(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.boardFor(w, r)
+	if !ok {
+		return
+	}
+	h.templates.ExecuteTemplate(w, "liveboard_data.html", h.boardData(b, time.Now()))
+}
+
+// boardData evaluates board b at now.
+func (h *Handler) boardData(b store.Board, now time.Time) boardData {
+	data := boardData{Board: b, UpdatedAt: now.Format("15:04:05")}
+	if b.To != "" {
+		data.Connections = h.nextConnections(b.From, b.To, b.Lines, b.Window, now)
+		data.Count = len(data.Connections)
+	} else {
+		data.Departures = h.nextDepartures(b.From, b.Lines, b.Window, now)
+		data.Count = len(data.Departures)
+	}
+	return data
+}
+
+// nextConnections lists the connections from one station to another
+// leaving within window minutes of now, optionally only on the given lines.
+func (h *Handler) nextConnections(from, to string, lines []string, window int, now time.Time) []liveConnection {
+	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	only := lineSet(lines)
+
+	var live []liveConnection
+	for _, c := range h.updater.IndexForDate(now).FindConnections(from, to, currentTime, window, now) {
+		if len(only) > 0 && !only[c.Line] {
+			continue
+		}
+		live = append(live, liveConnection{
+			Connection: c,
+			ArrivesIn:  max((c.ArrivalTime-currentTime)/60, 0),
+		})
+	}
+	return live
+}
+
+// nextDepartures lists the departures from a station within window minutes
+// of now, optionally only on the given lines.
+func (h *Handler) nextDepartures(station string, lines []string, window int, now time.Time) []liveDeparture {
+	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	only := lineSet(lines)
+
+	var live []liveDeparture
+	for _, d := range h.updater.IndexForDate(now).DepartureBoard(station, currentTime, window, now) {
+		if len(only) > 0 && !only[d.Line] {
+			continue
+		}
+		live = append(live, liveDeparture{
+			DepartureInfo: d,
+			LeavesIn:      max((d.DepartureTime-currentTime)/60, 0),
+		})
+	}
+	return live
+}
+
+func lineSet(lines []string) map[string]bool {
+	set := make(map[string]bool, len(lines))
+	for _, l := range lines {
+		set[l] = true
+	}
+	return set
+}
+
+// HandleBoards lists all boards.
+func (h *Handler) HandleBoards(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.allBoards()
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, boards)
+}
+
+// HandleAdminSaveBoard creates or replaces a board stored in the database
+// from a JSON body.
+func (h *Handler) HandleAdminSaveBoard(w http.ResponseWriter, r *http.Request) {
+	var b store.Board
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&b); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	b.Slug = r.PathValue("slug")
+	if err := b.Validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := h.updater.Index().Station(b.From); !ok {
+		writeJSONError(w, r, http.StatusBadRequest, "unknown station "+b.From)
+		return
+	}
+	if _, ok := h.updater.Index().Station(b.To); b.To != "" && !ok {
+		writeJSONError(w, r, http.StatusBadRequest, "unknown station "+b.To)
+		return
+	}
+	if err := h.updater.Store().SaveBoard(b); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.boardHub.boardChanged(b.Slug)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func (h *Handler) HandleAdminDeleteBoard(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	err := h.updater.Store().DeleteBoard(slug)
+	switch {
+	case errors.Is(err, store.ErrBoardNotFound):
+		writeJSONError(w, r, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error())
+	default:
+		h.boardHub.boardChanged(slug)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"timetable/internal/ical"
+	"timetable/internal/search"
+)
+
+// HandleConnectionICS serves one ride on a trip as an iCalendar event:
+// /calendar/connection.ics?trip=&from=&to=&date=.
+func (h *Handler) HandleConnectionICS(w http.ResponseWriter, r *http.Request) {
+	idx, c, ok := h.tripConnection(w, r)
+	if !ok {
+		return
+	}
+	date := requestDate(r)
+	if !search.ActiveServices(idx.Calendars, idx.CalendarDates, date)[idx.TripService[c.TripID]] {
+		httpError(w, r, "trip does not run on this date", http.StatusNotFound)
+		return
+	}
+	e := connectionEvent(r, idx, c, date)
+	e.UID = fmt.Sprintf("%s-%s-%s-%s@%s", c.TripID, c.FromStopID, c.ToStopID, date.Format("20060102"), r.Host)
+	writeICS(w, "spojeni.ics", []ical.Event{e})
+}
+
+// HandleCommuteICS serves a ride on a trip as an event repeating on every
+// day the trip runs: a weekly rule from calendar.txt with the exceptions
+// from calendar_dates.txt as EXDATE and RDATE.
+func (h *Handler) HandleCommuteICS(w http.ResponseWriter, r *http.Request) {
+	idx, c, ok := h.tripConnection(w, r)
+	if !ok {
+		return
+	}
+	p, ok := search.ServiceDays(idx.Calendars, idx.CalendarDates, idx.TripService[c.TripID])
+	if !ok {
+		httpError(w, r, "trip does not run on any day", http.StatusNotFound)
+		return
+	}
+	at := func(d time.Time) time.Time { return search.ResolveTime(d, c.DepartureTime) }
+
+	var e ical.Event
+	if first, ok := p.First(); ok {
+		e = connectionEvent(r, idx, c, first)
+		// Trips after midnight run on the day after their service day.
+		shift := c.DepartureTime / (24 * 3600)
+		for _, wd := range p.Weekdays {
+			e.Repeat = append(e.Repeat, (wd+time.Weekday(shift))%7)
+		}
+		e.Until = at(p.End)
+		for _, d := range p.Removed {
+			e.ExDates = append(e.ExDates, at(d))
+		}
+		for _, d := range p.Added {
+			e.RDates = append(e.RDates, at(d))
+		}
+	} else {
+		e = connectionEvent(r, idx, c, p.Added[0])
+		for _, d := range p.Added[1:] {
+			e.RDates = append(e.RDates, at(d))
+		}
+	}
+	e.UID = fmt.Sprintf("commute-%s-%s-%s@%s", c.TripID, c.FromStopID, c.ToStopID, r.Host)
+	writeICS(w, "dojizdeni.ics", []ical.Event{e})
+}
+
+// tripConnection resolves the trip, from and to parameters, writing an
+// error response if they do not describe a ride.
+func (h *Handler) tripConnection(w http.ResponseWriter, r *http.Request) (*search.Index, search.Connection, bool) {
+	idx := h.indexFor(w, r)
+	if idx == nil {
+		return nil, search.Connection{}, false
+	}
+	q := r.URL.Query()
+	c, ok := idx.TripConnection(q.Get("trip"), q.Get("from"), q.Get("to"))
+	if !ok {
+		httpError(w, r, "connection not found", http.StatusNotFound)
+		return nil, search.Connection{}, false
+	}
+	return idx, c, true
+}
+
+// connectionEvent describes connection c on the given service date.
+func connectionEvent(r *http.Request, idx *search.Index, c search.Connection, date time.Time) ical.Event {
+	from := platformName(idx, c.FromStopID)
+	to := platformName(idx, c.ToStopID)
+	link := fmt.Sprintf("%s/api/v1/trips/%s?date=%s", baseURL(r), url.PathEscape(c.TripID), date.Format("2006-01-02"))
+	return ical.Event{
+		Start:    search.ResolveTime(date, c.DepartureTime),
+		End:      search.ResolveTime(date, c.ArrivalTime),
+		Summary:  fmt.Sprintf("Linka %s: %s → %s", c.Line, c.FromStop, c.ToStop),
+		Location: from,
+		Description: fmt.Sprintf("Linka %s směr %s\nOdjezd %s: %s\nPříjezd %s: %s\n%s",
+			c.Line, c.Headsign, search.FormatTime(c.DepartureTime), from,
+			search.FormatTime(c.ArrivalTime), to, link),
+		URL: link,
+	}
+}
+
+func platformName(idx *search.Index, stopID string) string {
+	if code := idx.PlatformCode[stopID]; code != "" {
+		return idx.StopName[stopID] + ", nástupiště " + code
+	}
+	return idx.StopName[stopID]
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeICS(w http.ResponseWriter, filename string, events []ical.Event) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ical.Write(w, events)
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"timetable/internal/diff"
+	"timetable/internal/store"
+)
+
+// HandleAdminDiff renders the changes between two stored feed versions,
+// selected by the "old" and "new" parameters (ID or feed_version). By
+// default it compares the live feed with the upcoming one or, without an
+// upcoming feed, the version before the live one with the live one.
+func (h *Handler) HandleAdminDiff(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.updater.Feeds()
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	oldID, newID := defaultDiffFeeds(feeds, h.updater.LiveFeedID(), h.updater.UpcomingFeedID())
+
+	q := r.URL.Query()
+	for _, p := range []struct {
+		param string
+		id    *int64
+	}{{"old", &oldID}, {"new", &newID}} {
+		ref := q.Get(p.param)
+		if ref == "" {
+			continue
+		}
+		f, err := h.updater.LookupFeed(ref)
+		if errors.Is(err, store.ErrFeedNotFound) {
+			httpError(w, r, "unknown feed "+ref, http.StatusNotFound)
+			return
+		} else if err != nil {
+			httpError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		*p.id = f.ID
+	}
+
+	var opts diff.Options
+	if lines := strings.TrimSpace(q.Get("lines")); lines != "" {
+		opts.Lines = strings.Split(lines, ",")
+		for i := range opts.Lines {
+			opts.Lines[i] = strings.TrimSpace(opts.Lines[i])
+		}
+	}
+
+	data := struct {
+		Feeds  []store.FeedRecord
+		OldID  int64
+		NewID  int64
+		Lines  string
+		Report *diff.Report
+	}{Feeds: feeds, OldID: oldID, NewID: newID, Lines: q.Get("lines")}
+
+	if oldID != 0 && newID != 0 {
+		oldFeed, err := h.updater.LoadFeed(oldID)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		newFeed, err := h.updater.LoadFeed(newID)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Report = diff.Feeds(oldFeed, newFeed, opts)
+	}
+
+	h.templates.ExecuteTemplate(w, "admin_diff.html", data)
+}
+
+// defaultDiffFeeds picks the versions the diff page compares by default.
+// feeds is ordered newest first.
+func defaultDiffFeeds(feeds []store.FeedRecord, live, upcoming int64) (oldID, newID int64) {
+	if upcoming != 0 {
+		return live, upcoming
+	}
+	for _, f := range feeds {
+		if f.ID < live {
+			return f.ID, live
+		}
+	}
+	return 0, live
+}
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"timetable/internal/store"
+)
+
+// eventWriteTimeout bounds each write to a board event stream.
+const eventWriteTimeout = 10 * time.Second
+
+// boardHub renders each board once per update and shares the result among
+// all clients watching it over server-sent events. A board is only
+// evaluated while it has subscribers, and its definition is only looked up
+// again when it is saved or deleted.
+type boardHub struct {
+	h *Handler
+
+	mu      sync.Mutex
+	streams map[string]*boardStream
+}
+
+type boardStream struct {
+	subs map[chan []byte]struct{}
+	// last is the most recent event, sent to clients as they subscribe.
+	last []byte
+	stop context.CancelFunc
+	// reload is signalled when the board's definition changes.
+	reload chan struct{}
+}
+
+func newBoardHub(h *Handler) *boardHub {
+	return &boardHub{h: h, streams: make(map[string]*boardStream)}
+}
+
+// subscribe registers a client for updates of board slug. The channel is
+// closed if the board goes away; cancel must be called when the client
+// disconnects.
+func (hub *boardHub) subscribe(slug string) (ch chan []byte, cancel func()) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	s, ok := hub.streams[slug]
+	if !ok {
+		ctx, stop := context.WithCancel(context.Background())
+		s = &boardStream{subs: make(map[chan []byte]struct{}), stop: stop, reload: make(chan struct{}, 1)}
+		hub.streams[slug] = s
+		go hub.run(ctx, slug, s)
+	}
+	ch = make(chan []byte, 1)
+	s.subs[ch] = struct{}{}
+	if s.last != nil {
+		ch <- s.last
+	}
+	return ch, func() { hub.unsubscribe(slug, s, ch) }
+}
+
+func (hub *boardHub) unsubscribe(slug string, s *boardStream, ch chan []byte) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := s.subs[ch]; !ok {
+		return
+	}
+	delete(s.subs, ch)
+	if len(s.subs) == 0 {
+		s.stop()
+		if hub.streams[slug] == s {
+			delete(hub.streams, slug)
+		}
+	}
+}
+
+// boardChanged makes the stream of board slug, if any, look up the board
+// again after it was saved or deleted.
+func (hub *boardHub) boardChanged(slug string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if s, ok := hub.streams[slug]; ok {
+		select {
+		case s.reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run re-renders the board whenever the minute ticks, its first departure
+// leaves, the updater swaps the index or the board is changed, until the
+// last client leaves or the board goes away.
+func (hub *boardHub) run(ctx context.Context, slug string, s *boardStream) {
+	b, err := hub.h.lookupBoard(slug)
+	for err == nil {
+		changed := hub.h.updater.IndexChanged()
+		now := time.Now()
+		var event []byte
+		var next time.Time
+		if event, next, err = hub.render(b, now); err != nil {
+			break
+		}
+		hub.publish(s, event)
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.reload:
+			timer.Stop()
+			b, err = hub.h.lookupBoard(slug)
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	if !errors.Is(err, store.ErrBoardNotFound) {
+		slog.Error("Board events failed", "board", slug, "err", err)
+	}
+	hub.close(slug, s)
+}
+
+// render evaluates the board as a server-sent event and returns when it
+// next needs to be evaluated.
+func (hub *boardHub) render(b store.Board, now time.Time) ([]byte, time.Time, error) {
+	data := hub.h.boardData(b, now)
+
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	first := -1
+	if len(data.Connections) > 0 {
+		first = data.Connections[0].DepartureTime
+	} else if len(data.Departures) > 0 {
+		first = data.Departures[0].DepartureTime
+	}
+	if leaves := now.Add(time.Duration(first-currentTime+1) * time.Second); first > currentTime && leaves.Before(next) {
+		next = leaves
+	}
+
+	var buf bytes.Buffer
+	if err := hub.h.templates.ExecuteTemplate(&buf, "liveboard_data.html", data); err != nil {
+		return nil, time.Time{}, err
+	}
+	return formatEvent("update", buf.String()), next, nil
+}
+
+// publish hands event to every subscriber. A client that has not consumed
+// the previous event only gets the newest one.
+func (hub *boardHub) publish(s *boardStream, event []byte) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	s.last = event
+	for ch := range s.subs {
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+func (hub *boardHub) close(slug string, s *boardStream) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	s.stop()
+	for ch := range s.subs {
+		close(ch)
+	}
+	s.subs = nil
+	if hub.streams[slug] == s {
+		delete(hub.streams, slug)
+	}
+}
+
+func formatEvent(name, data string) []byte {
+	var b strings.Builder
+	b.WriteString("event: " + name + "\n")
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// HandleBoardEvents streams board updates as server-sent events. Each
+// "update" event carries the rendered board fragment.
+func (h *Handler) HandleBoardEvents(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.boardFor(w, r)
+	if !ok {
+		return
+	}
+	ch, cancel := h.boardHub.subscribe(b.Slug)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The stream outlives the server's write timeout, so each write gets
+	// its own deadline instead.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	rc.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.shutdown:
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timetable/internal/store"
+)
+
+const (
+	favoritesCookie = "favorites"
+	// favoritesPreview is the number of upcoming trips shown per favorite
+	// on the index page.
+	favoritesPreview = 3
+	favoritesWindow  = 120
+)
+
+// favoritesToken returns the anonymous token from the request cookie. With
+// create set, a new token is issued if the request has none.
+func favoritesToken(w http.ResponseWriter, r *http.Request, create bool) string {
+	if c, err := r.Cookie(favoritesCookie); err == nil && validToken(c.Value) {
+		return c.Value
+	}
+	if !create {
+		return ""
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     favoritesCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   400 * 24 * 3600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+func validToken(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func (h *Handler) HandleFavorites(w http.ResponseWriter, r *http.Request) {
+	favorites := []store.Favorite{}
+	if token := favoritesToken(w, r, false); token != "" {
+		f, err := h.updater.Store().Favorites(token)
+		if err != nil {
+			writeFavoritesError(w, r, err)
+			return
+		}
+		if f != nil {
+			favorites = f
+		}
+	}
+	writeJSON(w, http.StatusOK, favorites)
+}
+
+// HandleAddFavorite saves a favorite from a JSON body, issuing a token
+// cookie on first use. The name defaults to the station or board name.
+func (h *Handler) HandleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	var f store.Favorite
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&f); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := f.Validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	idx := h.updater.Index()
+	switch f.Kind {
+	case store.FavoriteBoard:
+		b, err := h.lookupBoard(f.Board)
+		if err != nil {
+			writeFavoritesError(w, r, err)
+			return
+		}
+		if f.Name == "" {
+			f.Name = b.Name
+		}
+	default:
+		for _, id := range []string{f.From, f.To} {
+			if _, ok := idx.Station(id); id != "" && !ok {
+				writeJSONError(w, r, http.StatusBadRequest, "unknown station "+id)
+				return
+			}
+		}
+		if f.Name == "" {
+			f.Name = idx.StopName[f.From]
+			if f.To != "" {
+				f.Name += " → " + idx.StopName[f.To]
+			}
+		}
+	}
+
+	f, err := h.updater.Store().AddFavorite(favoritesToken(w, r, true), f)
+	if err != nil {
+		writeFavoritesError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// HandleRenameFavorite changes a favorite's name from a {"name": ...} body.
+func (h *Handler) HandleRenameFavorite(w http.ResponseWriter, r *http.Request) {
+	id, ok := favoriteID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&body); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	f, err := h.updater.Store().RenameFavorite(favoritesToken(w, r, false), id, body.Name)
+	if err != nil {
+		writeFavoritesError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+func (h *Handler) HandleDeleteFavorite(w http.ResponseWriter, r *http.Request) {
+	id, ok := favoriteID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.updater.Store().DeleteFavorite(favoritesToken(w, r, false), id); err != nil {
+		writeFavoritesError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func favoriteID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, r, http.StatusNotFound, store.ErrFavoriteNotFound.Error())
+		return 0, false
+	}
+	return id, true
+}
+
+func writeFavoritesError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrFavoriteNotFound), errors.Is(err, store.ErrBoardNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrFavoriteExists), errors.Is(err, store.ErrTooManyFavorites):
+		status = http.StatusConflict
+	}
+	writeJSONError(w, r, status, err.Error())
+}
+
+// favoriteView is a favorite with its next connections or departures.
+type favoriteView struct {
+	store.Favorite
+	Link        string
+	Connections []liveConnection
+	Departures  []liveDeparture
+}
+
+// HandleFavoritesFragment renders the "My favourites" section of the index
+// page with the next trips of each favorite.
+func (h *Handler) HandleFavoritesFragment(w http.ResponseWriter, r *http.Request) {
+	var views []favoriteView
+	if token := favoritesToken(w, r, false); token != "" {
+		favorites, err := h.updater.Store().Favorites(token)
+		if err != nil {
+			requestLogger(r).Error("List favorites failed", "err", err)
+		}
+		now := time.Now()
+		for _, f := range favorites {
+			views = append(views, h.favoriteView(f, now))
+		}
+	}
+	h.templates.ExecuteTemplate(w, "favorites.html", views)
+}
+
+func (h *Handler) favoriteView(f store.Favorite, now time.Time) favoriteView {
+	v := favoriteView{Favorite: f}
+	switch f.Kind {
+	case store.FavoriteStation:
+		v.Departures = h.nextDepartures(f.From, nil, favoritesWindow, now)
+	case store.FavoriteConnection:
+		v.Connections = h.nextConnections(f.From, f.To, nil, favoritesWindow, now)
+	case store.FavoriteBoard:
+		v.Link = "/board/" + f.Board
+		if b, err := h.lookupBoard(f.Board); err == nil {
+			data := h.boardData(b, now)
+			v.Connections, v.Departures = data.Connections, data.Departures
+		}
+	}
+	v.Connections = v.Connections[:min(len(v.Connections), favoritesPreview)]
+	v.Departures = v.Departures[:min(len(v.Departures), favoritesPreview)]
+	return v
+}
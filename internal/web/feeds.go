@@ -0,0 +1,100 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"timetable/internal/search"
+	"timetable/internal/store"
+)
+
+var errInvalidAsOf = errors.New("invalid as_of date, expected YYYY-MM-DD")
+
+// indexFor returns the index a search request should run against, writing
+// an error response and returning nil if it cannot be resolved.
+func (h *Handler) indexFor(w http.ResponseWriter, r *http.Request) *search.Index {
+	idx, err := h.resolveIndex(r)
+	switch {
+	case err == nil:
+		return idx
+	case errors.Is(err, errInvalidAsOf):
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, store.ErrFeedNotFound):
+		httpError(w, r, "no matching feed version", http.StatusNotFound)
+	default:
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// resolveIndex picks the feed named by the "feed" parameter (ID or
+// feed_version), the feed valid on the "as_of" date (YYYY-MM-DD),
+// or the current or upcoming feed covering the requested date.
+func (h *Handler) resolveIndex(r *http.Request) (*search.Index, error) {
+	var (
+		feed store.FeedRecord
+		err  error
+	)
+	switch ref, asOf := r.URL.Query().Get("feed"), r.URL.Query().Get("as_of"); {
+	case ref != "":
+		feed, err = h.updater.LookupFeed(ref)
+	case asOf != "":
+		day, perr := time.ParseInLocation("2006-01-02", asOf, time.Local)
+		if perr != nil {
+			return nil, errInvalidAsOf
+		}
+		feed, err = h.updater.FeedAsOf(day)
+	default:
+		return h.updater.IndexForDate(requestDate(r)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.updater.IndexForFeed(feed.ID)
+}
+
+// requestDate returns the service date of a search request: the "date"
+// parameter, else the "as_of" date, else today.
+func requestDate(r *http.Request) time.Time {
+	for _, key := range []string{"date", "as_of"} {
+		if d, err := time.Parse("2006-01-02", r.URL.Query().Get(key)); err == nil {
+			return d
+		}
+	}
+	return time.Now()
+}
+
+// HandleFeeds lists the stored feed versions.
+func (h *Handler) HandleFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.updater.Feeds()
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type feedResult struct {
+		ID         int64     `json:"id"`
+		Version    string    `json:"feed_version"`
+		ValidFrom  string    `json:"valid_from,omitempty"`
+		ValidTo    string    `json:"valid_to,omitempty"`
+		ImportedAt time.Time `json:"imported_at"`
+		Live       bool      `json:"live"`
+		Upcoming   bool      `json:"upcoming,omitempty"`
+	}
+	live := h.updater.LiveFeedID()
+	upcoming := h.updater.UpcomingFeedID()
+	results := make([]feedResult, len(feeds))
+	for i, f := range feeds {
+		results[i] = feedResult{
+			ID:         f.ID,
+			Version:    f.Version,
+			ValidFrom:  f.ValidFrom,
+			ValidTo:    f.ValidTo,
+			ImportedAt: f.ImportedAt,
+			Live:       f.ID == live,
+			Upcoming:   f.ID == upcoming,
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -1,25 +1,48 @@
 package web
 
 import (
-	"encoding/json"
 	"html/template"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"time"
 
+	"timetable/internal/diff"
 	"timetable/internal/search"
+	"timetable/internal/store"
 	"timetable/internal/updater"
 )
 
 type Handler struct {
 	updater   *updater.Updater
 	templates *template.Template
+	// fileBoards are the boards from the config file.
+	fileBoards []store.Board
+	boardHub   *boardHub
+	metrics    *serverMetrics
+	shutdown   <-chan struct{}
 }
 
 func NewHandler(u *updater.Updater, templateDir string) (*Handler, error) {
 	funcMap := template.FuncMap{
 		"formatTime": search.FormatTime,
+		"formatDate": func(t time.Time) string {
+			return t.Format("2.1.2006")
+		},
+		"formatShift": diff.FormatShift,
+		"formatDays": func(d diff.Days) string {
+			return d.Format([7]string{"Ne", "Po", "Út", "St", "Čt", "Pá", "So"}, "–", ", ")
+		},
+		"dayType": func(dt string) string {
+			switch dt {
+			case diff.Saturday:
+				return "sobota"
+			case diff.Sunday:
+				return "neděle"
+			default:
+				return "pracovní dny"
+			}
+		},
 		"formatDuration": func(seconds int) string {
 			return strconv.Itoa(seconds/60) + " min"
 		},
@@ -40,11 +63,26 @@ func NewHandler(u *updater.Updater, templateDir string) (*Handler, error) {
 		return nil, err
 	}
 
-	return &Handler{updater: u, templates: tmpl}, nil
+	h := &Handler{updater: u, templates: tmpl, metrics: newServerMetrics(u)}
+	h.boardHub = newBoardHub(h)
+	return h, nil
 }
 
 func (h *Handler) HandleIndex(w http.ResponseWriter, r *http.Request) {
-	h.templates.ExecuteTemplate(w, "index.html", nil)
+	boards, err := h.allBoards()
+	if err != nil {
+		requestLogger(r).Error("List boards failed", "err", err)
+	}
+	data := struct {
+		Boards   []store.Board
+		Validity updater.Validity
+		Upcoming updater.Validity
+	}{
+		Boards:   boards,
+		Validity: h.updater.FeedValidity(),
+		Upcoming: h.updater.UpcomingValidity(),
+	}
+	h.templates.ExecuteTemplate(w, "index.html", data)
 }
 
 func (h *Handler) HandleStopAutocomplete(w http.ResponseWriter, r *http.Request) {
@@ -62,8 +100,7 @@ func (h *Handler) HandleStopAutocomplete(w http.ResponseWriter, r *http.Request)
 		results[i] = stationResult{ID: s.ID, Name: s.Name}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+	writeJSON(w, http.StatusOK, results)
 }
 
 func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
@@ -79,7 +116,7 @@ func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 
 	now := time.Now()
 	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
-	date := now
+	date := requestDate(r)
 
 	if timeStr != "" {
 		if t, err := time.Parse("15:04", timeStr); err == nil {
@@ -87,15 +124,12 @@ func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	dateStr := r.URL.Query().Get("date")
-	if dateStr != "" {
-		if d, err := time.Parse("2006-01-02", dateStr); err == nil {
-			date = d
-		}
+	idx := h.indexFor(w, r)
+	if idx == nil {
+		return
 	}
-
-	idx := h.updater.Index()
 	connections := idx.FindConnections(fromID, toID, currentTime, window, date)
+	h.metrics.observeResults("connections", len(connections))
 
 	fromName := ""
 	toName := ""
@@ -108,11 +142,17 @@ func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 
 	data := struct {
 		Connections []search.Connection
+		FromID      string
+		ToID        string
 		FromName    string
 		ToName      string
+		Date        string
 		Count       int
 	}{
 		Connections: connections,
+		FromID:      fromID,
+		ToID:        toID,
+		Date:        date.Format("2006-01-02"),
 		FromName:    fromName,
 		ToName:      toName,
 		Count:       len(connections),
@@ -133,7 +173,7 @@ func (h *Handler) HandleDepartures(w http.ResponseWriter, r *http.Request) {
 
 	now := time.Now()
 	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
-	date := now
+	date := requestDate(r)
 
 	if timeStr != "" {
 		if t, err := time.Parse("15:04", timeStr); err == nil {
@@ -141,15 +181,12 @@ func (h *Handler) HandleDepartures(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	dateStr := r.URL.Query().Get("date")
-	if dateStr != "" {
-		if d, err := time.Parse("2006-01-02", dateStr); err == nil {
-			date = d
-		}
+	idx := h.indexFor(w, r)
+	if idx == nil {
+		return
 	}
-
-	idx := h.updater.Index()
 	departures := idx.DepartureBoard(stationID, currentTime, window, date)
+	h.metrics.observeResults("departures", len(departures))
 
 	stationName := ""
 	if name, ok := idx.StopName[stationID]; ok {
@@ -158,10 +195,12 @@ func (h *Handler) HandleDepartures(w http.ResponseWriter, r *http.Request) {
 
 	data := struct {
 		Departures  []search.DepartureInfo
+		StationID   string
 		StationName string
 		Count       int
 	}{
 		Departures:  departures,
+		StationID:   stationID,
 		StationName: stationName,
 		Count:       len(departures),
 	}
@@ -169,44 +208,6 @@ func (h *Handler) HandleDepartures(w http.ResponseWriter, r *http.Request) {
 	h.templates.ExecuteTemplate(w, "departures.html", data)
 }
 
-func (h *Handler) HandleLiveBoard(w http.ResponseWriter, r *http.Request) {
-	h.templates.ExecuteTemplate(w, "liveboard.html", nil)
-}
-
-type liveConnection struct {
-	search.Connection
-	ArrivesIn int
-}
-
-func (h *Handler) HandleLiveBoardData(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
-	currentTime := now.Hour()*3600 + now.Minute()*60 + now.Second()
-
-	idx := h.updater.Index()
-	connections := idx.FindConnections("11311", "911", currentTime, 60, now)
-
-	live := make([]liveConnection, len(connections))
-	for i, c := range connections {
-		arrivesIn := (c.ArrivalTime - currentTime) / 60
-		if arrivesIn < 0 {
-			arrivesIn = 0
-		}
-		live[i] = liveConnection{Connection: c, ArrivesIn: arrivesIn}
-	}
-
-	data := struct {
-		Connections []liveConnection
-		UpdatedAt   string
-		Count       int
-	}{
-		Connections: live,
-		UpdatedAt:   now.Format("15:04:05"),
-		Count:       len(live),
-	}
-
-	h.templates.ExecuteTemplate(w, "liveboard_data.html", data)
-}
-
 func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	idx := h.updater.Index()
 	data := map[string]interface{}{
@@ -214,6 +215,15 @@ func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 		"stations": len(idx.Stations),
 		"trips":    len(idx.TripService),
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+	if v := h.updater.FeedValidity(); !v.IsZero() {
+		data["feed_valid_from"] = v.From.Format("2006-01-02")
+		data["feed_valid_to"] = v.To.Format("2006-01-02")
+		data["feed_days_left"] = v.DaysLeft(time.Now())
+		data["feed_validity_source"] = v.Source
+	}
+	if v := h.updater.UpcomingValidity(); !v.IsZero() {
+		data["upcoming_feed_valid_from"] = v.From.Format("2006-01-02")
+		data["upcoming_feed_valid_to"] = v.To.Format("2006-01-02")
+	}
+	writeJSON(w, http.StatusOK, data)
 }
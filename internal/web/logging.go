@@ -0,0 +1,113 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// validRequestID matches request IDs accepted from a reverse proxy.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// searchRoutes are the routes whose query parameters are logged.
+var searchRoutes = map[string]bool{
+	"GET /search":             true,
+	"GET /departures":         true,
+	"GET /api/stops":          true,
+	"GET /api/v1/connections": true,
+	"GET /api/v1/departures":  true,
+	"GET /api/v1/stations":    true,
+}
+
+// quietRoutes are logged at debug level only, as they are polled.
+var quietRoutes = map[string]bool{
+	"GET /health":  true,
+	"GET /metrics": true,
+	"GET /static/": true,
+}
+
+// requestID returns the ID logRequests assigned to the request, or "".
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger returns the default logger annotated with the request ID.
+func requestLogger(r *http.Request) *slog.Logger {
+	return slog.Default().With("request_id", requestID(r.Context()))
+}
+
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// logRequests gives every request an ID, taken from the X-Request-ID header
+// set by a proxy or generated, returns it in the X-Request-ID response
+// header and logs the request once it has been served.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case quietRoutes[r.Pattern]:
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if searchRoutes[r.Pattern] {
+			query := r.URL.Query()
+			var params []any
+			for _, key := range slices.Sorted(maps.Keys(query)) {
+				params = append(params, slog.String(key, strings.Join(query[key], ",")))
+			}
+			attrs = append(attrs, slog.Group("query", params...))
+		}
+		slog.LogAttrs(r.Context(), level, "Request", attrs...)
+	})
+}
+
+// httpError is http.Error with the request ID appended, so that users can
+// quote it when reporting a problem.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if id := requestID(r.Context()); id != "" {
+		msg = fmt.Sprintf("%s\nrequest ID: %s", msg, id)
+	}
+	http.Error(w, msg, code)
+}
+
+// writeJSONError writes {"error": msg} with the request ID.
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg, "request_id": requestID(r.Context())})
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"timetable/internal/metrics"
+	"timetable/internal/updater"
+)
+
+// serverMetrics are the metrics served at /metrics: request counts and
+// latencies recorded by instrument, search result counts recorded by the
+// search handlers and the updater's state, read when scraped.
+type serverMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.Counter
+	duration *metrics.Histogram
+	results  *metrics.Histogram
+}
+
+func newServerMetrics(u *updater.Updater) *serverMetrics {
+	reg := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: reg,
+		requests: reg.Counter("timetable_http_requests_total",
+			"HTTP requests by route pattern, method and status code.", "handler", "method", "code"),
+		duration: reg.Histogram("timetable_http_request_duration_seconds",
+			"HTTP request latency by route pattern.",
+			[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "handler"),
+		results: reg.Histogram("timetable_search_results",
+			"Number of results returned by connection searches and departure boards.",
+			[]float64{0, 1, 2, 5, 10, 20, 50, 100}, "kind"),
+	}
+
+	reg.GaugeFunc("timetable_feed_validity_days_left",
+		"Days until the live feed expires.",
+		func(emit func(float64, ...string)) {
+			if v := u.FeedValidity(); !v.IsZero() {
+				emit(float64(v.DaysLeft(time.Now())))
+			}
+		})
+	reg.GaugeFunc("timetable_update_last_success_timestamp_seconds",
+		"Unix time of the last update or scheduled check that completed without error.",
+		func(emit func(float64, ...string)) {
+			if t := u.Stats().LastSuccess; !t.IsZero() {
+				emit(float64(t.Unix()))
+			}
+		})
+	reg.CounterFunc("timetable_update_failures_total",
+		"Failed updater operations by kind.",
+		func(emit func(float64, ...string)) {
+			for kind, n := range u.Stats().Failures {
+				emit(float64(n), kind)
+			}
+		}, "kind")
+	reg.GaugeFunc("timetable_index_build_duration_seconds",
+		"Time taken to build the most recent live or upcoming index.",
+		func(emit func(float64, ...string)) {
+			emit(u.Stats().IndexBuild.Seconds())
+		})
+	reg.GaugeFunc("timetable_index_size_bytes",
+		"Estimated memory held by the most recently built index.",
+		func(emit func(float64, ...string)) {
+			emit(float64(u.Stats().IndexSize))
+		})
+	reg.GaugeFunc("timetable_index_stations",
+		"Stations in the live index.",
+		func(emit func(float64, ...string)) {
+			emit(float64(len(u.Index().Stations)))
+		})
+	reg.GaugeFunc("timetable_index_trips",
+		"Trips in the live index.",
+		func(emit func(float64, ...string)) {
+			emit(float64(len(u.Index().TripService)))
+		})
+	reg.GaugeFunc("timetable_import_duration_seconds",
+		"Time taken by the last import of a feed into SQLite.",
+		func(emit func(float64, ...string)) {
+			emit(u.Stats().Import.Seconds())
+		})
+	return m
+}
+
+// observeResults records the number of results of a search of the given
+// kind ("connections" or "departures").
+func (m *serverMetrics) observeResults(kind string, n int) {
+	m.results.Observe(float64(n), kind)
+}
+
+// instrument counts the requests served by next and their latency, labelled
+// with the pattern of the route that handled them.
+func (m *serverMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		handler := r.Pattern
+		if handler == "" {
+			handler = "unmatched"
+		}
+		m.requests.Inc(handler, methodLabel(r.Method), strconv.Itoa(rec.status))
+		m.duration.Observe(time.Since(start).Seconds(), handler)
+	})
+}
+
+// methodLabel returns method for the standard HTTP methods and "other" for
+// anything else, so clients cannot create label values at will.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// statusRecorder remembers the status code and the size of the response
+// written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = code, true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// the board event stream needs for flushing.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// apiParam documents one query or path parameter of an API endpoint.
+type apiParam struct {
+	Name        string
+	In          string // "query" or "path"
+	Description string
+	Format      string // OpenAPI string format, e.g. "date"
+	Integer     bool
+	Required    bool
+}
+
+// apiEndpoint is one /api/v1 route. The same table registers the handlers
+// and generates the OpenAPI document, so the two cannot drift apart.
+type apiEndpoint struct {
+	Method   string
+	Pattern  string
+	Summary  string
+	Params   []apiParam
+	Response any
+	Handler  http.HandlerFunc
+}
+
+var (
+	feedParams = []apiParam{
+		{Name: "feed", In: "query", Description: "Feed ID or feed_version to query instead of the current feed"},
+		{Name: "as_of", In: "query", Format: "date", Description: "Query the feed valid on this date; also the default service date"},
+	}
+	timeParams = []apiParam{
+		{Name: "date", In: "query", Format: "date", Description: "Service date, default today"},
+		{Name: "time", In: "query", Description: "Earliest departure (HH:MM), default now"},
+		{Name: "window", In: "query", Integer: true, Description: "Search window in minutes (1-1440), default 60"},
+	}
+)
+
+func params(groups ...[]apiParam) []apiParam {
+	var all []apiParam
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+func (h *Handler) apiEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{
+			Method: "GET", Pattern: "/api/v1/connections",
+			Summary: "Direct connections between two stations",
+			Params: params([]apiParam{
+				{Name: "from", In: "query", Required: true, Description: "Origin station ID"},
+				{Name: "to", In: "query", Required: true, Description: "Destination station ID"},
+			}, timeParams, feedParams),
+			Response: apiConnections{},
+			Handler:  h.HandleAPIConnections,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/departures",
+			Summary: "Departure board of a station",
+			Params: params([]apiParam{
+				{Name: "station", In: "query", Required: true, Description: "Station ID"},
+			}, timeParams, feedParams),
+			Response: apiDepartures{},
+			Handler:  h.HandleAPIDepartures,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/stations",
+			Summary: "All stations, or those matching a search query",
+			Params: params([]apiParam{
+				{Name: "q", In: "query", Description: "Name search, ignoring case and diacritics"},
+			}, feedParams),
+			Response: apiStations{},
+			Handler:  h.HandleAPIStations,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/stations/{id}",
+			Summary:  "A single station and its platforms",
+			Params:   params([]apiParam{{Name: "id", In: "path", Required: true, Description: "Station ID"}}, feedParams),
+			Response: apiStation{},
+			Handler:  h.HandleAPIStation,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/lines",
+			Summary:  "All lines",
+			Params:   feedParams,
+			Response: apiLines{},
+			Handler:  h.HandleAPILines,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/lines/{id}",
+			Summary:  "A single line",
+			Params:   params([]apiParam{{Name: "id", In: "path", Required: true, Description: "Route ID"}}, feedParams),
+			Response: apiLine{},
+			Handler:  h.HandleAPILine,
+		},
+		{
+			Method: "GET", Pattern: "/api/v1/trips/{id}",
+			Summary: "Stop times of a trip on a service date",
+			Params: params([]apiParam{
+				{Name: "id", In: "path", Required: true, Description: "Trip ID"},
+				{Name: "date", In: "query", Format: "date", Description: "Service date, default today"},
+			}, feedParams),
+			Response: apiTrip{},
+			Handler:  h.HandleAPITrip,
+		},
+	}
+}
+
+// openAPIDocument builds the OpenAPI 3 description of endpoints, deriving
+// response schemas from the JSON encoding of the response types.
+func openAPIDocument(endpoints []apiEndpoint) map[string]any {
+	schemas := map[string]any{}
+	errorRef := schemaFor(reflect.TypeOf(apiErrorResponse{}), schemas)
+	errorResponse := func(desc string) map[string]any {
+		return map[string]any{
+			"description": desc,
+			"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+		}
+	}
+
+	paths := map[string]any{}
+	for _, e := range endpoints {
+		var ps []any
+		for _, p := range e.Params {
+			schema := map[string]any{"type": "string"}
+			if p.Integer {
+				schema["type"] = "integer"
+			}
+			if p.Format != "" {
+				schema["format"] = p.Format
+			}
+			ps = append(ps, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required,
+				"description": p.Description,
+				"schema":      schema,
+			})
+		}
+		op := map[string]any{
+			"summary":     e.Summary,
+			"operationId": operationID(e),
+			"parameters":  ps,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content": map[string]any{"application/json": map[string]any{
+						"schema": schemaFor(reflect.TypeOf(e.Response), schemas),
+					}},
+				},
+				"400": errorResponse("Invalid or missing parameter"),
+				"404": errorResponse("Unknown station, line, trip or feed"),
+			},
+		}
+		item, _ := paths[e.Pattern].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[e.Pattern] = item
+		}
+		item[strings.ToLower(e.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Timetable DPMLJ API",
+			"version":     "1",
+			"description": "Times are ISO-8601 timestamps resolved against the service date in the server's time zone.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// operationID derives an ID such as "getStationsById" from an endpoint.
+func operationID(e apiEndpoint) string {
+	path := strings.TrimPrefix(e.Pattern, "/api/v1/")
+	path = pathParam.ReplaceAllString(path, "by/$1")
+	id := strings.ToLower(e.Method)
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the JSON schema of t. Named structs are added to schemas
+// and referenced.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return schemaFor(t.Elem(), schemas)
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() != reflect.Struct:
+		return map[string]any{}
+	}
+
+	name := strings.TrimPrefix(t.Name(), "api")
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+	schemas[name] = nil // guard against recursion
+
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		field, opts, _ := strings.Cut(tag, ",")
+		if field == "" {
+			field = f.Name
+		}
+		props[field] = schemaFor(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, field)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	schemas[name] = schema
+	return ref
+}
@@ -0,0 +1,81 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"timetable/internal/poster"
+	"timetable/internal/search"
+)
+
+// HandleStationPDF serves the printable timetable of a station:
+// /departures/{station}.pdf.
+func (h *Handler) HandleStationPDF(w http.ResponseWriter, r *http.Request) {
+	stationID, ok := strings.CutSuffix(r.PathValue("file"), ".pdf")
+	if !ok {
+		httpError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	idx := h.indexFor(w, r)
+	if idx == nil {
+		return
+	}
+	from, to := h.printValidity(idx)
+	t, ok := poster.Station(idx, stationID, from, to)
+	if !ok {
+		httpError(w, r, "station not found", http.StatusNotFound)
+		return
+	}
+	writePDF(w, r, "jr-"+stationID+".pdf", t.WritePDF)
+}
+
+// HandleLinePDF serves the timetable of a line, given by route ID or short
+// name: /line/{route}.pdf.
+func (h *Handler) HandleLinePDF(w http.ResponseWriter, r *http.Request) {
+	route, ok := strings.CutSuffix(r.PathValue("file"), ".pdf")
+	if !ok {
+		httpError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	idx := h.indexFor(w, r)
+	if idx == nil {
+		return
+	}
+	from, to := h.printValidity(idx)
+	t, ok := poster.Line(idx, route, from, to)
+	if !ok {
+		httpError(w, r, "line not found", http.StatusNotFound)
+		return
+	}
+	writePDF(w, r, "linka-"+t.Route.Line+".pdf", t.WritePDF)
+}
+
+// printValidity returns the validity to print for idx: that of the live or
+// upcoming feed, or zero times for older feeds, whose calendar then gives
+// the range.
+func (h *Handler) printValidity(idx *search.Index) (time.Time, time.Time) {
+	v := h.updater.FeedValidity()
+	if idx != h.updater.Index() {
+		v = h.updater.UpcomingValidity()
+		if up := h.updater.IndexForDate(v.From); v.IsZero() || up != idx {
+			return time.Time{}, time.Time{}
+		}
+	}
+	return v.From, v.To
+}
+
+// writePDF renders into a buffer first so that a failure can still be
+// reported with a proper status.
+func writePDF(w http.ResponseWriter, r *http.Request, filename string, render func(w io.Writer) error) {
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.Write(buf.Bytes())
+}
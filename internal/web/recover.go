@@ -0,0 +1,55 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// recoverPanics turns a panic in a handler into a logged error and an error
+// page. If the handler had already started its response, the connection is
+// aborted instead so that the client does not take a truncated response for
+// a complete one.
+func (h *Handler) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			requestLogger(r).Error("Handler panicked", "route", r.Pattern, "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+			if rec.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			h.serverError(w, r)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// serverError responds with a 500: a JSON error for the API, else the error
+// page.
+func (h *Handler) serverError(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAPIError(w, r, &apiError{http.StatusInternalServerError, "internal", "internal server error"})
+		return
+	}
+	data := struct {
+		Status    int
+		RequestID string
+	}{http.StatusInternalServerError, requestID(r.Context())}
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "error.html", data); err != nil {
+		httpError(w, r, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(buf.Bytes())
+}
@@ -3,24 +3,73 @@ package web
 import (
 	"net/http"
 
+	"timetable/internal/store"
 	"timetable/internal/updater"
 )
 
-func NewRouter(u *updater.Updater, templateDir, staticDir string) (http.Handler, error) {
-	h, err := NewHandler(u, templateDir)
+type Config struct {
+	TemplateDir string
+	StaticDir   string
+	// AdminToken protects the /admin endpoints; they are disabled if empty.
+	AdminToken string
+	// Boards are the board definitions from the config file. They take
+	// precedence over boards stored in the database.
+	Boards []store.Board
+	// Shutdown is closed when the server starts shutting down. Board event
+	// streams end then, as they would otherwise keep the server from
+	// draining; clients reconnect to the next instance.
+	Shutdown <-chan struct{}
+}
+
+func NewRouter(u *updater.Updater, cfg Config) (http.Handler, error) {
+	h, err := NewHandler(u, cfg.TemplateDir)
 	if err != nil {
 		return nil, err
 	}
+	h.fileBoards = cfg.Boards
+	h.shutdown = cfg.Shutdown
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /", h.HandleIndex)
 	mux.HandleFunc("GET /api/stops", h.HandleStopAutocomplete)
+	mux.HandleFunc("GET /api/feeds", h.HandleFeeds)
+	endpoints := h.apiEndpoints()
+	for _, e := range endpoints {
+		mux.HandleFunc(e.Method+" "+e.Pattern, e.Handler)
+	}
+	openAPI := openAPIDocument(endpoints)
+	mux.HandleFunc("GET /api/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, openAPI)
+	})
+	mux.HandleFunc("GET /api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, r, notFound("no API endpoint %s %s", r.Method, r.URL.Path))
+	})
 	mux.HandleFunc("GET /search", h.HandleSearch)
 	mux.HandleFunc("GET /departures", h.HandleDepartures)
-	mux.HandleFunc("GET /z-domova", h.HandleLiveBoard)
-	mux.HandleFunc("GET /z-domova/data", h.HandleLiveBoardData)
+	mux.HandleFunc("GET /departures/{file}", h.HandleStationPDF)
+	mux.HandleFunc("GET /line/{file}", h.HandleLinePDF)
+	mux.HandleFunc("GET /calendar/connection.ics", h.HandleConnectionICS)
+	mux.HandleFunc("GET /calendar/commute.ics", h.HandleCommuteICS)
+	mux.HandleFunc("GET /board/{slug}", h.HandleBoard)
+	mux.HandleFunc("GET /board/{slug}/data", h.HandleBoardData)
+	mux.HandleFunc("GET /board/{slug}/events", h.HandleBoardEvents)
+	mux.HandleFunc("GET /api/boards", h.HandleBoards)
+	mux.Handle("GET /z-domova", http.RedirectHandler("/board/z-domova", http.StatusMovedPermanently))
+	mux.HandleFunc("GET /favorites", h.HandleFavoritesFragment)
+	mux.HandleFunc("GET /api/favorites", h.HandleFavorites)
+	mux.HandleFunc("POST /api/favorites", h.HandleAddFavorite)
+	mux.HandleFunc("PATCH /api/favorites/{id}", h.HandleRenameFavorite)
+	mux.HandleFunc("DELETE /api/favorites/{id}", h.HandleDeleteFavorite)
 	mux.HandleFunc("GET /health", h.HandleHealth)
-	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
+	mux.Handle("GET /metrics", h.metrics.registry)
+	mux.HandleFunc("POST /admin/update", requireAdmin(cfg.AdminToken, h.HandleAdminUpdate))
+	mux.HandleFunc("POST /admin/reload", requireAdmin(cfg.AdminToken, h.HandleAdminReload))
+	mux.HandleFunc("POST /admin/rollback", requireAdmin(cfg.AdminToken, h.HandleAdminRollback))
+	mux.HandleFunc("GET /admin/updates", requireAdmin(cfg.AdminToken, h.HandleAdminUpdates))
+	mux.HandleFunc("GET /admin/diff", requireAdmin(cfg.AdminToken, h.HandleAdminDiff))
+	mux.HandleFunc("PUT /admin/boards/{slug}", requireAdmin(cfg.AdminToken, h.HandleAdminSaveBoard))
+	mux.HandleFunc("DELETE /admin/boards/{slug}", requireAdmin(cfg.AdminToken, h.HandleAdminDeleteBoard))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.StaticDir))))
 
-	return mux, nil
+	return logRequests(h.metrics.instrument(h.recoverPanics(mux))), nil
 }